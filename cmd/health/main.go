@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/gavinturner/vinylretailers/cmd"
+	"github.com/gavinturner/vinylretailers/db"
+	"github.com/gavinturner/vinylretailers/util/leader"
+	"github.com/gavinturner/vinylretailers/util/queue"
+	_ "github.com/lib/pq"
+	"time"
+)
+
+// Lists how each retailer's scans have gone recently against how they went before, flagging those that look broken,
+// which scheduler is leading, and optionally how the scanning queue is doing (with what each scanner holds, for
+// backends that know). The window and baseline default to the HEALTH_* settings the scheduler and scanners use
+// e.g.
+//
+//	go run ./cmd/health
+//	go run ./cmd/health -window 24h -baseline 336h -drop 30
+//	go run ./cmd/health -queue
+func main() {
+	defaults := cmd.LoadRetailerHealthConfig()
+	window := flag.Duration("window", defaults.Window, "how far back the recent scans judged go")
+	baseline := flag.Duration("baseline", defaults.Baseline, "how long before the window the scans it's compared with go back")
+	minScans := flag.Int("min-scans", defaults.MinScans, "scans in the window before a retailer's health is judged")
+	drop := flag.Int("drop", defaults.DropPercent, "percentage drop in success rate or results that flags a retailer as broken")
+	showQueue := flag.Bool("queue", false, "also report on the scanning queue")
+	flag.Parse()
+
+	psqlDB, err := cmd.InitialiseDbConnection()
+	if err != nil {
+		panic(err)
+	}
+	defer psqlDB.Close()
+	vinylDS := db.NewDB(psqlDB)
+
+	health, err := vinylDS.GetRetailerHealth(nil, nil, *window, *baseline, time.Now())
+	if err != nil {
+		panic(err)
+	}
+	broken := 0
+	for _, h := range health {
+		status := "ok"
+		if problem := h.Problem(*minScans, *drop); problem != "" {
+			status = "BROKEN: " + problem
+			broken++
+		}
+		fmt.Printf("%s (last scanned %s): %v/%v scans succeeded (%.0f%%), %.1f results an artist - baseline %.0f%% of %v scans, %.1f for the same %v artists - %s\n",
+			h.RetailerName, h.LastScanAt.Format(time.RFC3339), h.Succeeded, h.Scans, h.SuccessRate()*100, h.ResultsPerArtist(),
+			h.BaselineSuccessRate()*100, h.BaselineScans, h.BaselineResultsPerArtist(), h.ComparedArtists, status)
+	}
+	fmt.Printf("%v of %v retailers look broken\n", broken, len(health))
+
+	status, err := leader.Leader(psqlDB, cmd.SCHEDULER_LEADER_NAME)
+	if err != nil {
+		panic(err)
+	}
+	if status == nil {
+		fmt.Printf("No scheduler is leading - batches aren't being scheduled\n")
+	} else {
+		fmt.Printf("Scheduler leader: %s (connected from %s since %v)\n", status.Identity, status.Client, status.ConnectedAt.Format(time.RFC3339))
+	}
+
+	if *showQueue {
+		printQueueHealth()
+	}
+}
+
+func printQueueHealth() {
+	scanningQueue, err := cmd.InitialiseScanningQueue(queue.Options{})
+	if err != nil {
+		panic(err)
+	}
+	defer scanningQueue.Close()
+	waiting, err := scanningQueue.QueueLength()
+	if err != nil {
+		panic(err)
+	}
+	dead, err := scanningQueue.DeadLetterLength()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%v scan requests waiting, %v dead lettered\n", waiting, dead)
+	reporter, ok := scanningQueue.(queue.ConsumerReporter)
+	if !ok {
+		return
+	}
+	consumers, err := reporter.ConsumerStats()
+	if err != nil {
+		panic(err)
+	}
+	for _, c := range consumers {
+		fmt.Printf("%s: %v scan requests pending, oldest for %s\n", c.Consumer, c.Pending, c.Idle.Round(time.Second))
+	}
+}
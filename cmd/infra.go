@@ -1,16 +1,30 @@
 package cmd
 
 import (
+	"encoding/json"
+	"fmt"
+	"github.com/gavinturner/vinylretailers/db"
+	"github.com/gavinturner/vinylretailers/retailers"
 	"github.com/gavinturner/vinylretailers/util/cfg"
+	"github.com/gavinturner/vinylretailers/util/leader"
+	"github.com/gavinturner/vinylretailers/util/log"
 	"github.com/gavinturner/vinylretailers/util/postgres"
+	"github.com/gavinturner/vinylretailers/util/queue"
 	"github.com/gavinturner/vinylretailers/util/redis"
 	"github.com/pkg/errors"
+	"time"
 )
 
 const (
-	DEFAULT_MAX_IDLE_CONNS    = 2
-	DEFAULT_MAX_CONNS         = 10
-	REDIS_SCANNING_QUEUE_NAME = "scanning_queue"
+	DEFAULT_MAX_IDLE_CONNS = 2
+	DEFAULT_MAX_CONNS      = 10
+	SCANNING_QUEUE_NAME    = "scanning_queue"
+	SCHEDULER_LEADER_NAME  = "scheduler"
+
+	QUEUE_BACKEND_REDIS         = "redis"
+	QUEUE_BACKEND_REDIS_STREAMS = "redis-streams"
+	QUEUE_BACKEND_POSTGRES      = "postgres"
+	QUEUE_BACKEND_MEMORY        = "memory"
 )
 
 func init() {
@@ -34,11 +48,129 @@ func InitialiseDbConnection() (*postgres.DB, error) {
 	return &psqlDB, nil
 }
 
-func InitialiseRedisScanningQueue() (*redis.RedisQueue, error) {
+// InitialiseScanningQueue connects to the scanning queue, using the backend named by the QUEUE_BACKEND setting:
+// "redis" (lists, the default), "redis-streams" (a consumer group, which tracks which scanner holds which request),
+// or "postgres" (a table in the database, so redis isn't needed). The in-process "memory" queue is refused, as
+// nothing queued to it by this process would be scanned (see InitialiseScannerQueue). The visibility timeout and
+// attempts in the given options are overridden by the QUEUE_* settings.
+func InitialiseScanningQueue(options queue.Options) (queue.Queue, error) {
+	options = scanningQueueOptions(options)
 	redisServer, _ := cfg.StringSetting("REDIS_SERVER")
 	if redisServer == "" {
 		redisServer = "localhost:6379"
 	}
 	redisPassword, _ := cfg.StringSetting("REDIS_PASSWORD")
-	return redis.ConnectToQueue(redisServer, redisPassword, REDIS_SCANNING_QUEUE_NAME, true)
+	backend, _ := cfg.StringSetting("QUEUE_BACKEND")
+	switch backend {
+	case "", QUEUE_BACKEND_REDIS:
+		return redis.ConnectToQueue(redisServer, redisPassword, SCANNING_QUEUE_NAME, true, options)
+	case QUEUE_BACKEND_REDIS_STREAMS:
+		return redis.ConnectToStreamQueue(redisServer, redisPassword, SCANNING_QUEUE_NAME, options)
+	case QUEUE_BACKEND_POSTGRES:
+		// the queue has its own connection, as dequeuing shouldn't wait on the caller's connections
+		psqlDB, err := InitialiseDbConnection()
+		if err != nil {
+			return nil, err
+		}
+		return queue.NewPostgresQueue(psqlDB, SCANNING_QUEUE_NAME, options), nil
+	case QUEUE_BACKEND_MEMORY:
+		return nil, fmt.Errorf("queue backend '%s' only works within a single process, so is only used by the scanner (which then runs the scheduler too)",
+			backend)
+	}
+	return nil, fmt.Errorf("unknown queue backend '%s'", backend)
+}
+
+// InitialiseScannerQueue is InitialiseScanningQueue for the scanner, which can also use the in-process "memory" queue
+// for local runs. It then has the queue to itself, so it must run the scheduler as well. Returns whether it does.
+func InitialiseScannerQueue(options queue.Options) (scanningQueue queue.Queue, inProcess bool, err error) {
+	if backend, _ := cfg.StringSetting("QUEUE_BACKEND"); backend == QUEUE_BACKEND_MEMORY {
+		return queue.NewMemoryQueue(scanningQueueOptions(options)), true, nil
+	}
+	scanningQueue, err = InitialiseScanningQueue(options)
+	return scanningQueue, false, err
+}
+
+// scanningQueueOptions overrides the options with the QUEUE_VISIBILITY_TIMEOUT_SECS and QUEUE_MAX_ATTEMPTS settings.
+func scanningQueueOptions(options queue.Options) queue.Options {
+	// a scan request not acked within the visibility timeout is redelivered, so it must be longer than a scan can take
+	if visibilitySecs, _ := cfg.IntSetting("QUEUE_VISIBILITY_TIMEOUT_SECS"); visibilitySecs > 0 {
+		options.VisibilityTimeout = time.Duration(visibilitySecs) * time.Second
+	}
+	if maxAttempts, _ := cfg.IntSetting("QUEUE_MAX_ATTEMPTS"); maxAttempts > 0 {
+		options.MaxAttempts = maxAttempts
+	}
+	return options
+}
+
+// InitialiseLeaderElection campaigns for the named leadership, with the heartbeat and lease from the
+// LEADER_HEARTBEAT_SECS and LEADER_LEASE_SECS settings. A standby takes over within a heartbeat of the leader
+// exiting, or within a lease and a heartbeat of its host dying.
+func InitialiseLeaderElection(psqlDB *postgres.DB, name string) *leader.Elector {
+	options := leader.Options{}
+	if heartbeatSecs, _ := cfg.IntSetting("LEADER_HEARTBEAT_SECS"); heartbeatSecs > 0 {
+		options.Heartbeat = time.Duration(heartbeatSecs) * time.Second
+	}
+	if leaseSecs, _ := cfg.IntSetting("LEADER_LEASE_SECS"); leaseSecs > 0 {
+		options.Lease = time.Duration(leaseSecs) * time.Second
+	}
+	return leader.NewElector(psqlDB, name, options)
+}
+
+// VerifyRetailerScrapers reports retailers in the database that have no registered scraper to scan them with, and
+// registered scrapers that no retailer row refers to. Neither is fatal, but both usually mean a migration and the
+// scraper registry have got out of step.
+func VerifyRetailerScrapers(vinylDS db.VinylDS) error {
+	rows, err := vinylDS.GetAllRetailers(nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get retailers list")
+	}
+	used := map[string]struct{}{}
+	for _, r := range rows {
+		if !r.Enabled && r.DisabledReason.Valid {
+			log.Infof("Retailer %v '%s' is disabled: %s", r.ID, r.Name, r.DisabledReason.String)
+		}
+		if !r.ScraperKey.Valid || !retailers.IsRegisteredScraper(r.ScraperKey.String) {
+			log.Warnf("Retailer %v '%s' has no registered scraper (scraper_key '%s')", r.ID, r.Name, r.ScraperKey.String)
+			continue
+		}
+		used[r.ScraperKey.String] = struct{}{}
+		// generic scrapers are defined entirely by the config, so a bad definition should be caught here
+		if _, err := retailers.NewVinylRetailer(r.ScraperKey.String, json.RawMessage(r.ScraperConfig)); err != nil {
+			log.Error(err, "Retailer %v '%s' has invalid scraper config", r.ID, r.Name)
+		}
+		if retailers.ConfiguredRobotsPolicy(json.RawMessage(r.ScraperConfig)) == "" {
+			log.Warnf("Retailer %v '%s' doesn't state its robots policy (fetch.robots) so its robots.txt is obeyed", r.ID, r.Name)
+		}
+	}
+	for _, key := range retailers.RegisteredScrapers() {
+		generic := key == retailers.SHOPIFY_SCRAPER_KEY || key == retailers.SELECTOR_SCRAPER_KEY
+		if _, ok := used[key]; !ok && !generic {
+			log.Warnf("Scraper '%s' is registered but no retailer uses it", key)
+		}
+	}
+	return nil
+}
+
+// InitialiseScraperFetching sets the default fetch config (timeouts, retries, rate limits, user agent) for all
+// scrapers from the FETCH_* settings. Retailers can override any of these in their scraper config, and each states
+// whether its robots.txt is obeyed.
+func InitialiseScraperFetching() {
+	fetch := retailers.DefaultFetchConfig()
+	intSetting := func(name string, value *int) {
+		if setting, err := cfg.IntSetting(name); err == nil {
+			*value = setting
+		}
+	}
+	intSetting("FETCH_TIMEOUT_SECS", &fetch.TimeoutSecs)
+	intSetting("FETCH_MAX_RETRIES", &fetch.MaxRetries)
+	intSetting("FETCH_BACKOFF_MILLIS", &fetch.BackoffMillis)
+	intSetting("FETCH_MAX_BACKOFF_SECS", &fetch.MaxBackoffSecs)
+	intSetting("FETCH_INTERVAL_MILLIS", &fetch.RequestIntervalMillis)
+	intSetting("FETCH_MAX_CONCURRENT", &fetch.MaxConcurrent)
+	intSetting("FETCH_MAX_PAGES", &fetch.MaxPages)
+	if userAgent, _ := cfg.StringSetting("FETCH_USER_AGENT"); userAgent != "" {
+		fetch.UserAgent = userAgent
+	}
+	retailers.SetDefaultFetchConfig(fetch)
+	log.Infof("Scraper fetching: %+v", fetch)
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/gavinturner/vinylretailers/cmd"
+	"github.com/gavinturner/vinylretailers/db"
+	_ "github.com/lib/pq"
+	"gopkg.in/guregu/null.v3"
+	"time"
+)
+
+// Lists how often each artist is scanned at each retailer, as the scheduler last adapted it to how often the artist's
+// listings there change, and when each is next due a scan
+// e.g.
+//
+//	go run ./cmd/intervals
+//	go run ./cmd/intervals -retailer 3
+func main() {
+	retailer := flag.Int64("retailer", 0, "only list the intervals at this retailer")
+	flag.Parse()
+
+	psqlDB, err := cmd.InitialiseDbConnection()
+	if err != nil {
+		panic(err)
+	}
+	defer psqlDB.Close()
+	vinylDS := db.NewDB(psqlDB)
+
+	var retailerID *int64
+	if *retailer != 0 {
+		retailerID = retailer
+	}
+	intervals, err := vinylDS.GetScanIntervals(nil, retailerID)
+	if err != nil {
+		panic(err)
+	}
+	config := cmd.LoadScanIntervalConfig()
+	fmt.Printf("Intervals are between %v and %v, from changes over %v and arrivals over %v\n",
+		config.Min, config.Max, config.ChangeWindow, config.ArrivalWindow)
+	for _, i := range intervals {
+		fmt.Printf("%s at %s: every %v - %v changes, %v recent arrivals, last changed %s, next scan %s\n",
+			i.ArtistName, i.RetailerName, time.Duration(i.IntervalSecs)*time.Second, i.Changes, i.RecentArrivals,
+			formatTime(i.LastChangeAt, "never"), formatTime(i.NextScanAt, "on the next run"))
+	}
+	fmt.Printf("%v artist scan intervals\n", len(intervals))
+}
+
+func formatTime(t null.Time, otherwise string) string {
+	if !t.Valid {
+		return otherwise
+	}
+	return t.Time.Format(time.RFC3339)
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"github.com/gavinturner/vinylretailers/cmd"
 	"github.com/gavinturner/vinylretailers/db"
-	"github.com/gavinturner/vinylretailers/retailers"
 	"github.com/gavinturner/vinylretailers/util/email"
 	"github.com/gavinturner/vinylretailers/util/log"
 	_ "github.com/lib/pq"
@@ -19,12 +18,10 @@ const (
 	DELAY_BETWEEN_REPORTING_POLLS_SECS = 10
 )
 
-//
 // reporter.main()
 // Represents the process body of the reporter pod. ...
 // @see scheduler.main()
 // @see scanner.main()
-//
 func main() {
 
 	// sleep on startup to let the infra pods get started up
@@ -137,20 +134,47 @@ func renderResultsRow(image string, artist string, titleUrl string, title string
 	return htmlOut, nil
 }
 
-func buildAndSendEmail(skus []retailers.SKU, userEmail string, userName string) error {
+// renderChange describes why the sku is in the report alongside its price
+func renderChange(sku db.ReportSKU) string {
+	switch sku.Change {
+	case db.SKUChange_Restock:
+		return sku.Price.String() + " - back in stock!"
+	case db.SKUChange_Price:
+		return sku.Price.String() + " - price change"
+	case db.SKUChange_Variants:
+		return sku.Price.String() + " - change to a variant"
+	}
+	return sku.Price.String()
+}
+
+// renderName is the release title along with the pressing, where the listing is a specific one
+func renderName(sku db.ReportSKU) string {
+	if sku.Edition != "" {
+		return fmt.Sprintf("%s (%s)", sku.Name, sku.Edition)
+	}
+	return sku.Name
+}
+
+func buildAndSendEmail(skus []db.ReportSKU, userEmail string, userName string) error {
 	subject := "New vinyl releases found by new engine"
 	message := ""
 
-	artistSkus := map[string][]retailers.SKU{}
-	retailerSkus := map[string][]retailers.SKU{}
+	artistSkus := map[string][]db.ReportSKU{}
+	retailerSkus := map[string][]db.ReportSKU{}
+	restockedSkus := []db.ReportSKU{}
 
 	for _, sku := range skus {
 		log.Debugf("Processing SKU %v for report to %v", sku.Name, userEmail)
+		if sku.Change == db.SKUChange_Restock {
+			// restocks are listed up front, separately from new releases and price changes
+			restockedSkus = append(restockedSkus, sku)
+			continue
+		}
 		if _, ok := artistSkus[sku.Artist]; !ok {
-			artistSkus[sku.Artist] = []retailers.SKU{}
+			artistSkus[sku.Artist] = []db.ReportSKU{}
 		}
 		if _, ok := retailerSkus[sku.Retailer]; !ok {
-			retailerSkus[sku.Retailer] = []retailers.SKU{}
+			retailerSkus[sku.Retailer] = []db.ReportSKU{}
 		}
 		s := artistSkus[sku.Artist]
 		s = append(s, sku)
@@ -160,12 +184,25 @@ func buildAndSendEmail(skus []retailers.SKU, userEmail string, userName string)
 		retailerSkus[sku.Retailer] = s
 	}
 
+	if len(restockedSkus) > 0 {
+		message += "<h4>Back in stock</h4>\n"
+		message += "<table>\n"
+		for _, sku := range restockedSkus {
+			row, err := renderResultsRow(sku.Image, sku.Artist, sku.Url, renderName(sku), renderChange(sku), sku.Retailer, sku.RetailerUrl)
+			if err != nil {
+				return errors.Wrapf(err, "Failed to construct report email message")
+			}
+			message += row
+		}
+		message += "</table>"
+	}
+
 	for artist, skus := range artistSkus {
 		message += "<h4>" + artist + "</h4>\n"
 		message += "<table>\n"
 		for _, sku := range skus {
 			log.Debugf("Processing SKU %v for artist %v, report to %v", sku.Name, artist, userEmail)
-			row, err := renderResultsRow(sku.Image, sku.Artist, sku.Url, sku.Name, sku.Price, sku.Retailer, sku.RetailerUrl)
+			row, err := renderResultsRow(sku.Image, sku.Artist, sku.Url, renderName(sku), renderChange(sku), sku.Retailer, sku.RetailerUrl)
 			if err != nil {
 				return errors.Wrapf(err, "Failed to construct report email message")
 			}
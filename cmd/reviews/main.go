@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/gavinturner/vinylretailers/cmd"
+	"github.com/gavinturner/vinylretailers/db"
+	_ "github.com/lib/pq"
+	"github.com/pkg/errors"
+	"os"
+)
+
+// Works the queue of listings that might be a release we already know about, but weren't similar enough to be
+// matched automatically. With no flags it lists the pending reviews e.g.
+//
+//	go run ./cmd/reviews
+//	go run ./cmd/reviews -accept 12
+//	go run ./cmd/reviews -reject 13
+func main() {
+	accept := flag.Int64("accept", 0, "id of a review to accept, merging the listing's release into the candidate")
+	reject := flag.Int64("reject", 0, "id of a review to reject, keeping the releases apart")
+	flag.Parse()
+
+	psqlDB, err := cmd.InitialiseDbConnection()
+	if err != nil {
+		panic(err)
+	}
+	defer psqlDB.Close()
+	vinylDS := db.NewDB(psqlDB)
+
+	if *accept != 0 || *reject != 0 {
+		tx, err := vinylDS.StartTransaction()
+		if err != nil {
+			panic(err)
+		}
+		if *accept != 0 {
+			err = vinylDS.ResolveReleaseReview(tx, *accept, true)
+		} else {
+			err = vinylDS.ResolveReleaseReview(tx, *reject, false)
+		}
+		if closeErr := vinylDS.CloseTransaction(tx, err); err == nil {
+			err = closeErr
+		}
+		conflict := &db.ReleaseMergeConflict{}
+		if errors.As(err, &conflict) {
+			fmt.Printf("Can't accept review %v: %s, so they're different listings - reject it instead\n", *accept, conflict.Error())
+			os.Exit(1)
+		}
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	reviews, err := vinylDS.GetPendingReleaseReviews(nil)
+	if err != nil {
+		panic(err)
+	}
+	for _, r := range reviews {
+		fmt.Printf("%v: '%s' (release %v) might be '%s' (release %v) - %.2f\n", r.ID, r.ListingTitle, r.ReleaseID.Int64, r.CandidateTitle, r.CandidateReleaseID, r.Score)
+	}
+	fmt.Printf("%v pending reviews\n", len(reviews))
+}
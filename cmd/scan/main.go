@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/gavinturner/vinylretailers/cmd"
+	"github.com/gavinturner/vinylretailers/db"
+	"github.com/gavinturner/vinylretailers/util/queue"
+	_ "github.com/lib/pq"
+)
+
+// Scans the artists a user follows now, rather than waiting for the next scheduled batch. The scan requests go in the
+// queue's priority lane, so they are picked up ahead of any routine batch still being scanned, and the user gets a
+// report of their own once they're done e.g.
+//
+//	go run ./cmd/scan -user 3
+func main() {
+	userID := flag.Int64("user", 0, "id of the user to scan for")
+	flag.Parse()
+	if *userID == 0 {
+		flag.Usage()
+		return
+	}
+
+	psqlDB, err := cmd.InitialiseDbConnection()
+	if err != nil {
+		panic(err)
+	}
+	defer psqlDB.Close()
+	vinylDS := db.NewDB(psqlDB)
+
+	scanningQueue, err := cmd.InitialiseScanningQueue(queue.Options{})
+	if err != nil {
+		panic(err)
+	}
+	defer scanningQueue.Close()
+
+	activeRetailers, err := cmd.ActiveRetailers(&vinylDS)
+	if err != nil {
+		panic(err)
+	}
+	watchedArtists, err := vinylDS.GetWatchedArtists(nil)
+	if err != nil {
+		panic(err)
+	}
+	watched := map[int64][]db.WatchedArtist{*userID: watchedArtists[*userID]}
+	batchID, err := cmd.ScheduleBatch(&vinylDS, scanningQueue, activeRetailers, watched, true, nil)
+	if err != nil {
+		panic(err)
+	}
+	if batchID == 0 {
+		fmt.Printf("User %v doesn't follow any artists\n", *userID)
+		return
+	}
+	fmt.Printf("Scheduled batch %v: %v artists at %v retailers\n", batchID, len(watched[*userID]), len(activeRetailers))
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"github.com/gavinturner/vinylretailers/db"
+	"github.com/gavinturner/vinylretailers/util/cfg"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+	"time"
+)
+
+const (
+	DEFAULT_SCAN_MIN_INTERVAL_SECS   = 30 * 60
+	DEFAULT_SCAN_MAX_INTERVAL_SECS   = 24 * 60 * 60
+	DEFAULT_SCAN_CHANGE_WINDOW_DAYS  = 28
+	DEFAULT_SCAN_ARRIVAL_WINDOW_DAYS = 2
+)
+
+// ScanIntervalConfig bounds how often an artist is scanned at a retailer, and how much of the sku history its
+// interval is adapted from.
+type ScanIntervalConfig struct {
+	Min           time.Duration
+	Max           time.Duration
+	ChangeWindow  time.Duration // history the rate its listings change at is measured over
+	ArrivalWindow time.Duration // how recently a listing must have been new or restocked to scan as often as possible
+}
+
+// ScanPair is an artist at a retailer
+type ScanPair struct {
+	RetailerID int64
+	ArtistID   int64
+}
+
+// LoadScanIntervalConfig reads the SCAN_MIN_INTERVAL_SECS, SCAN_MAX_INTERVAL_SECS, SCAN_CHANGE_WINDOW_DAYS and
+// SCAN_ARRIVAL_WINDOW_DAYS settings. An artist is never scanned more often than the schedule covering the retailer
+// runs, so the minimum is best left at the schedule's period.
+func LoadScanIntervalConfig() ScanIntervalConfig {
+	intSetting := func(name string, value int) int {
+		if setting, _ := cfg.IntSetting(name); setting > 0 {
+			return setting
+		}
+		return value
+	}
+	config := ScanIntervalConfig{
+		Min:           time.Duration(intSetting("SCAN_MIN_INTERVAL_SECS", DEFAULT_SCAN_MIN_INTERVAL_SECS)) * time.Second,
+		Max:           time.Duration(intSetting("SCAN_MAX_INTERVAL_SECS", DEFAULT_SCAN_MAX_INTERVAL_SECS)) * time.Second,
+		ChangeWindow:  time.Duration(intSetting("SCAN_CHANGE_WINDOW_DAYS", DEFAULT_SCAN_CHANGE_WINDOW_DAYS)) * 24 * time.Hour,
+		ArrivalWindow: time.Duration(intSetting("SCAN_ARRIVAL_WINDOW_DAYS", DEFAULT_SCAN_ARRIVAL_WINDOW_DAYS)) * 24 * time.Hour,
+	}
+	if config.Max < config.Min {
+		config.Max = config.Min
+	}
+	return config
+}
+
+// AdaptedScanInterval is how often an artist should be scanned at a retailer given how its listings there have
+// changed: as often as possible if something was newly listed or restocked recently, as seldom as possible if nothing
+// has changed, and otherwise twice for each change in the change window (so a change is seen within half the time
+// changes usually take), within the bounds.
+func AdaptedScanInterval(config ScanIntervalConfig, changes db.ListingChanges) time.Duration {
+	if changes.RecentArrivals > 0 {
+		return config.Min
+	} else if changes.Changes == 0 {
+		return config.Max
+	}
+	interval := config.ChangeWindow / time.Duration(2*changes.Changes)
+	if interval < config.Min {
+		return config.Min
+	} else if interval > config.Max {
+		return config.Max
+	}
+	return interval
+}
+
+// AdaptScanIntervals works out the scan interval of each watched artist at each of the retailers from the sku
+// history, keeping when each was last scheduled.
+func AdaptScanIntervals(vinylDS db.VinylDS, config ScanIntervalConfig, retailers []db.Retailer, watchedArtists map[int64][]db.WatchedArtist, now time.Time) (map[ScanPair]db.ScanInterval, error) {
+	existing, err := vinylDS.GetScanIntervals(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	scheduled := map[ScanPair]null.Time{}
+	for _, i := range existing {
+		scheduled[ScanPair{RetailerID: i.RetailerID, ArtistID: i.ArtistID}] = i.LastScheduledAt
+	}
+	listingChanges, err := vinylDS.GetListingChanges(nil, now.Add(-config.ChangeWindow), now.Add(-config.ArrivalWindow))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get listing changes")
+	}
+	changes := map[ScanPair]db.ListingChanges{}
+	for _, c := range listingChanges {
+		changes[ScanPair{RetailerID: c.RetailerID, ArtistID: c.ArtistID}] = c
+	}
+
+	intervals := map[ScanPair]db.ScanInterval{}
+	for _, retailer := range retailers {
+		for _, watches := range watchedArtists {
+			for _, watch := range watches {
+				pair := ScanPair{RetailerID: retailer.ID, ArtistID: watch.ArtistID}
+				c := changes[pair]
+				interval := AdaptedScanInterval(config, c)
+				i := db.ScanInterval{
+					ArtistID:        watch.ArtistID,
+					RetailerID:      retailer.ID,
+					IntervalSecs:    int(interval.Seconds()),
+					Changes:         c.Changes,
+					RecentArrivals:  c.RecentArrivals,
+					LastChangeAt:    c.LastChangeAt,
+					LastScheduledAt: scheduled[pair],
+				}
+				if i.LastScheduledAt.Valid {
+					i.NextScanAt = null.TimeFrom(i.LastScheduledAt.Time.Add(interval))
+				}
+				intervals[pair] = i
+			}
+		}
+	}
+	return intervals, nil
+}
+
+// ScanDue returns true if the artist is due to be scanned at the retailer: it never has been, or its interval has
+// (nearly) passed since it last was. It's due a little early rather than waiting for the schedule's next run.
+func ScanDue(interval db.ScanInterval, now time.Time) bool {
+	if !interval.NextScanAt.Valid {
+		return true
+	}
+	early := time.Duration(interval.IntervalSecs) * time.Second / 10
+	return !now.Before(interval.NextScanAt.Time.Add(-early))
+}
@@ -1,16 +1,26 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/gavinturner/vinylretailers/cmd"
 	"github.com/gavinturner/vinylretailers/db"
 	"github.com/gavinturner/vinylretailers/retailers"
+	"github.com/gavinturner/vinylretailers/util/cfg"
 	"github.com/gavinturner/vinylretailers/util/log"
+	"github.com/gavinturner/vinylretailers/util/postgres"
+	"github.com/gavinturner/vinylretailers/util/queue"
 	"github.com/gavinturner/vinylretailers/util/redis"
 	_ "github.com/lib/pq"
 	"golang.org/x/sync/errgroup"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 
 	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
 	"strings"
 	"time"
 )
@@ -18,18 +28,58 @@ import (
 const (
 	STARTUP_DELAY_SECS     = 10
 	DBSTARTUP_TIMEOUT_SECS = 30
-	SOLD_OUT               = "sold out"
+	DEQUEUE_BACKOFF        = time.Second      // wait after failing to read the queue, doubling while it keeps failing
+	DEQUEUE_MAX_BACKOFF    = 30 * time.Second // longest wait between attempts to read the queue
+
+	DEFAULT_SCAN_WORKERS                = 4
+	DEFAULT_SCAN_TIMEOUT_SECS           = 300
+	DEFAULT_SCAN_RETRY_BACKOFF_SECS     = 30
+	DEFAULT_SCAN_MAX_RETRY_BACKOFF_SECS = 600
+	DEFAULT_SCAN_DELIST_AFTER_MISSES    = 3
+	DEFAULT_SCAN_MIN_RESULTS_PERCENT    = 50
 )
 
-//
+// scanConfig controls how the scanner runs each scan request.
+type scanConfig struct {
+	workers           int           // scan requests run at once
+	timeout           time.Duration // a scan running longer is abandoned and requeued
+	retryBackoff      time.Duration // how long a failed scan waits to be retried, doubling with each attempt
+	maxRetryBackoff   time.Duration // the longest a failed scan waits to be retried
+	delistAfterMisses int           // successful scans in a row a listing must be missing from to be marked delisted
+	minResultsPercent int           // scans finding less than this % of the known listings don't count listings as missing
+
+	health cmd.RetailerHealthConfig
+}
+
+func loadScanConfig() scanConfig {
+	intSetting := func(name string, value int) int {
+		if setting, _ := cfg.IntSetting(name); setting > 0 {
+			return setting
+		}
+		return value
+	}
+	return scanConfig{
+		workers:           intSetting("SCAN_WORKERS", DEFAULT_SCAN_WORKERS),
+		timeout:           time.Duration(intSetting("SCAN_TIMEOUT_SECS", DEFAULT_SCAN_TIMEOUT_SECS)) * time.Second,
+		retryBackoff:      time.Duration(intSetting("SCAN_RETRY_BACKOFF_SECS", DEFAULT_SCAN_RETRY_BACKOFF_SECS)) * time.Second,
+		maxRetryBackoff:   time.Duration(intSetting("SCAN_MAX_RETRY_BACKOFF_SECS", DEFAULT_SCAN_MAX_RETRY_BACKOFF_SECS)) * time.Second,
+		delistAfterMisses: intSetting("SCAN_DELIST_AFTER_MISSES", DEFAULT_SCAN_DELIST_AFTER_MISSES),
+		minResultsPercent: intSetting("SCAN_MIN_RESULTS_PERCENT", DEFAULT_SCAN_MIN_RESULTS_PERCENT),
+
+		health: cmd.LoadRetailerHealthConfig(),
+	}
+}
+
 // scanner.main()
 // Represents the process body of the scanner pod. A single scanner pod essentially listens on the redis scanner
 // queue waiting for new scan requests. Each scan request is for an artist + retailer and is for a specific batch.
 // The scanner takes this request and runs the appropriate retail scraper for the nominated retailer, seeding with
 // the nominated artist. Any new/updated releases found are stored as release SKUs in the database and attached to
-// any open batch reports that include that artist (typically one report per watching user).
+// any open batch reports that include that artist (typically one report per watching user). A pool of workers
+// (SCAN_WORKERS) takes requests off the queue, and on SIGTERM they stop taking them and finish what they're scanning.
+// With QUEUE_BACKEND=memory the queue is in-process and the scanner runs the scheduler as well, so that a single
+// process (and no redis) is all a local run needs.
 // @see scheduler.main()
-//
 func main() {
 
 	// sleep on startup to let the infra pods get started
@@ -43,16 +93,14 @@ func main() {
 	defer psqlDB.Close()
 	vinylDS := db.NewDB(psqlDB)
 
-	// use the redis config to initialise a connection to the redis scanning queue
-	scanningQueue, err := cmd.InitialiseRedisScanningQueue()
+	// use the redis config to initialise a connection to the redis scanning queue. a request whose last attempt expires
+	// unacked (e.g. its scanner died) is given up on just as if it had been nacked
+	config := loadScanConfig()
+	scanningQueue, inProcess, err := cmd.InitialiseScannerQueue(queue.Options{ExpiredDeadLetter: settleExpiredScanRequest(&vinylDS, config)})
 	if err != nil {
 		panic(err)
 	}
 	defer scanningQueue.Close()
-	_, err = scanningQueue.PingRedis()
-	if err != nil {
-		panic(err)
-	}
 
 	// make sure that the db is up. keep trying every second until it is
 	log.Debugf("Retail vinyl scanner starts..")
@@ -60,44 +108,286 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	err = cmd.VerifyRetailerScrapers(&vinylDS)
+	if err != nil {
+		panic(err)
+	}
+	cmd.InitialiseScraperFetching()
+
+	// SIGTERM (or ^C) stops the workers taking new requests. scans already running are left to finish (so the pod's
+	// termination grace period should be longer than SCAN_TIMEOUT_SECS), and any that don't are redelivered once
+	// their visibility timeout passes
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
 
 	//
-	// check for requests on the scan queue (blocking) and action when found by calling the correct scraper
-	// for the nominated retailer. Any SKUs found are compared with what we already know for the associated release
-	// and the SKU is updated if we have new information (price / availability)
+	// each worker checks for requests on the scan queue (blocking) and actions them when found by calling the correct
+	// scraper for the nominated retailer. Any SKUs found are compared with what we already know for the associated
+	// release and the SKU is updated if we have new information (price / availability)
 	//
 
+	log.Infof("Starting %v scan workers", config.workers)
+	wg := sync.WaitGroup{}
+	if inProcess {
+		// nothing else can queue to an in-process queue, so for local runs the scanner is the scheduler as well
+		log.Infof("Scanning queue is in-process, so running the scheduler too")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cmd.RunScheduler(ctx, psqlDB, &vinylDS, scanningQueue, cmd.LoadSchedulerConfig())
+		}()
+	}
+	for worker := 1; worker <= config.workers; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			runScanWorker(ctx, worker, &vinylDS, scanningQueue, config)
+		}(worker)
+	}
+	<-ctx.Done()
+	log.Infof("Retail scanner stopping, waiting for scans in progress to finish..")
+	wg.Wait()
+	log.Debugf("Retail Scanner terminating..")
+}
+
+// runScanWorker runs scan requests from the queue until the context is cancelled, finishing the scan it's running
+// first. Failing to read the queue (redis or the database being briefly unavailable) is retried after a backoff.
+func runScanWorker(ctx context.Context, worker int, vinylDS db.VinylDS, scanningQueue queue.Queue, config scanConfig) {
+	backoff := time.Duration(0)
 	for {
 		// grab the next request
 		payload := redis.ScanRequest{}
-		found, err := scanningQueue.Dequeue(&payload, true)
+		delivery, err := scanningQueue.Dequeue(ctx, &payload, true)
+		if err != nil && ctx.Err() != nil {
+			return
+		}
+		if err != nil || delivery == nil {
+			if err == nil {
+				err = fmt.Errorf("blocking dequeue returned without a request")
+			}
+			backoff *= 2
+			if backoff == 0 {
+				backoff = DEQUEUE_BACKOFF
+			} else if backoff > DEQUEUE_MAX_BACKOFF {
+				backoff = DEQUEUE_MAX_BACKOFF
+			}
+			log.Error(err, "Scan worker %v failed to dequeue a request, retrying in %v", worker, backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			continue
+		}
+		backoff = 0
+		runScan(vinylDS, scanningQueue, delivery, &payload, config)
+	}
+}
+
+// runScan scans the artist at the retailer for a scan request, then settles the request by how the scan went. One
+// that succeeded is acked. One that failed in a way that may not happen next time (a timeout, the retailer erroring)
+// is requeued to be retried after a backoff, and one that won't ever succeed (or has run out of attempts) is given up
+// on. Either way a request that's done with counts towards its batch, so the batch still finishes.
+func runScan(vinylDS db.VinylDS, scanningQueue queue.Queue, delivery *queue.Delivery, payload *redis.ScanRequest, config scanConfig) {
+	// a scan (the artist and all its variants) that runs too long is abandoned and retried later, so that
+	// one slow or hanging retailer can't hold up the queue
+	ctx, cancel := context.WithTimeout(context.Background(), config.timeout)
+	started := time.Now()
+	result, batchCompleted, err := scrapeArtistForRetailer(ctx, vinylDS, payload, config)
+	cancel()
+
+	outcome := db.ScanOutcome_Succeeded
+	if err == nil {
+		// the scan's transaction (which counted the search as completed) has committed, so it's done. if the scanner
+		// dies before this the request is redelivered once its visibility timeout passes
+		if err := scanningQueue.Ack(delivery); err != nil {
+			log.Error(err, "Failed to ack '%s' for '%s'", payload.RetailerName, payload.ArtistName)
+		}
+	} else if isPermanentFailure(err) {
+		// e.g. the retailer's markup has changed, or its robots.txt disallows the scraper. the scraper or the
+		// retailer's config needs fixing, but the other retailers can still be scanned
+		log.Error(err, "Giving up scraping '%s' for '%s'", payload.RetailerName, payload.ArtistName)
+		outcome, batchCompleted = failScanRequest(vinylDS, scanningQueue, delivery, payload, config)
+	} else {
+		log.Warnf("Failed scraping '%s' for '%s' (attempt %v), retrying: %s", payload.RetailerName, payload.ArtistName, delivery.Attempt, err.Error())
+		outcome, batchCompleted = retryScanRequest(vinylDS, scanningQueue, delivery, payload, config)
+	}
+	recordScan(vinylDS, payload, delivery.Attempt, started, result, err, outcome)
+	if batchCompleted {
+		checkRetailerHealth(vinylDS, payload.BatchID, config)
+	}
+}
+
+// permanentError is a scan failure that retrying won't fix
+type permanentError struct {
+	error
+}
+
+func (e permanentError) Unwrap() error {
+	return e.error
+}
+
+func isPermanentFailure(err error) bool {
+	var permanent permanentError
+	return retailers.IsParseError(err) || retailers.IsDisallowedByRobots(err) || errors.As(err, &permanent)
+}
+
+// retryScanRequest requeues a failed scan request to be retried after a backoff that doubles with each attempt, or
+// gives up on it if it has run out of attempts. Returns whether giving up on it completed its batch.
+func retryScanRequest(vinylDS db.VinylDS, scanningQueue queue.Queue, delivery *queue.Delivery, payload *redis.ScanRequest, config scanConfig) (db.ScanOutcome, bool) {
+	backoff := config.retryBackoff << (delivery.Attempt - 1)
+	if backoff <= 0 || backoff > config.maxRetryBackoff {
+		backoff = config.maxRetryBackoff
+	}
+	deadLettered, err := scanningQueue.Nack(delivery, backoff)
+	if err != nil {
+		// it's redelivered once its visibility timeout passes anyway
+		log.Error(err, "Failed to requeue '%s' for '%s'", payload.RetailerName, payload.ArtistName)
+		return db.ScanOutcome_Retrying, false
+	}
+	if deadLettered {
+		log.Error(fmt.Errorf("scan request dead lettered"), "Giving up scraping '%s' for '%s' after %v attempts", payload.RetailerName, payload.ArtistName, delivery.Attempt)
+		batchCompleted, err := countFailedSearch(vinylDS, payload)
 		if err != nil {
-			log.Error(err, "Failed to dequeue request")
-			break
-		} else if !found {
-			log.Error(err, "Failed to block on request dequeue?")
-			break
+			log.Error(err, "Failed to count failed search of '%s' for '%s' - batch %v won't finish", payload.RetailerName, payload.ArtistName, payload.BatchID)
 		}
-		err = scrapeArtistForRetailer(&vinylDS, &payload)
+		return db.ScanOutcome_Failed, batchCompleted
+	}
+	err = vinylDS.IncrementBatchSearchRetriedCount(nil, payload.BatchID)
+	if err != nil {
+		log.Error(err, "Failed to count retry of '%s' for '%s'", payload.RetailerName, payload.ArtistName)
+	}
+	return db.ScanOutcome_Retrying, false
+}
+
+// failScanRequest gives up on a scan request that won't ever succeed, counting it as a failed search of its batch.
+// If it can't be counted the request is retried instead, as the batch can't finish without it. Returns whether
+// counting it completed its batch.
+func failScanRequest(vinylDS db.VinylDS, scanningQueue queue.Queue, delivery *queue.Delivery, payload *redis.ScanRequest, config scanConfig) (db.ScanOutcome, bool) {
+	batchCompleted, err := countFailedSearch(vinylDS, payload)
+	if err != nil {
+		log.Error(err, "Failed to count failed search of '%s' for '%s'", payload.RetailerName, payload.ArtistName)
+		return retryScanRequest(vinylDS, scanningQueue, delivery, payload, config)
+	}
+	if err := scanningQueue.Ack(delivery); err != nil {
+		log.Error(err, "Failed to ack '%s' for '%s'", payload.RetailerName, payload.ArtistName)
+	}
+	return db.ScanOutcome_Failed, batchCompleted
+}
+
+// settleExpiredScanRequest returns the queue's hook for a scan request dead lettered because its last attempt wasn't
+// acked in time, which counts it as a failed search of its batch so the batch can still finish.
+func settleExpiredScanRequest(vinylDS db.VinylDS, config scanConfig) func(json.RawMessage) {
+	return func(data json.RawMessage) {
+		payload := redis.ScanRequest{}
+		if err := json.Unmarshal(data, &payload); err != nil {
+			log.Error(err, "Failed to unmarshal expired scan request")
+			return
+		}
+		log.Error(fmt.Errorf("scan request dead lettered"), "Giving up scraping '%s' for '%s' after its last attempt expired", payload.RetailerName, payload.ArtistName)
+		batchCompleted, err := countFailedSearch(vinylDS, &payload)
 		if err != nil {
-			log.Panic(err, "Failed to scrape '%s' for '%s'", payload.RetailerName, payload.ArtistName)
+			log.Error(err, "Failed to count failed search of '%s' for '%s' - batch %v won't finish", payload.RetailerName, payload.ArtistName, payload.BatchID)
+		} else if batchCompleted {
+			checkRetailerHealth(vinylDS, payload.BatchID, config)
 		}
 	}
-	log.Debugf("Retail Scanner terminating..")
 }
 
-func scrapeArtistForRetailer(vinylDS db.VinylDS, payload *redis.ScanRequest) error {
+// countFailedSearch counts a scan request that has been given up on as a failed search of its batch, and clears its
+// outstanding scan so the scheduler can queue the scan again. A request that was already counted (and has been
+// redelivered since) isn't counted again. Returns whether counting it completed its batch.
+func countFailedSearch(vinylDS db.VinylDS, payload *redis.ScanRequest) (batchCompleted bool, err error) {
+	tx, err := vinylDS.StartTransaction()
+	if err != nil {
+		return false, errors.Wrapf(err, "Failed to start transaction")
+	}
+	defer func() {
+		if closeErr := vinylDS.CloseTransaction(tx, err); err == nil && closeErr != nil {
+			batchCompleted, err = false, errors.Wrapf(closeErr, "Failed to commit failed search")
+		}
+	}()
+	cleared, err := vinylDS.ClearOutstandingScan(tx, payload.BatchID, payload.ArtistID, payload.RetailerID)
+	if err != nil {
+		return false, err
+	}
+	if !cleared {
+		log.Warnf("Failed search of '%s' for '%s' was already done with for batch %v (or taken over by a later batch), so isn't counted again",
+			payload.RetailerName, payload.ArtistName, payload.BatchID)
+		return false, nil
+	}
+	return vinylDS.IncrementBatchSearchFailedCount(tx, payload.BatchID)
+}
 
-	// get the scraper implementation for the nominated retailer
-	retailerScraper, err := retailers.VinylRetailerFactory(retailers.RetailerID(payload.RetailerID))
+// recordScan writes the outcome of an attempt at a scan request to the scans table. Failing to is logged rather than
+// failing the scan.
+func recordScan(vinylDS db.VinylDS, payload *redis.ScanRequest, attempt int, started time.Time, result retailers.ScrapeResult, err error, outcome db.ScanOutcome) {
+	scan := db.Scan{
+		BatchID:      payload.BatchID,
+		RetailerID:   payload.RetailerID,
+		ArtistID:     payload.ArtistID,
+		Attempt:      attempt,
+		Status:       db.ScanStatus_Succeeded,
+		Outcome:      outcome,
+		StartedAt:    started,
+		DurationMS:   time.Since(started).Milliseconds(),
+		ResultCount:  len(result.SKUs),
+		PagesFetched: result.PagesFetched,
+	}
+	if err != nil {
+		switch {
+		case retailers.IsDeadlineExceeded(err):
+			scan.Status = db.ScanStatus_TimedOut
+		case retailers.IsParseError(err):
+			scan.Status = db.ScanStatus_ParseError
+		case retailers.IsDisallowedByRobots(err):
+			scan.Status = db.ScanStatus_Disallowed
+		default:
+			scan.Status = db.ScanStatus_Failed
+		}
+		scan.Error = null.StringFrom(err.Error())
+	}
+	if err := vinylDS.AddScan(nil, &scan); err != nil {
+		log.Error(err, "Failed to record scan of '%s' for '%s'", payload.RetailerName, payload.ArtistName)
+	}
+}
+
+// checkRetailerHealth flags the retailers that look broken, as their scans over the health window are failing, or
+// finding much less for the same artists, than they were. It's checked as each batch completes, so a change to a
+// retailer's markup is flagged without waiting for the scheduler to stop scanning it.
+func checkRetailerHealth(vinylDS db.VinylDS, batchID int64, config scanConfig) {
+	health, err := vinylDS.GetRetailerHealth(nil, nil, config.health.Window, config.health.Baseline, time.Now())
+	if err != nil {
+		log.Error(err, "Failed to check retailer health as batch %v completed", batchID)
+		return
+	}
+	for _, h := range health {
+		if problem := h.Problem(config.health.MinScans, config.health.DropPercent); problem != "" {
+			log.Error(errors.New(problem), "Retailer '%s' looks broken as batch %v completes", h.RetailerName, batchID)
+		}
+	}
+}
+
+func scrapeArtistForRetailer(ctx context.Context, vinylDS db.VinylDS, payload *redis.ScanRequest, config scanConfig) (merged retailers.ScrapeResult, batchCompleted bool, err error) {
+
+	// get the scraper implementation registered for the nominated retailer
+	retailer, err := vinylDS.GetRetailer(nil, payload.RetailerID)
+	if err != nil {
+		return merged, false, errors.Wrapf(err, "could not retrieve retailer (%v) %s", payload.RetailerID, payload.RetailerName)
+	}
+	if retailer == nil || !retailer.ScraperKey.Valid {
+		return merged, false, permanentError{fmt.Errorf("no scraper configured for retailer (%v) %s", payload.RetailerID, payload.RetailerName)}
+	}
+	retailerScraper, err := retailers.NewVinylRetailer(retailer.ScraperKey.String, json.RawMessage(retailer.ScraperConfig))
 	if err != nil {
-		return errors.Wrapf(err, "could not determine scraper for retailer (%v) %s", payload.RetailerID, payload.RetailerName)
+		return merged, false, permanentError{errors.Wrapf(err, "could not determine scraper for retailer (%v) %s", payload.RetailerID, payload.RetailerName)}
 	}
 
-	errGrp := errgroup.Group{}
+	// the first scrape to fail cancels the others, as the scan fails anyway
+	errGrp, ctx := errgroup.WithContext(ctx)
 	mutex := sync.Mutex{}
-	var releases []retailers.SKU
-	variants := make([][]retailers.SKU, len(payload.ArtistVariants))
+	var found retailers.ScrapeResult
+	variants := make([]retailers.ScrapeResult, len(payload.ArtistVariants))
 
 	//
 	// scrape artist name and all variants..
@@ -106,7 +396,7 @@ func scrapeArtistForRetailer(vinylDS db.VinylDS, payload *redis.ScanRequest) err
 	errGrp.Go(func() error {
 		// scrape for available releases
 		var err error
-		releases, err = retailerScraper.ScrapeArtistReleases(strings.TrimSpace(strings.ToLower(payload.ArtistName)))
+		found, err = retailers.ScrapeArtist(ctx, retailerScraper, retailer.ScraperKey.String, strings.TrimSpace(strings.ToLower(payload.ArtistName)))
 		if err != nil {
 			return errors.Wrapf(err, "Failed to scrape '%s'", payload.ArtistName)
 		}
@@ -114,9 +404,9 @@ func scrapeArtistForRetailer(vinylDS db.VinylDS, payload *redis.ScanRequest) err
 	})
 
 	for idx, variant := range payload.ArtistVariants {
+		idx, variant := idx, variant
 		errGrp.Go(func() error {
-			idx := idx
-			variantReleases, err := retailerScraper.ScrapeArtistReleases(strings.TrimSpace(strings.ToLower(variant)))
+			variantReleases, err := retailers.ScrapeArtist(ctx, retailerScraper, retailer.ScraperKey.String, strings.TrimSpace(strings.ToLower(variant)))
 			if err != nil {
 				return errors.Wrapf(err, "Failed to scrape variant '%s'", variant)
 			}
@@ -129,11 +419,14 @@ func scrapeArtistForRetailer(vinylDS db.VinylDS, payload *redis.ScanRequest) err
 
 	if err := errGrp.Wait(); err != nil {
 		if err != nil {
-			return errors.Wrapf(err, "Failed to scrape '%s' for '%s'", payload.RetailerName, payload.ArtistName)
+			return merged, false, errors.Wrapf(err, "Failed to scrape '%s' for '%s'", payload.RetailerName, payload.ArtistName)
 		}
 	}
-
-	// TODO: handle merging the results into the releases list (when we find dupes using variants)
+	// the same listing is usually found under more than one of the artist's names, so merge the results keeping
+	// the listing from the artist's main name where it was found by that too
+	merged = retailers.MergeResults(append([]retailers.ScrapeResult{found}, variants...)...)
+	releases := merged.SKUs
+	log.Infof("%s@%s: scraped %v releases from %v pages of results", payload.ArtistName, payload.RetailerName, len(releases), merged.PagesFetched)
 
 	//
 	// Upsert all the SKUs that we scraped.
@@ -142,87 +435,175 @@ func scrapeArtistForRetailer(vinylDS db.VinylDS, payload *redis.ScanRequest) err
 	persistedSkus := []db.SKU{}
 	tx, err := vinylDS.StartTransaction()
 	if err != nil {
-		return errors.Wrapf(err, "Failed to start transaction")
+		return merged, false, errors.Wrapf(err, "Failed to start transaction")
+	}
+	// the transaction is committed before the request is acked, so a scan that fails to commit is retried
+	defer func() {
+		if closeErr := vinylDS.CloseTransaction(tx, err); err == nil && closeErr != nil {
+			batchCompleted, err = false, errors.Wrapf(closeErr, "Failed to commit scan")
+		}
+	}()
+
+	// the scan is no longer outstanding. a request redelivered after it was done with (the scanner died before acking
+	// it, or it ran past its visibility timeout and both deliveries ran) has already been counted, so only the
+	// delivery that clears it counts towards the batch and counts listings as missing. clearing it first holds the row
+	// until the transaction ends, so a delivery running at the same time waits to find it cleared
+	var cleared bool
+	cleared, err = vinylDS.ClearOutstandingScan(tx, payload.BatchID, payload.ArtistID, payload.RetailerID)
+	if err != nil {
+		return merged, false, err
 	}
-	defer vinylDS.CloseTransaction(tx, err)
 
 	for _, release := range releases {
-		// upsert the release to create if we haven't seen it before. otherwise get release id
-		var releaseID int64
-		releaseID, err = vinylDS.UpsertRelease(tx, payload.ArtistID, release.Name)
+		// match the listing to the release it's a listing of (however the retailer titles it), creating the
+		// release if we haven't seen it before. the listing's link, then barcodes and catalogue numbers are matched first
+		var match db.ReleaseMatch
+		barcodes, catalogueNos := release.Codes()
+		match, err = vinylDS.MatchRelease(tx, payload.ArtistID, payload.RetailerID, release.Name, release.Url, barcodes, catalogueNos)
 		if err != nil {
-			return errors.Wrapf(err, "Failed to create new release '%s' for artist '%s'", release.Name, payload.ArtistName)
+			return merged, false, errors.Wrapf(err, "Failed to match release '%s' for artist '%s'", release.Name, payload.ArtistName)
+		}
+		releaseID := match.ReleaseID
+		if match.Code != "" {
+			log.Debugf("%s@%s: '%s' matched release %v by %s", payload.ArtistName, payload.RetailerName, release.Name, releaseID, match.Code)
+		}
+		if match.Review != nil {
+			log.Infof("%s@%s: '%s' might be release %v (%.2f) - queued for review", payload.ArtistName, payload.RetailerName, release.Name, match.Review.CandidateReleaseID, match.Score)
 		}
 		sku := db.SKU{
-			ReleaseID:  releaseID,
-			RetailerID: payload.RetailerID,
-			ArtistID:   payload.ArtistID,
-			ItemUrl:    release.Url,
-			ImageUrl:   release.Image,
-			Price:      release.Price,
+			ReleaseID:   releaseID,
+			RetailerID:  payload.RetailerID,
+			ArtistID:    payload.ArtistID,
+			ItemUrl:     release.Url,
+			ImageUrl:    release.Image,
+			Price:       release.Price,
+			SearchTerm:  release.SearchTerm,
+			Format:      release.Format,
+			Edition:     release.Edition,
+			Variants:    release.Variants,
+			Barcode:     release.Barcode,
+			CatalogueNo: release.CatalogueNo,
 		}
 		// upsert a new SKU for the release. A new SKU record will be created if the price/availability
 		// of the release has changed (as compared to the most recent existing SKU for the release)
-		var same bool
-		same, err = vinylDS.UpsertSKU(tx, &sku)
+		var change db.SKUChange
+		change, err = vinylDS.UpsertSKU(tx, &sku)
 		if err != nil {
-			return errors.Wrapf(err, "Failed to upsert new price for '%s' ", release.Name)
+			return merged, false, errors.Wrapf(err, "Failed to upsert new price for '%s' ", release.Name)
 		}
 
-		// if the sku is available and the price has changed, then it's a candidate for adding to one
-		// or more user reports for the current batch
-		if !same && sku.Price != SOLD_OUT {
-			log.Debugf("%s@%s: Found new release state: %s = %s (%v)", payload.ArtistName, payload.RetailerName, release.Name, sku.Price, sku.ID)
-			err = vinylDS.AddSKUToReportsForBatch(tx, payload.BatchID, &sku)
+		// if the sku is available and the price/availability has changed, then it's a candidate for adding to one
+		// or more user reports for the current batch. restocks are reported as such so reports can call them out
+		switch change {
+		case db.SKUChange_None:
+			log.Debugf("%s@%s: releases [%v, %s] has not changed", payload.ArtistName, payload.RetailerName, releaseID, release.Name)
+		case db.SKUChange_SoldOut, db.SKUChange_Delisted:
+			log.Debugf("%s@%s: Found new (sold out) release state: %s = %s (%v)", payload.ArtistName, payload.RetailerName, release.Name, sku.Price, sku.ID)
+		default:
+			if !sku.Price.IsAvailable() {
+				log.Debugf("%s@%s: Found new (sold out) release state: %s = %s (%v)", payload.ArtistName, payload.RetailerName, release.Name, sku.Price, sku.ID)
+				break
+			}
+			log.Debugf("%s@%s: Found new release state (%s): %s = %s (%v)", payload.ArtistName, payload.RetailerName, change, release.Name, sku.Price, sku.ID)
+			err = vinylDS.AddSKUToReportsForBatch(tx, payload.BatchID, &sku, change)
 			if err != nil {
-				return errors.Wrapf(err, "Failed to upsert new price for '%s' ", release.Name)
+				return merged, false, errors.Wrapf(err, "Failed to upsert new price for '%s' ", release.Name)
 			}
-		} else if !same && sku.Price == SOLD_OUT {
-			log.Debugf("%s@%s: Found new (sold out) release state: %s = %s (%v)", payload.ArtistName, payload.RetailerName, release.Name, sku.Price, sku.ID)
-		} else {
-			log.Debugf("%s@%s: releases [%v, %s] has not changed", payload.ArtistName, payload.RetailerName, releaseID, release.Name)
 		}
 		persistedSkus = append(persistedSkus, sku)
 	}
-	/*
-		//
-		// any skus that were not found for the retailer / artist are now not available - they should be marked as
-		// SOLD OUT.
-		//
 
-		var existingSKUs []db.SKU
-		existingSKUs, err = vinylDS.GetAllSKUs(tx, &payload.ArtistID, &payload.RetailerID)
-		if err != nil {
-			return errors.Wrapf(err, "Failed to get existing skus for retailer %s and artist %s", payload.RetailerName, payload.ArtistName)
-		}
-		persistedSkusIdx := map[int64]struct{}{}
-		for _, s := range persistedSkus {
-			persistedSkusIdx[s.ID] = struct{}{}
-		}
-		missingSKUs := []db.SKU{}
-		for _, s := range existingSKUs {
-			if _, ok := persistedSkusIdx[s.ID]; !ok {
-				missingSKUs = append(missingSKUs, s)
-			}
-		}
-		for _, s := range missingSKUs {
-			s.Price = SOLD_OUT
-			err = vinylDS.UpdateSKU(tx, &s)
-			if err != nil {
-				return errors.Wrapf(err, "Failed to set missing SKU %v to SOLD OUT", s.ID)
-			}
-			log.Debugf("%s@%s: releases [%v:%v] not found - setting to SOLD OUT", payload.ArtistName, payload.RetailerName, s.ID, s.ReleaseID)
-		}
-	*/
+	//
+	// listings we've seen before that the retailer no longer lists have sold out (some stores only list what's in
+	// stock) or been taken down.
+	//
+
+	if !cleared {
+		log.Warnf("Scan of '%s' for '%s' was already done with for batch %v (or taken over by a later batch), so isn't counted again",
+			payload.RetailerName, payload.ArtistName, payload.BatchID)
+		return merged, false, nil
+	}
+	err = markMissingSKUs(vinylDS, tx, payload, persistedSkus, merged.ProductsSkipped, config)
+	if err != nil {
+		return merged, false, errors.Wrapf(err, "Failed to mark missing skus for retailer %s and artist %s", payload.RetailerName, payload.ArtistName)
+	}
 
 	//
-	// now that we've finished processing the artist + retailer, we can increment the number of required
-	// searches for the current batch (so we know when the batch is done)
+	// now that we've finished processing the artist + retailer we can increment the number of completed searches
+	// for the current batch (so we know when the batch is done)
 	//
 
-	err = vinylDS.IncrementBatchSearchCompletedCount(tx, payload.BatchID)
+	batchCompleted, err = vinylDS.IncrementBatchSearchCompletedCount(tx, payload.BatchID)
+	if err != nil {
+		return merged, false, errors.Wrapf(err, "Failed to increment search count for batch %v ", payload.BatchID)
+	}
+	return merged, batchCompleted, nil
+}
+
+// markMissingSKUs counts a miss against each listing the scan didn't find, and marks the listing delisted (as a new
+// sku) once it has been missing from enough scans in a row. Searches can be flaky, so one miss isn't enough, and a
+// scan that found suspiciously few of the listings we know about, or skipped products it couldn't read, isn't trusted
+// to say what's missing at all. Failed scans never get this far, so they don't count either.
+func markMissingSKUs(vinylDS db.VinylDS, tx *postgres.Tx, payload *redis.ScanRequest, found []db.SKU, productsSkipped int, config scanConfig) error {
+	current, err := vinylDS.GetCurrentSKUs(tx, payload.ArtistID, payload.RetailerID)
 	if err != nil {
-		return errors.Wrapf(err, "Failed to increment search count for batch %v ", payload.BatchID)
+		return err
+	}
+	foundIdx := map[int64]struct{}{}
+	for _, s := range found {
+		foundIdx[s.ReleaseID] = struct{}{}
+	}
+	listed := 0
+	missing := []db.SKU{}
+	for _, s := range current {
+		if s.Price.Availability == retailers.Availability_Delisted {
+			continue
+		}
+		listed++
+		if _, ok := foundIdx[s.ReleaseID]; !ok {
+			missing = append(missing, s)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if productsSkipped > 0 {
+		log.Warnf("%s@%s: skipped %v products that had gone - not counting %v missing listings", payload.ArtistName, payload.RetailerName, productsSkipped, len(missing))
+		return nil
+	}
+	if len(found)*100 < listed*config.minResultsPercent {
+		log.Warnf("%s@%s: only found %v of %v known listings - not counting %v missing listings", payload.ArtistName, payload.RetailerName, len(found), listed, len(missing))
+		return nil
+	}
+
+	for _, s := range missing {
+		s.MissedScans++
+		if s.MissedScans < config.delistAfterMisses {
+			err = vinylDS.SetSKUMissedScans(tx, s.ID, s.MissedScans)
+			if err != nil {
+				return err
+			}
+			log.Debugf("%s@%s: release [%v:%v] not found (%v scans)", payload.ArtistName, payload.RetailerName, s.ID, s.ReleaseID, s.MissedScans)
+			continue
+		}
+		delisted := db.SKU{
+			ReleaseID:   s.ReleaseID,
+			RetailerID:  s.RetailerID,
+			ArtistID:    s.ArtistID,
+			ItemUrl:     s.ItemUrl,
+			ImageUrl:    s.ImageUrl,
+			Price:       retailers.DelistedPrice(),
+			SearchTerm:  s.SearchTerm,
+			Format:      s.Format,
+			Edition:     s.Edition,
+			Barcode:     s.Barcode,
+			CatalogueNo: s.CatalogueNo,
+		}
+		_, err = vinylDS.UpsertSKU(tx, &delisted)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to mark sku %v delisted", s.ID)
+		}
+		log.Debugf("%s@%s: release [%v:%v] not found in %v scans - marked delisted (%v)", payload.ArtistName, payload.RetailerName, s.ID, s.ReleaseID, s.MissedScans, delisted.ID)
 	}
 	return nil
 }
@@ -0,0 +1,220 @@
+//go:build unit_test
+// +build unit_test
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gavinturner/vinylretailers/db"
+	"github.com/gavinturner/vinylretailers/retailers"
+	"github.com/gavinturner/vinylretailers/util/postgres"
+	"github.com/gavinturner/vinylretailers/util/queue"
+	"github.com/gavinturner/vinylretailers/util/redis"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+)
+
+// scannerDB mocks the database for a scan of an artist at the retailer, with the artist's scan outstanding in its
+// batch until it's cleared
+func scannerDB(retailer db.Retailer) *db.VinylDSMock {
+	outstanding := true
+	return &db.VinylDSMock{
+		GetRetailerFunc: func(tx *postgres.Tx, retailerId int64) (*db.Retailer, error) {
+			return &retailer, nil
+		},
+		StartTransactionFunc: func() (*postgres.Tx, error) {
+			return nil, nil
+		},
+		CloseTransactionFunc: func(tx *postgres.Tx, err error) error {
+			return nil
+		},
+		ClearOutstandingScanFunc: func(tx *postgres.Tx, batchID int64, artistID int64, retailerID int64) (bool, error) {
+			cleared := outstanding
+			outstanding = false
+			return cleared, nil
+		},
+		IncrementBatchSearchFailedCountFunc: func(tx *postgres.Tx, batchId int64) (bool, error) {
+			return false, nil
+		},
+		IncrementBatchSearchCompletedCountFunc: func(tx *postgres.Tx, batchId int64) (bool, error) {
+			return false, nil
+		},
+		AddScanFunc: func(tx *postgres.Tx, scan *db.Scan) error {
+			return nil
+		},
+		GetRetailerHealthFunc: func(tx *postgres.Tx, retailerID *int64, window time.Duration, baseline time.Duration, now time.Time) ([]db.RetailerHealth, error) {
+			return []db.RetailerHealth{}, nil
+		},
+	}
+}
+
+func TestScanner_RobotsRefusal(t *testing.T) {
+	t.Parallel()
+
+	// a shopify store with the default robots.txt, which disallows its search
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, "User-agent: *\nDisallow: /search\n")
+			return
+		}
+		t.Errorf("Unexpected request for %s", r.URL)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer store.Close()
+
+	vinylDS := scannerDB(db.Retailer{
+		ID:            1,
+		Name:          "Store",
+		ScraperKey:    null.StringFrom(retailers.SHOPIFY_SCRAPER_KEY),
+		ScraperConfig: types.JSONText(`{"baseUrl": "` + store.URL + `", "searchPath": "/search?q=%s", "fetch": {"robots": "obey", "requestIntervalMillis": 0}}`),
+		Enabled:       true,
+	})
+	scanningQueue := queue.NewMemoryQueue(queue.Options{})
+	defer scanningQueue.Close()
+	payload := redis.ScanRequest{BatchID: 7, RetailerID: 1, RetailerName: "Store", ArtistID: 3, ArtistName: "clowns"}
+	require.Nil(t, scanningQueue.Enqueue(payload))
+	delivery, err := scanningQueue.Dequeue(context.Background(), &payload, false)
+	require.Nil(t, err)
+
+	runScan(vinylDS, scanningQueue, delivery, &payload, loadScanConfig())
+
+	require.Equal(t, 1, len(vinylDS.AddScanCalls()), "Expected the scan to be recorded")
+	scan := vinylDS.AddScanCalls()[0].Scan
+	assert.Equal(t, db.ScanStatus_Disallowed, scan.Status, "Expected a robots refusal rather than a retailer failure")
+	assert.Equal(t, db.ScanOutcome_Failed, scan.Outcome, "Expected a refused scan not to be retried")
+	assert.Equal(t, 1, len(vinylDS.IncrementBatchSearchFailedCountCalls()), "Expected the search to count towards its batch")
+	waiting, _ := scanningQueue.QueueLength()
+	dead, _ := scanningQueue.DeadLetterLength()
+	assert.Equal(t, int64(0), waiting+dead, "Expected the request to be acked")
+}
+
+func TestScanner_RedeliveryCountsMissesOnce(t *testing.T) {
+	t.Parallel()
+
+	// the store lists one of the two releases we know it has
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><div class="card"><h3>Clowns - Bad Blood LP</h3><a href="/p/bad-blood">view</a><span class="price">$35.00</span></div></body></html>`)
+	}))
+	defer store.Close()
+
+	vinylDS := scannerDB(db.Retailer{
+		ID:         1,
+		Name:       "Store",
+		ScraperKey: null.StringFrom(retailers.SELECTOR_SCRAPER_KEY),
+		ScraperConfig: types.JSONText(`{"searchUrl": "` + store.URL + `/search?q=%s", "item": ".card", "title": {"selector": "h3"},
+			"url": {"selector": "a", "attr": "href"}, "price": {"selector": ".price"}, "fetch": {"robots": "ignore", "requestIntervalMillis": 0}}`),
+		Enabled: true,
+	})
+	vinylDS.MatchReleaseFunc = func(tx *postgres.Tx, artistID int64, retailerID int64, title string, itemURL string, barcodes []string, catalogueNos []string) (db.ReleaseMatch, error) {
+		return db.ReleaseMatch{ReleaseID: 10}, nil
+	}
+	vinylDS.UpsertSKUFunc = func(tx *postgres.Tx, sku *db.SKU) (db.SKUChange, error) {
+		return db.SKUChange_None, nil
+	}
+	vinylDS.GetCurrentSKUsFunc = func(tx *postgres.Tx, artistID int64, retailerID int64) ([]db.SKU, error) {
+		return []db.SKU{
+			{ID: 100, ReleaseID: 10, Price: retailers.NewPrice(3500)},
+			{ID: 101, ReleaseID: 11, Price: retailers.NewPrice(4000)},
+		}, nil
+	}
+	vinylDS.SetSKUMissedScansFunc = func(tx *postgres.Tx, skuID int64, missedScans int) error {
+		return nil
+	}
+
+	// the request ran past its visibility timeout, so it's delivered (and scanned) twice
+	payload := redis.ScanRequest{BatchID: 7, RetailerID: 1, RetailerName: "Store", ArtistID: 3, ArtistName: "clowns"}
+	config := loadScanConfig()
+	for delivery := 1; delivery <= 2; delivery++ {
+		result, _, err := scrapeArtistForRetailer(context.Background(), vinylDS, &payload, config)
+		require.Nil(t, err, "Failed scan %v", delivery)
+		require.Equal(t, 1, len(result.SKUs))
+	}
+
+	require.Equal(t, 1, len(vinylDS.SetSKUMissedScansCalls()), "Expected the missing listing to be counted by one delivery")
+	assert.Equal(t, int64(101), vinylDS.SetSKUMissedScansCalls()[0].SkuID)
+	assert.Equal(t, 1, vinylDS.SetSKUMissedScansCalls()[0].MissedScans)
+	assert.Equal(t, 1, len(vinylDS.IncrementBatchSearchCompletedCountCalls()), "Expected the search to count towards its batch once")
+}
+
+func TestScanner_ExpiredRequestCountsAsFailed(t *testing.T) {
+	t.Parallel()
+
+	vinylDS := scannerDB(db.Retailer{ID: 1, Name: "Store", Enabled: true})
+	scanningQueue := queue.NewMemoryQueue(queue.Options{VisibilityTimeout: 20 * time.Millisecond, MaxAttempts: 1, ExpiredDeadLetter: settleExpiredScanRequest(vinylDS, loadScanConfig())})
+	defer scanningQueue.Close()
+	payload := redis.ScanRequest{BatchID: 7, RetailerID: 1, RetailerName: "Store", ArtistID: 3, ArtistName: "clowns"}
+	require.Nil(t, scanningQueue.Enqueue(payload))
+
+	// the scanner holding the request's last attempt dies, so it's never acked
+	delivery, err := scanningQueue.Dequeue(context.Background(), &redis.ScanRequest{}, false)
+	require.Nil(t, err)
+	require.NotNil(t, delivery)
+	time.Sleep(30 * time.Millisecond)
+	delivery, err = scanningQueue.Dequeue(context.Background(), &redis.ScanRequest{}, false)
+	require.Nil(t, err)
+	assert.Nil(t, delivery, "Expected the expired request to be dead lettered rather than redelivered")
+
+	require.Equal(t, 1, len(vinylDS.ClearOutstandingScanCalls()), "Expected the outstanding scan to be cleared")
+	assert.Equal(t, int64(7), vinylDS.ClearOutstandingScanCalls()[0].BatchID)
+	require.Equal(t, 1, len(vinylDS.IncrementBatchSearchFailedCountCalls()), "Expected the search to count towards its batch, so it can complete")
+	assert.Equal(t, int64(7), vinylDS.IncrementBatchSearchFailedCountCalls()[0].BatchId)
+}
+
+func TestScanner_HealthCheckedAsBatchCompletes(t *testing.T) {
+	t.Parallel()
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body></body></html>`)
+	}))
+	defer store.Close()
+
+	// the batch has two artists to scan at the retailer
+	vinylDS := scannerDB(db.Retailer{
+		ID:         1,
+		Name:       "Store",
+		ScraperKey: null.StringFrom(retailers.SELECTOR_SCRAPER_KEY),
+		ScraperConfig: types.JSONText(`{"searchUrl": "` + store.URL + `/search?q=%s", "item": ".card", "title": {"selector": "h3"},
+			"url": {"selector": "a", "attr": "href"}, "price": {"selector": ".price"}, "fetch": {"robots": "ignore", "requestIntervalMillis": 0}}`),
+		Enabled: true,
+	})
+	vinylDS.ClearOutstandingScanFunc = func(tx *postgres.Tx, batchID int64, artistID int64, retailerID int64) (bool, error) {
+		return true, nil
+	}
+	vinylDS.GetCurrentSKUsFunc = func(tx *postgres.Tx, artistID int64, retailerID int64) ([]db.SKU, error) {
+		return []db.SKU{}, nil
+	}
+	completed := 0
+	vinylDS.IncrementBatchSearchCompletedCountFunc = func(tx *postgres.Tx, batchId int64) (bool, error) {
+		completed++
+		return completed == 2, nil
+	}
+	vinylDS.GetRetailerHealthFunc = func(tx *postgres.Tx, retailerID *int64, window time.Duration, baseline time.Duration, now time.Time) ([]db.RetailerHealth, error) {
+		return []db.RetailerHealth{{RetailerID: 1, RetailerName: "Store", Scans: 10, BaselineScans: 10, BaselineSucceeded: 10}}, nil
+	}
+	scanningQueue := queue.NewMemoryQueue(queue.Options{})
+	defer scanningQueue.Close()
+
+	config := loadScanConfig()
+	for artistID := int64(1); artistID <= 2; artistID++ {
+		payload := redis.ScanRequest{BatchID: 7, RetailerID: 1, RetailerName: "Store", ArtistID: artistID, ArtistName: "clowns"}
+		require.Nil(t, scanningQueue.Enqueue(payload))
+		delivery, err := scanningQueue.Dequeue(context.Background(), &payload, false)
+		require.Nil(t, err)
+		runScan(vinylDS, scanningQueue, delivery, &payload, config)
+		if artistID == 1 {
+			assert.Equal(t, 0, len(vinylDS.GetRetailerHealthCalls()), "Expected health not to be checked before the batch completes")
+		}
+	}
+
+	require.Equal(t, 1, len(vinylDS.GetRetailerHealthCalls()), "Expected health to be checked once, as the batch completes")
+	assert.Nil(t, vinylDS.GetRetailerHealthCalls()[0].RetailerID, "Expected every retailer's health to be checked")
+	assert.Equal(t, config.health.Window, vinylDS.GetRetailerHealthCalls()[0].Window)
+}
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/gavinturner/vinylretailers/db"
+	"github.com/gavinturner/vinylretailers/retailers"
+	"github.com/gavinturner/vinylretailers/util/cfg"
+	"github.com/gavinturner/vinylretailers/util/cron"
+	"github.com/gavinturner/vinylretailers/util/log"
+	"github.com/gavinturner/vinylretailers/util/queue"
+	"github.com/gavinturner/vinylretailers/util/redis"
+	"github.com/pkg/errors"
+	"math/rand"
+	"time"
+)
+
+const (
+	MAX_QUIET_RUNS = 10000 // runs in a row that can fall in a schedule's quiet hours before it's treated as never running
+
+	DEFAULT_HEALTH_WINDOW_SECS   = 6 * 60 * 60
+	DEFAULT_HEALTH_BASELINE_SECS = 7 * 24 * 60 * 60
+	DEFAULT_HEALTH_MIN_SCANS     = 5
+	DEFAULT_HEALTH_DROP_PERCENT  = 50
+	DEFAULT_HEALTH_RETRY_SECS    = 6 * 60 * 60
+)
+
+// RetailerHealthConfig controls when a retailer is judged to be broken (see db.RetailerHealth.Problem).
+type RetailerHealthConfig struct {
+	Window      time.Duration // how far back a retailer's recent scans are judged from
+	Baseline    time.Duration // how long before the window the scans it's compared with go back
+	MinScans    int           // scans of a retailer in the window before its health is judged
+	DropPercent int           // drop in success rate or results from the baseline that flags a retailer as broken
+	Retry       time.Duration // how long a broken retailer goes unscanned before it's tried again
+}
+
+// LoadRetailerHealthConfig reads the HEALTH_WINDOW_SECS, HEALTH_BASELINE_SECS, HEALTH_MIN_SCANS, HEALTH_DROP_PERCENT
+// and HEALTH_RETRY_SECS settings.
+func LoadRetailerHealthConfig() RetailerHealthConfig {
+	intSetting := func(name string, value int) int {
+		if setting, _ := cfg.IntSetting(name); setting > 0 {
+			return setting
+		}
+		return value
+	}
+	return RetailerHealthConfig{
+		Window:      time.Duration(intSetting("HEALTH_WINDOW_SECS", DEFAULT_HEALTH_WINDOW_SECS)) * time.Second,
+		Baseline:    time.Duration(intSetting("HEALTH_BASELINE_SECS", DEFAULT_HEALTH_BASELINE_SECS)) * time.Second,
+		MinScans:    intSetting("HEALTH_MIN_SCANS", DEFAULT_HEALTH_MIN_SCANS),
+		DropPercent: intSetting("HEALTH_DROP_PERCENT", DEFAULT_HEALTH_DROP_PERCENT),
+		Retry:       time.Duration(intSetting("HEALTH_RETRY_SECS", DEFAULT_HEALTH_RETRY_SECS)) * time.Second,
+	}
+}
+
+// ActiveRetailers returns the enabled retailers that have a registered scraper to scan them with.
+func ActiveRetailers(vinylDS db.VinylDS) ([]db.Retailer, error) {
+	allRetailers, err := vinylDS.GetAllRetailers(nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get retailers list")
+	}
+	active := []db.Retailer{}
+	for _, retailer := range allRetailers {
+		if retailer.Enabled && retailer.ScraperKey.Valid && retailers.IsRegisteredScraper(retailer.ScraperKey.String) {
+			active = append(active, retailer)
+		}
+	}
+	return active, nil
+}
+
+// HealthyRetailers returns the retailers that don't look broken over the health window, and those that do but
+// haven't been scanned for the retry period (so a retailer that has been fixed is picked up again).
+func HealthyRetailers(vinylDS db.VinylDS, config RetailerHealthConfig, activeRetailers []db.Retailer, now time.Time) ([]db.Retailer, error) {
+	health, err := vinylDS.GetRetailerHealth(nil, nil, config.Window, config.Baseline, now)
+	if err != nil {
+		return nil, err
+	}
+	broken := map[int64]string{}
+	for _, h := range health {
+		if problem := h.Problem(config.MinScans, config.DropPercent); problem != "" && now.Sub(h.LastScanAt) < config.Retry {
+			broken[h.RetailerID] = problem
+		}
+	}
+	healthy := []db.Retailer{}
+	for _, retailer := range activeRetailers {
+		if problem, ok := broken[retailer.ID]; ok {
+			log.Warnf("Not scanning '%s', as it looks broken: %s", retailer.Name, problem)
+			continue
+		}
+		healthy = append(healthy, retailer)
+	}
+	return healthy, nil
+}
+
+// ScheduleBatch starts a new batch (with a report for each user) and queues a scan request for every artist the
+// users watch at every retailer that is due a scan (all of them, if due is nil), in the priority lane if asked. The
+// batch requires as many searches as requests were queued, and each is recorded as outstanding until the scanner is
+// done with it. If the requests can't all be queued the batch is deleted. Returns 0 if there is nothing to scan.
+func ScheduleBatch(vinylDS db.VinylDS, scanningQueue queue.Queue, activeRetailers []db.Retailer, watchedArtists map[int64][]db.WatchedArtist, priority bool, due func(retailerID int64, artistID int64) bool) (int64, error) {
+
+	// index a single scannable list of artists
+	artists := map[int64]db.WatchedArtist{}
+	for _, watches := range watchedArtists {
+		for _, watch := range watches {
+			artists[watch.ArtistID] = watch
+		}
+	}
+
+	// the scans that are due (once each, even if a retailer is listed twice), and the artists they cover
+	scans := []redis.ScanRequest{}
+	outstanding := []db.OutstandingScan{}
+	scanned := map[int64]bool{}
+	retailerIDs := map[int64]bool{}
+	for _, retailer := range activeRetailers {
+		if retailerIDs[retailer.ID] {
+			continue
+		}
+		retailerIDs[retailer.ID] = true
+		for artistID, watchedArtist := range artists {
+			if due != nil && !due(retailer.ID, artistID) {
+				continue
+			}
+			scans = append(scans, redis.ScanRequest{
+				ArtistID:       artistID,
+				RetailerID:     retailer.ID,
+				ArtistName:     watchedArtist.ArtistName,
+				ArtistVariants: watchedArtist.ArtistVariants,
+				RetailerName:   retailer.Name,
+			})
+			outstanding = append(outstanding, db.OutstandingScan{ArtistID: artistID, RetailerID: retailer.ID})
+			scanned[artistID] = true
+		}
+	}
+	if len(scans) == 0 {
+		return 0, nil
+	}
+
+	// users' reports only cover the artists being scanned
+	watchers := map[int64][]db.WatchedArtist{}
+	for userID, watches := range watchedArtists {
+		for _, watch := range watches {
+			if scanned[watch.ArtistID] {
+				watchers[userID] = append(watchers[userID], watch)
+			}
+		}
+	}
+
+	// add the new batch to the db (and start a report for each watching user), along with its outstanding scans
+	batchID, err := startBatch(vinylDS, len(scans), watchers, outstanding)
+	if err != nil {
+		return 0, err
+	}
+	log.Debugf("Scheduling batch %v...", batchID)
+	for _, payload := range scans {
+		payload.BatchID = batchID
+		if priority {
+			err = scanningQueue.EnqueuePriority(payload)
+		} else {
+			err = scanningQueue.Enqueue(payload)
+		}
+		if err != nil {
+			if err2 := vinylDS.DeleteBatch(nil, batchID); err2 != nil {
+				log.Error(err2, "Failed to cleanup batch %v", batchID)
+			}
+			return 0, errors.Wrapf(err, "failed to write scanning request to redis scanning queue")
+		}
+	}
+	return batchID, nil
+}
+
+func startBatch(vinylDS db.VinylDS, requiredSearches int, watchers map[int64][]db.WatchedArtist, outstanding []db.OutstandingScan) (batchID int64, err error) {
+	tx, err := vinylDS.StartTransaction()
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to start transaction")
+	}
+	defer func() {
+		if closeErr := vinylDS.CloseTransaction(tx, err); err == nil && closeErr != nil {
+			batchID, err = 0, errors.Wrapf(closeErr, "failed to commit new batch")
+		}
+	}()
+	batchID, err = vinylDS.AddNewBatch(tx, requiredSearches, watchers)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to start new batch")
+	}
+	err = vinylDS.AddOutstandingScans(tx, batchID, outstanding)
+	if err != nil {
+		return 0, err
+	}
+	return batchID, nil
+}
+
+// NextScheduledRun returns when the schedule next runs after the given time: the first time its cron expression
+// fires (in its timezone) outside its quiet hours, delayed by a random jitter of up to its jitter as long as that
+// doesn't take it into quiet hours. The time is to the second, so that it is stored exactly.
+func NextScheduledRun(schedule db.Schedule, after time.Time) (time.Time, error) {
+	cronSchedule, err := cron.Parse(schedule.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	location, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "unknown timezone '%s'", schedule.Timezone)
+	}
+	var quiet *cron.QuietHours
+	if schedule.QuietStart.Valid && schedule.QuietEnd.Valid {
+		quietHours, err := cron.ParseQuietHours(schedule.QuietStart.String, schedule.QuietEnd.String)
+		if err != nil {
+			return time.Time{}, err
+		}
+		quiet = &quietHours
+	}
+	next := after.In(location)
+	for i := 0; i < MAX_QUIET_RUNS; i++ {
+		next = cronSchedule.Next(next)
+		if next.IsZero() {
+			break
+		}
+		if quiet != nil && quiet.Contains(next) {
+			continue
+		}
+		// a run jittered into quiet hours runs on time instead
+		run := next
+		if schedule.JitterSecs > 0 {
+			run = next.Add(time.Duration(rand.Intn(schedule.JitterSecs+1)) * time.Second)
+			if quiet != nil && quiet.Contains(run) {
+				run = next
+			}
+		}
+		return run.Truncate(time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("schedule '%s' never runs outside its quiet hours", schedule.Cron)
+}
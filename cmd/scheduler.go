@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"context"
+	"github.com/gavinturner/vinylretailers/db"
+	"github.com/gavinturner/vinylretailers/util/cfg"
+	"github.com/gavinturner/vinylretailers/util/log"
+	"github.com/gavinturner/vinylretailers/util/postgres"
+	"github.com/gavinturner/vinylretailers/util/queue"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+	"time"
+)
+
+const (
+	SCHEDULE_CHECK_SECS = 30 // how often the schedules are checked for runs that are due
+
+	DEFAULT_OUTSTANDING_SCAN_TIMEOUT_SECS = 6 * 60 * 60
+)
+
+// SchedulerConfig controls which scans a schedule's run queues.
+type SchedulerConfig struct {
+	intervals          ScanIntervalConfig
+	health             RetailerHealthConfig
+	outstandingTimeout time.Duration // a scan queued longer ago that hasn't been done with is taken to be lost
+}
+
+// LoadSchedulerConfig reads the OUTSTANDING_SCAN_TIMEOUT_SECS setting, and the scan interval and health settings.
+func LoadSchedulerConfig() SchedulerConfig {
+	timeout, _ := cfg.IntSetting("OUTSTANDING_SCAN_TIMEOUT_SECS")
+	if timeout <= 0 {
+		timeout = DEFAULT_OUTSTANDING_SCAN_TIMEOUT_SECS
+	}
+	return SchedulerConfig{
+		intervals:          LoadScanIntervalConfig(),
+		health:             LoadRetailerHealthConfig(),
+		outstandingTimeout: time.Duration(timeout) * time.Second,
+	}
+}
+
+// RunScheduler campaigns to be the scheduler, and while it's the leader starts the schedules' runs as they fall due,
+// until the context is cancelled. It's run by the scheduler, and by the scanner when it has the queue to itself.
+func RunScheduler(ctx context.Context, psqlDB *postgres.DB, vinylDS db.VinylDS, scanningQueue queue.Queue, config SchedulerConfig) {
+	InitialiseLeaderElection(psqlDB, SCHEDULER_LEADER_NAME).Run(ctx, func(ctx context.Context) {
+		for ctx.Err() == nil {
+			runDueSchedules(ctx, vinylDS, scanningQueue, config, time.Now())
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Duration(SCHEDULE_CHECK_SECS) * time.Second):
+			}
+		}
+	})
+}
+
+// runDueSchedules starts a batch for each schedule whose next run is due, of the retailers it covers. Each run is
+// claimed (by moving the schedule's next run on) before its batch is started, so a run is only started once even if
+// the scheduler is restarted, and a run that was due while the scheduler was down is started when it comes back up.
+// A schedule the scheduler hasn't seen before first runs at its first scheduled time after it was created. It stops
+// between schedules if the context is cancelled (as the scheduler is no longer leader).
+func runDueSchedules(ctx context.Context, vinylDS db.VinylDS, scanningQueue queue.Queue, config SchedulerConfig, now time.Time) {
+	schedules, err := vinylDS.GetEnabledSchedules(nil)
+	if err != nil {
+		log.Error(err, "Failed to get schedules")
+		return
+	}
+
+	var activeRetailers []db.Retailer
+	var watchedArtists map[int64][]db.WatchedArtist
+	for _, schedule := range schedules {
+		if ctx.Err() != nil {
+			return
+		}
+		if schedule.NextRunAt.Valid && schedule.NextRunAt.Time.After(now) {
+			continue
+		}
+		due := schedule.NextRunAt.Time
+		if !schedule.NextRunAt.Valid {
+			// a new schedule first runs at its first run after it was created, which may already be due
+			first, err := NextScheduledRun(schedule, schedule.CreatedAt)
+			if err != nil {
+				log.Error(err, "Schedule %v '%s' is invalid", schedule.ID, schedule.Name)
+				continue
+			}
+			if first.After(now) {
+				claimed, err := vinylDS.MoveScheduleNextRun(nil, schedule.ID, schedule.NextRunAt, first)
+				if err != nil {
+					log.Error(err, "Failed to set first run of schedule '%s'", schedule.Name)
+				} else if claimed {
+					log.Infof("Schedule '%s' (%s) first runs at %v", schedule.Name, schedule.Cron, first)
+				}
+				continue
+			}
+			due = first
+		}
+		next, err := NextScheduledRun(schedule, now)
+		if err != nil {
+			log.Error(err, "Schedule %v '%s' is invalid", schedule.ID, schedule.Name)
+			continue
+		}
+		claimed, err := vinylDS.MoveScheduleNextRun(nil, schedule.ID, schedule.NextRunAt, next)
+		if err != nil {
+			log.Error(err, "Failed to claim run of schedule '%s'", schedule.Name)
+			continue
+		} else if !claimed {
+			continue
+		}
+
+		// grab the list of known retailers that we have a scraper for, and the list of artists watched by which users
+		if activeRetailers == nil {
+			activeRetailers, err = ActiveRetailers(vinylDS)
+			if err != nil {
+				log.Error(err, "Failed to get retailers list")
+			} else if healthy, err := HealthyRetailers(vinylDS, config.health, activeRetailers, now); err != nil {
+				log.Error(err, "Failed to check retailer health - scanning them all")
+			} else {
+				activeRetailers = healthy
+			}
+			watchedArtists, err = vinylDS.GetWatchedArtists(nil)
+			if err != nil {
+				log.Error(err, "Failed to get artists list")
+			}
+		}
+
+		//
+		// Create a new batch and enqueue the scan requests that are due for that batch.
+		//
+
+		batchID, err := scheduleDueScans(vinylDS, scanningQueue, config, scheduledRetailers(schedule, schedules, activeRetailers), watchedArtists, now)
+		if err != nil {
+			log.Error(err, "Failed to schedule new batch for schedule '%s'", schedule.Name)
+			// put the run back, so it's tried again rather than skipped
+			if _, err := vinylDS.MoveScheduleNextRun(nil, schedule.ID, null.TimeFrom(next), due); err != nil {
+				log.Error(err, "Failed to put back run of schedule '%s' - skipping it", schedule.Name)
+			}
+			continue
+		} else if batchID != 0 {
+			log.Debugf("Batch %v scheduled for schedule '%s', which next runs at %v..", batchID, schedule.Name, next)
+		}
+		err = vinylDS.SetScheduleLastRun(nil, schedule.ID, now, batchID)
+		if err != nil {
+			log.Error(err, "Failed to record run of schedule '%s'", schedule.Name)
+		}
+	}
+}
+
+// scheduleDueScans starts a batch of the artists that are due a scan at each of the retailers, adapting how often
+// each is scanned to how often its listings have changed, and records when each was scheduled. An artist with a scan
+// still outstanding at a retailer isn't scanned there again until it has been done with.
+func scheduleDueScans(vinylDS db.VinylDS, scanningQueue queue.Queue, config SchedulerConfig, retailers []db.Retailer, watchedArtists map[int64][]db.WatchedArtist, now time.Time) (int64, error) {
+	intervals, err := AdaptScanIntervals(vinylDS, config.intervals, retailers, watchedArtists, now)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to adapt scan intervals")
+	}
+	outstandingScans, err := vinylDS.GetOutstandingScans(nil, now.Add(-config.outstandingTimeout))
+	if err != nil {
+		return 0, err
+	}
+	outstanding := map[ScanPair]bool{}
+	for _, s := range outstandingScans {
+		outstanding[ScanPair{RetailerID: s.RetailerID, ArtistID: s.ArtistID}] = true
+	}
+	due := func(retailerID int64, artistID int64) bool {
+		pair := ScanPair{RetailerID: retailerID, ArtistID: artistID}
+		return !outstanding[pair] && ScanDue(intervals[pair], now)
+	}
+	batchID, err := ScheduleBatch(vinylDS, scanningQueue, retailers, watchedArtists, false, due)
+	if err != nil {
+		return 0, err
+	}
+
+	// the batch is queued, so a failure to record it only means the artists may be scanned again sooner than due
+	scheduled := 0
+	updated := []db.ScanInterval{}
+	for _, interval := range intervals {
+		if batchID != 0 && due(interval.RetailerID, interval.ArtistID) {
+			interval.LastScheduledAt = null.TimeFrom(now)
+			interval.NextScanAt = null.TimeFrom(now.Add(time.Duration(interval.IntervalSecs) * time.Second))
+			scheduled++
+		}
+		updated = append(updated, interval)
+	}
+	if err = vinylDS.SaveScanIntervals(nil, updated); err != nil {
+		log.Error(err, "Failed to save scan intervals")
+	}
+	log.Debugf("%v of %v artist scans are due at %v retailers (%v outstanding)", scheduled, len(intervals), len(retailers), len(outstanding))
+	return batchID, nil
+}
+
+// scheduledRetailers returns the active retailers the schedule covers: its retailer, or if it doesn't have one the
+// retailers without an enabled schedule of their own.
+func scheduledRetailers(schedule db.Schedule, schedules []db.Schedule, activeRetailers []db.Retailer) []db.Retailer {
+	own := map[int64]struct{}{}
+	for _, s := range schedules {
+		if s.RetailerID.Valid {
+			own[s.RetailerID.Int64] = struct{}{}
+		}
+	}
+	covered := []db.Retailer{}
+	for _, retailer := range activeRetailers {
+		if schedule.RetailerID.Valid {
+			if retailer.ID == schedule.RetailerID.Int64 {
+				covered = append(covered, retailer)
+			}
+		} else if _, ok := own[retailer.ID]; !ok {
+			covered = append(covered, retailer)
+		}
+	}
+	return covered
+}
@@ -1,28 +1,32 @@
 package main
 
 import (
+	"context"
 	"github.com/gavinturner/vinylretailers/cmd"
 	"github.com/gavinturner/vinylretailers/db"
 	"github.com/gavinturner/vinylretailers/util/log"
-	"github.com/gavinturner/vinylretailers/util/redis"
+	"github.com/gavinturner/vinylretailers/util/queue"
 	_ "github.com/lib/pq"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
 const (
-	// delay for ten minutes between attepts to produce reports
-	STARTUP_DELAY_SECS         = 10
-	DBSTARTUP_TIMEOUT_SECS     = 30
-	DELAY_BETWEEN_REPORTS_SECS = 60 * 30 // 30 minutes
+	STARTUP_DELAY_SECS     = 10
+	DBSTARTUP_TIMEOUT_SECS = 30
 )
 
-//
 // scheduler.main()
-// Represents the process body of the scheduler pod. Note that only one scheduler pod is required per install.
+// Represents the process body of the scheduler pod. Only one scheduler pod is required per install, but more can be
+// run for redundancy: they elect a leader, and only the leader schedules batches. The others stand by to take over.
 // The scheduler is responsible for creating new scanning batches and pushing the set of required scanning requests
-// for the batch onto the scanning queue.
+// for the batch onto the scanning queue. When it does so is up to the schedules in the database: each has a cron
+// expression, and either covers one retailer or all the retailers without a schedule of their own. A run only scans
+// the artists that are due at each retailer, by how often their listings there change, that aren't still waiting on
+// an earlier scan. Broken retailers aren't scanned until they have been left for a while.
 // @see scanner.main()
-//
 func main() {
 
 	// sleep on startup to let the infra pods get started up
@@ -39,15 +43,11 @@ func main() {
 	defer psqlDB.Close()
 	vinylDS := db.NewDB(psqlDB)
 
-	scanningQueue, err := cmd.InitialiseRedisScanningQueue()
+	scanningQueue, err := cmd.InitialiseScanningQueue(queue.Options{})
 	if err != nil {
 		panic(err)
 	}
 	defer scanningQueue.Close()
-	_, err = scanningQueue.PingRedis()
-	if err != nil {
-		panic(err)
-	}
 
 	// make sure that the db is up. keep trying every second until it is
 	log.Debugf("Retail vinyl scheduler starts..\n")
@@ -55,80 +55,13 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-
-	for {
-
-		// grab the list of known retailers
-		retailers, err := vinylDS.GetAllRetailers(nil)
-		if err != nil {
-			log.Error(err, "Failed to get retailers list")
-		}
-
-		// grab the list of artists watched by which users
-		watchedArtists, err := vinylDS.GetWatchedArtists(nil)
-		if err != nil {
-			log.Error(err, "Failed to get artists list")
-		}
-
-		// index a single scannable list of artists
-		artists := map[int64]db.WatchedArtist{}
-		for _, watches := range watchedArtists {
-			for _, watch := range watches {
-				artists[watch.ArtistID] = watch
-			}
-		}
-
-		//
-		// Create a new batch and enqueue all the scan requests for that batch.
-		//
-
-		var batchID int64
-		if len(artists) > 0 && len(retailers) > 0 {
-			// calculate the number of expected scans for a new batch
-			// temporary
-			requiredSearches := len(artists) * len(retailers)
-
-			// add the new batch to the db (and start a report for each watching user)
-			batchID, err = vinylDS.AddNewBatch(nil, requiredSearches, watchedArtists)
-			if err != nil {
-				log.Error(err, "Failed to start new batch")
-			} else {
-				// enqueue all our scan requests for the batch
-				log.Debugf("Scheduling batch %v...", batchID)
-				for _, retailer := range retailers {
-					for artistID, watchedArtist := range artists {
-						payload := redis.ScanRequest{
-							BatchID:        batchID,
-							ArtistID:       artistID,
-							RetailerID:     retailer.ID,
-							ArtistName:     watchedArtist.ArtistName,
-							ArtistVariants: watchedArtist.ArtistVariants,
-							RetailerName:   retailer.Name,
-						}
-						err = scanningQueue.Enqueue(payload)
-						if err != nil {
-							log.Error(err, "Failed to write scanning request to redis scanning queue")
-							break
-						}
-					}
-					if err != nil {
-						break
-					}
-				}
-				if err != nil {
-					err2 := vinylDS.DeleteBatch(nil, batchID)
-					if err2 != nil {
-						log.Error(err, "Failed to cleanup batch %v", batchID)
-					}
-				}
-			}
-			if err != nil {
-				log.Debugf("Failed to schedule new batch %v", batchID)
-			} else {
-				log.Debugf("Batch %v scheduled..", batchID)
-			}
-		}
-		log.Debugf("Sleeping for %v seconds..", DELAY_BETWEEN_REPORTS_SECS)
-		time.Sleep(time.Duration(DELAY_BETWEEN_REPORTS_SECS) * time.Second)
+	err = cmd.VerifyRetailerScrapers(&vinylDS)
+	if err != nil {
+		panic(err)
 	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	cmd.RunScheduler(ctx, psqlDB, &vinylDS, scanningQueue, cmd.LoadSchedulerConfig())
+	log.Debugf("Retail vinyl scheduler terminating..")
 }
@@ -66,3 +66,21 @@ func (v *VinylDB) GetWatchedArtists(tx *postgres.Tx) (map[int64][]WatchedArtist,
 	}
 	return cached, nil
 }
+
+// SetFollowFilter limits what is reported to the user for an artist they follow: vinyl only (listings of an unknown
+// format are kept, as they might be), and/or only listings whose edition contains the filter e.g. "test pressing".
+// An empty filter reports every edition.
+func (v *VinylDB) SetFollowFilter(tx *postgres.Tx, userID int64, artistID int64, vinylOnly bool, editionFilter string) error {
+	querier := v.Q(tx)
+	result, err := querier.Exec(querier.Rebind(`
+		UPDATE users_following_artists SET vinyl_only = ?, edition_filter = NULLIF(?, ''), updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND artist_id = ?
+	`), vinylOnly, editionFilter, userID, artistID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set follow filter")
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errors.Errorf("user %v does not follow artist %v", userID, artistID)
+	}
+	return nil
+}
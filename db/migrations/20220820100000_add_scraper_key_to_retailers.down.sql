@@ -0,0 +1,5 @@
+
+DELETE FROM retailers WHERE id = 14;
+DROP INDEX IF EXISTS retailers_scraper_key_idx;
+ALTER TABLE retailers DROP COLUMN scraper_config;
+ALTER TABLE retailers DROP COLUMN scraper_key;
@@ -0,0 +1,25 @@
+
+ALTER TABLE retailers ADD COLUMN scraper_key TEXT;
+ALTER TABLE retailers ADD COLUMN scraper_config JSONB NOT NULL DEFAULT '{}';
+
+UPDATE retailers SET scraper_key = 'artistfirst' WHERE id = 1;
+UPDATE retailers SET scraper_key = 'poisoncity' WHERE id = 2;
+UPDATE retailers SET scraper_key = 'resistrecords' WHERE id = 3;
+UPDATE retailers SET scraper_key = 'damagedrecords' WHERE id = 4;
+UPDATE retailers SET scraper_key = 'repressedrecords' WHERE id = 5;
+UPDATE retailers SET scraper_key = 'utopia' WHERE id = 6;
+UPDATE retailers SET scraper_key = 'beatdiscrecords' WHERE id = 7;
+UPDATE retailers SET scraper_key = 'musicfarmers' WHERE id = 8;
+UPDATE retailers SET scraper_key = 'dutchvinyl' WHERE id = 9;
+UPDATE retailers SET scraper_key = 'clarityrecords' WHERE id = 10;
+UPDATE retailers SET scraper_key = 'ohjeanrecords' WHERE id = 11;
+UPDATE retailers SET scraper_key = 'strangeworldrecords' WHERE id = 12;
+UPDATE retailers SET scraper_key = 'grevillerecords' WHERE id = 13;
+
+INSERT INTO retailers (id, name, url, scraper_key) VALUES
+    (14, 'Off White Records', 'https://offwhiterecords.com', 'offwhiterecords')
+;
+
+-- each store's scraper scans one retailer, otherwise the retailer is scanned twice. the generic shopify scraper is
+-- configured entirely from the retailer row, so any number of retailers can use it
+CREATE UNIQUE INDEX IF NOT EXISTS retailers_scraper_key_idx ON retailers (scraper_key) WHERE scraper_key <> 'shopify';
@@ -0,0 +1,13 @@
+
+ALTER TABLE skus ADD COLUMN price TEXT NOT NULL DEFAULT '';
+
+UPDATE skus SET price = CASE
+    WHEN availability = 'sold_out' THEN 'sold out'
+    WHEN price_amount IS NULL THEN ''
+    ELSE '$' || TO_CHAR(price_amount / 100.0, 'FM999999990.00')
+END;
+
+ALTER TABLE skus ALTER COLUMN price DROP DEFAULT;
+ALTER TABLE skus DROP COLUMN availability;
+ALTER TABLE skus DROP COLUMN price_currency;
+ALTER TABLE skus DROP COLUMN price_amount;
@@ -0,0 +1,11 @@
+
+ALTER TABLE skus ADD COLUMN price_amount BIGINT;
+ALTER TABLE skus ADD COLUMN price_currency TEXT NOT NULL DEFAULT 'AUD';
+ALTER TABLE skus ADD COLUMN availability TEXT NOT NULL DEFAULT 'in_stock';
+
+-- prices were scraped text such as '$44.00', '$ 10.00' or 'sold out'. amounts are now stored in cents
+UPDATE skus SET availability = 'sold_out' WHERE LOWER(price) LIKE '%sold out%';
+UPDATE skus SET price_amount = ROUND(SUBSTRING(REPLACE(price, ',', '') FROM '[0-9]+(?:\.[0-9]+)?')::NUMERIC * 100)
+    WHERE SUBSTRING(REPLACE(price, ',', '') FROM '[0-9]+(?:\.[0-9]+)?') IS NOT NULL;
+
+ALTER TABLE skus DROP COLUMN price;
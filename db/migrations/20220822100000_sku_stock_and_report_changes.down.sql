@@ -0,0 +1,3 @@
+
+ALTER TABLE report_skus DROP COLUMN change;
+ALTER TABLE skus DROP COLUMN stock_quantity;
@@ -0,0 +1,5 @@
+
+ALTER TABLE skus ADD COLUMN stock_quantity INTEGER;
+
+-- why the sku was reported: new, restock, price, availability
+ALTER TABLE report_skus ADD COLUMN change TEXT NOT NULL DEFAULT 'new';
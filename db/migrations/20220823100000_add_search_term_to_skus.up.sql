@@ -0,0 +1,2 @@
+
+ALTER TABLE skus ADD COLUMN search_term TEXT;
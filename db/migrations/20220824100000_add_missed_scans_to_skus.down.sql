@@ -0,0 +1,2 @@
+
+ALTER TABLE skus DROP COLUMN missed_scans;
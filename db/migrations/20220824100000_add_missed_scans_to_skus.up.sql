@@ -0,0 +1,3 @@
+
+-- successful scans in a row that have not found the listing. it is marked delisted after several
+ALTER TABLE skus ADD COLUMN missed_scans INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+
+DROP TABLE release_reviews;
+DROP TABLE release_aliases;
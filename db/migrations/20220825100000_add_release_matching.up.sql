@@ -0,0 +1,28 @@
+
+-- normalised listing titles matched to a release, so the same listing title is matched straight away next time
+CREATE TABLE IF NOT EXISTS release_aliases (
+    id BIGSERIAL PRIMARY KEY,
+    release_id BIGINT NOT NULL REFERENCES releases(id) ON DELETE CASCADE,
+    artist_id BIGINT NOT NULL REFERENCES artists(id),
+    normalised_title TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (artist_id, normalised_title)
+);
+GRANT ALL PRIVILEGES ON TABLE release_aliases TO vinylretailers;
+
+-- listings that might be an existing release, but weren't similar enough to match automatically. the listing gets
+-- its own release until someone accepts (merging it into the candidate) or rejects the match. an accepted review is
+-- kept once its release has been merged away, as a record of the decision
+CREATE TABLE IF NOT EXISTS release_reviews (
+    id BIGSERIAL PRIMARY KEY,
+    release_id BIGINT REFERENCES releases(id) ON DELETE SET NULL,
+    candidate_release_id BIGINT NOT NULL REFERENCES releases(id) ON DELETE CASCADE,
+    retailer_id BIGINT REFERENCES retailers(id),
+    listing_title TEXT NOT NULL,
+    score REAL NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (release_id, candidate_release_id)
+);
+GRANT ALL PRIVILEGES ON TABLE release_reviews TO vinylretailers;
@@ -0,0 +1,6 @@
+
+ALTER TABLE users_following_artists DROP COLUMN edition_filter;
+ALTER TABLE users_following_artists DROP COLUMN vinyl_only;
+ALTER TABLE skus DROP COLUMN variants;
+ALTER TABLE skus DROP COLUMN edition;
+ALTER TABLE skus DROP COLUMN format;
@@ -0,0 +1,9 @@
+
+ALTER TABLE skus ADD COLUMN format TEXT;
+ALTER TABLE skus ADD COLUMN edition TEXT;
+-- price and availability of each version of the listing, where the retailer sells several (colours, lp and cd..)
+ALTER TABLE skus ADD COLUMN variants JSONB;
+
+-- only report listings that are (or might be) vinyl, and/or whose edition contains the filter e.g. 'test pressing'
+ALTER TABLE users_following_artists ADD COLUMN vinyl_only BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE users_following_artists ADD COLUMN edition_filter TEXT;
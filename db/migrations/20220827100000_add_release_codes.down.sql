@@ -0,0 +1,5 @@
+
+DROP TABLE release_codes;
+DROP INDEX skus_barcode_idx;
+ALTER TABLE skus DROP COLUMN catalogue_number;
+ALTER TABLE skus DROP COLUMN barcode;
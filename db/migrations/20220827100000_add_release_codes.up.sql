@@ -0,0 +1,18 @@
+
+ALTER TABLE skus ADD COLUMN IF NOT EXISTS barcode TEXT;
+ALTER TABLE skus ADD COLUMN IF NOT EXISTS catalogue_number TEXT;
+CREATE INDEX IF NOT EXISTS skus_barcode_idx ON skus (barcode);
+
+-- barcodes (EAN-13) and catalogue numbers seen on listings of a release. a listing with one of these is that
+-- release, whatever its title
+CREATE TABLE IF NOT EXISTS release_codes (
+    id BIGSERIAL PRIMARY KEY,
+    release_id BIGINT NOT NULL REFERENCES releases(id) ON DELETE CASCADE,
+    artist_id BIGINT NOT NULL REFERENCES artists(id),
+    kind TEXT NOT NULL, -- 'barcode' or 'catalogue_number'
+    value TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (artist_id, kind, value)
+);
+CREATE INDEX IF NOT EXISTS release_codes_value_idx ON release_codes (kind, value);
+GRANT ALL PRIVILEGES ON TABLE release_codes TO vinylretailers;
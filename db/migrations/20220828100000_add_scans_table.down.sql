@@ -0,0 +1,3 @@
+
+DROP VIEW retailer_batch_health;
+DROP TABLE scans;
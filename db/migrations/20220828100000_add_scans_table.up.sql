@@ -0,0 +1,27 @@
+
+-- a record of every scan request the scanner has run (each attempt of it), so failing or broken retailers show up
+CREATE TABLE IF NOT EXISTS scans (
+    id BIGSERIAL PRIMARY KEY,
+    batch_id BIGINT NOT NULL REFERENCES batches(id) ON DELETE CASCADE,
+    retailer_id BIGINT NOT NULL REFERENCES retailers(id),
+    artist_id BIGINT NOT NULL REFERENCES artists(id),
+    attempt INT NOT NULL DEFAULT 1,
+    status TEXT NOT NULL, -- succeeded, failed, timed_out or parse_error
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    result_count INT NOT NULL DEFAULT 0,
+    pages_fetched INT NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS scans_retailer_batch_idx ON scans (retailer_id, batch_id);
+GRANT ALL PRIVILEGES ON TABLE scans TO vinylretailers;
+
+-- how each retailer's scans went in each batch. results are only counted for successful scans
+CREATE OR REPLACE VIEW retailer_batch_health AS
+    SELECT retailer_id, batch_id, MIN(started_at) AS started_at, COUNT(*) AS scans,
+        COUNT(*) FILTER (WHERE status = 'succeeded') AS succeeded,
+        COALESCE(SUM(result_count) FILTER (WHERE status = 'succeeded'), 0) AS results
+    FROM scans
+    GROUP BY retailer_id, batch_id;
+GRANT ALL PRIVILEGES ON TABLE retailer_batch_health TO vinylretailers;
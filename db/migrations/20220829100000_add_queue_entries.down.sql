@@ -0,0 +1,2 @@
+
+DROP TABLE queue_entries;
@@ -0,0 +1,17 @@
+
+-- entries of queues kept in postgres rather than redis. consumers take them with SELECT ... FOR UPDATE SKIP LOCKED,
+-- so each is delivered to one consumer. a delivered entry is hidden until visible_at, when it is redelivered if it
+-- hasn't been acked (deleted) by then
+CREATE TABLE IF NOT EXISTS queue_entries (
+    id BIGSERIAL PRIMARY KEY,
+    queue TEXT NOT NULL,
+    priority BOOLEAN NOT NULL DEFAULT FALSE,
+    payload JSONB NOT NULL,
+    attempts INT NOT NULL DEFAULT 0, -- deliveries so far
+    queued_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP, -- reset when requeued, so it goes to the back
+    visible_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    dead_at TIMESTAMP WITH TIME ZONE, -- set when the entry is dead lettered
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS queue_entries_next_idx ON queue_entries (queue, priority DESC, queued_at, id) WHERE dead_at IS NULL;
+GRANT ALL PRIVILEGES ON TABLE queue_entries TO vinylretailers;
@@ -0,0 +1,4 @@
+
+ALTER TABLE scans DROP COLUMN outcome;
+ALTER TABLE batches DROP COLUMN retried_searches;
+ALTER TABLE batches DROP COLUMN failed_searches;
@@ -0,0 +1,7 @@
+
+-- searches that failed for good (they still complete the batch) and scans that failed and were requeued to retry
+ALTER TABLE batches ADD COLUMN IF NOT EXISTS failed_searches INT DEFAULT 0;
+ALTER TABLE batches ADD COLUMN IF NOT EXISTS retried_searches INT DEFAULT 0;
+
+-- what became of the scan request after the scan: succeeded, retrying or failed
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS outcome TEXT NOT NULL DEFAULT 'succeeded';
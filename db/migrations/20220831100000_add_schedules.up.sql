@@ -0,0 +1,24 @@
+
+-- when the scheduler starts batches. a schedule for a retailer covers just that retailer, and the retailers without a
+-- schedule of their own are covered by the schedules without a retailer. next_run_at is kept (with its jitter) so a
+-- restarted scheduler picks up where it left off
+CREATE TABLE IF NOT EXISTS schedules (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    retailer_id BIGINT REFERENCES retailers(id) ON DELETE CASCADE,
+    cron TEXT NOT NULL, -- minute hour day-of-month month day-of-week, in the schedule's timezone
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    quiet_start TEXT, -- HH:MM. runs that fall between quiet_start and quiet_end are skipped
+    quiet_end TEXT,
+    jitter_secs INT NOT NULL DEFAULT 0, -- runs are delayed by up to this long, so they don't all hit at once
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    last_run_at TIMESTAMP WITH TIME ZONE,
+    last_batch_id BIGINT REFERENCES batches(id) ON DELETE SET NULL,
+    next_run_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+GRANT ALL PRIVILEGES ON TABLE schedules TO vinylretailers;
+
+-- every retailer every 30 minutes, as the scheduler always has
+INSERT INTO schedules (name, cron) VALUES ('default', '*/30 * * * *');
@@ -0,0 +1,2 @@
+
+DROP TABLE scan_intervals;
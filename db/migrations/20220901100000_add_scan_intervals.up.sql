@@ -0,0 +1,16 @@
+
+-- how often each artist is scanned at each retailer, adapted by the scheduler to how often the artist's listings there
+-- change (from the sku history)
+CREATE TABLE IF NOT EXISTS scan_intervals (
+    artist_id BIGINT NOT NULL REFERENCES artists(id) ON DELETE CASCADE,
+    retailer_id BIGINT NOT NULL REFERENCES retailers(id) ON DELETE CASCADE,
+    interval_secs INT NOT NULL,
+    changes INT NOT NULL DEFAULT 0, -- sku changes within the change window
+    recent_arrivals INT NOT NULL DEFAULT 0, -- new listings and restocks within the recent window
+    last_change_at TIMESTAMP WITH TIME ZONE,
+    last_scheduled_at TIMESTAMP WITH TIME ZONE,
+    next_scan_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (artist_id, retailer_id)
+);
+GRANT ALL PRIVILEGES ON TABLE scan_intervals TO vinylretailers;
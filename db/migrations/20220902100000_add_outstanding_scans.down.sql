@@ -0,0 +1,3 @@
+
+DROP TABLE outstanding_scans;
+ALTER TABLE retailers DROP COLUMN enabled;
@@ -0,0 +1,14 @@
+
+-- retailers can be disabled, so they aren't scanned, without losing their listings
+ALTER TABLE retailers ADD COLUMN IF NOT EXISTS enabled BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- the artists at each retailer with a scan request queued and not yet done with, and the batch that queued it, so the
+-- scheduler doesn't queue the same scan again while it's waiting
+CREATE TABLE IF NOT EXISTS outstanding_scans (
+    artist_id BIGINT NOT NULL REFERENCES artists(id) ON DELETE CASCADE,
+    retailer_id BIGINT NOT NULL REFERENCES retailers(id) ON DELETE CASCADE,
+    batch_id BIGINT NOT NULL REFERENCES batches(id) ON DELETE CASCADE,
+    queued_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (artist_id, retailer_id)
+);
+GRANT ALL PRIVILEGES ON TABLE outstanding_scans TO vinylretailers;
@@ -0,0 +1,3 @@
+
+DROP INDEX IF EXISTS retailers_scraper_key_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS retailers_scraper_key_idx ON retailers (scraper_key) WHERE scraper_key <> 'shopify';
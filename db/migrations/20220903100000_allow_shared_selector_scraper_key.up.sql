@@ -0,0 +1,6 @@
+
+-- selector scrapers are defined entirely by the retailer row, so like the generic shopify scraper any number of
+-- retailers can use one
+DROP INDEX IF EXISTS retailers_scraper_key_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS retailers_scraper_key_idx ON retailers (scraper_key)
+    WHERE scraper_key NOT IN ('shopify', 'selector');
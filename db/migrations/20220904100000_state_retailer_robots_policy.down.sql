@@ -0,0 +1,12 @@
+
+CREATE OR REPLACE VIEW retailer_batch_health AS
+    SELECT retailer_id, batch_id, MIN(started_at) AS started_at, COUNT(*) AS scans,
+        COUNT(*) FILTER (WHERE status = 'succeeded') AS succeeded,
+        COALESCE(SUM(result_count) FILTER (WHERE status = 'succeeded'), 0) AS results
+    FROM scans
+    GROUP BY retailer_id, batch_id;
+
+UPDATE retailers SET enabled = TRUE WHERE disabled_reason IS NOT NULL;
+ALTER TABLE retailers DROP COLUMN disabled_reason;
+
+UPDATE retailers SET scraper_config = scraper_config #- '{fetch,robots}';
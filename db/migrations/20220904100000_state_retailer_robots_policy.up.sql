@@ -0,0 +1,24 @@
+
+-- each retailer states whether its robots.txt is obeyed, and they all are: none has agreed to be scanned regardless.
+-- a store that does can be set to 'ignore'
+UPDATE retailers
+SET scraper_config = jsonb_set(scraper_config, '{fetch}', COALESCE(scraper_config->'fetch', '{}') || '{"robots": "obey"}')
+WHERE scraper_config->'fetch'->'robots' IS NULL;
+
+-- why a retailer has been disabled, so it isn't mistaken for one that's broken
+ALTER TABLE retailers ADD COLUMN IF NOT EXISTS disabled_reason TEXT;
+
+-- shopify's default robots.txt disallows /search, which these stores' scrapers search. dutch vinyl (/a/search) and
+-- oh jean records (/apps/omega-search) search through apps that it allows
+UPDATE retailers
+SET enabled = FALSE, disabled_reason = 'robots.txt disallows /search, which its shopify scraper searches'
+WHERE scraper_key IN ('poisoncity', 'utopia', 'musicfarmers', 'grevillerecords');
+
+-- a scan the retailer's robots.txt refused isn't the retailer failing, so it doesn't count towards its health
+CREATE OR REPLACE VIEW retailer_batch_health AS
+    SELECT retailer_id, batch_id, MIN(started_at) AS started_at, COUNT(*) AS scans,
+        COUNT(*) FILTER (WHERE status = 'succeeded') AS succeeded,
+        COALESCE(SUM(result_count) FILTER (WHERE status = 'succeeded'), 0) AS results
+    FROM scans
+    WHERE status <> 'disallowed'
+    GROUP BY retailer_id, batch_id;
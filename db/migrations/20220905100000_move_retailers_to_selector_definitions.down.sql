@@ -0,0 +1,7 @@
+
+UPDATE retailers SET scraper_key = 'clarityrecords', scraper_config = '{"fetch": {"robots": "obey"}}'
+WHERE scraper_key = 'selector' AND scraper_config->>'searchUrl' LIKE 'https://clarityrecords.net/%';
+UPDATE retailers SET scraper_key = 'repressedrecords', scraper_config = '{"fetch": {"robots": "obey"}}'
+WHERE scraper_key = 'selector' AND scraper_config->>'searchUrl' LIKE 'https://repressedrecords.com/%';
+UPDATE retailers SET scraper_key = 'strangeworldrecords', scraper_config = '{"fetch": {"robots": "obey"}}'
+WHERE scraper_key = 'selector' AND scraper_config->>'searchUrl' LIKE 'https://www.strangeworldrecords.com.au/%';
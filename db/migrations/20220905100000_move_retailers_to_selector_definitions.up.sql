@@ -0,0 +1,10 @@
+
+-- these retailers' search results are read by selector scraper definitions (retailers/definitions/<scraper key>.json,
+-- checked by the fixture tests) in place of hand written scrapers. a definition changed in the repo is copied here by
+-- a migration of its own
+UPDATE retailers SET scraper_key = 'selector', scraper_config = '{"searchUrl": "https://clarityrecords.net/search.php?search_query=%s+vinyl&section=product", "item": "article.card", "title": {"attr": "data-name"}, "url": {"selector": ".card-figure a", "attr": "href"}, "image": {"selector": ".card-image", "attr": "data-src"}, "price": {"attr": "data-product-price"}, "soldOut": ".card-figcaption-button:contains(\"Sold out\")", "nextPage": "link[rel=next]", "fetch": {"robots": "obey"}}'
+WHERE scraper_key = 'clarityrecords';
+UPDATE retailers SET scraper_key = 'selector', scraper_config = '{"searchUrl": "https://repressedrecords.com/search?q=%s+vinyl&options[prefix]=last", "item": ".product-card", "title": {"selector": ".product-card__title"}, "url": {"selector": "a.grid-view-item__link", "attr": "href"}, "image": {"selector": "noscript img", "attr": "src"}, "price": {"selector": ".price:not(.price--on-sale) .price__regular .price-item--regular, .price--on-sale .price__sale .price-item--sale"}, "soldOut": ".grid-view-item--sold-out", "nextPage": ".pagination li:last-child a", "fetch": {"robots": "obey"}}'
+WHERE scraper_key = 'repressedrecords';
+UPDATE retailers SET scraper_key = 'selector', scraper_config = '{"searchUrl": "https://www.strangeworldrecords.com.au/search?q=%s+vinyl", "item": ".grid:has(.one-fifth a[title]):not(:has(.grid))", "title": {"selector": ".one-fifth a", "attr": "title"}, "titleRegex": "^\\s*(?P<artist>.+?)\\s*-\\s+(?P<title>.+?)\\s*$", "url": {"selector": ".one-fifth a", "attr": "href"}, "image": {"selector": ".one-fifth img", "attr": "src"}, "price": {"selector": "[itemprop=price]"}, "soldOut": ".badge--sold-out", "nextPage": ".pagination-custom a:contains(\"→\")", "fetch": {"robots": "obey"}}'
+WHERE scraper_key = 'strangeworldrecords';
@@ -0,0 +1,10 @@
+
+DROP INDEX IF EXISTS scans_retailer_started_idx;
+CREATE OR REPLACE VIEW retailer_batch_health AS
+    SELECT retailer_id, batch_id, MIN(started_at) AS started_at, COUNT(*) AS scans,
+        COUNT(*) FILTER (WHERE status = 'succeeded') AS succeeded,
+        COALESCE(SUM(result_count) FILTER (WHERE status = 'succeeded'), 0) AS results
+    FROM scans
+    WHERE status <> 'disallowed'
+    GROUP BY retailer_id, batch_id;
+GRANT ALL PRIVILEGES ON TABLE retailer_batch_health TO vinylretailers;
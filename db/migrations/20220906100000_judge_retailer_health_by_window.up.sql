@@ -0,0 +1,4 @@
+
+-- retailer health is judged over a window of time rather than batch by batch, as a small batch isn't a fair sample
+DROP VIEW IF EXISTS retailer_batch_health;
+CREATE INDEX IF NOT EXISTS scans_retailer_started_idx ON scans (retailer_id, started_at);
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"github.com/gavinturner/vinylretailers/util/postgres"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// OutstandingScan is an artist at a retailer with a scan request queued (or being retried) for a batch.
+type OutstandingScan struct {
+	ArtistID   int64     `db:"artist_id" json:"artistId"`
+	RetailerID int64     `db:"retailer_id" json:"retailerId"`
+	BatchID    int64     `db:"batch_id" json:"batchId"`
+	QueuedAt   time.Time `db:"queued_at" json:"queuedAt"`
+}
+
+// GetOutstandingScans returns the scans queued since the given time that haven't been done with. Older ones are
+// taken to have been lost from the queue.
+func (v *VinylDB) GetOutstandingScans(tx *postgres.Tx, queuedSince time.Time) ([]OutstandingScan, error) {
+	querier := v.Q(tx)
+	scans := []OutstandingScan{}
+	err := querier.Select(&scans, querier.Rebind(`
+		SELECT artist_id, retailer_id, batch_id, queued_at
+		FROM outstanding_scans
+		WHERE queued_at >= ?
+	`), queuedSince)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve outstanding scans")
+	}
+	return scans, nil
+}
+
+// AddOutstandingScans records the scans as queued for the batch, taking over any that were already outstanding for
+// an earlier batch. A scan that is taken over is cleared for the earlier batch and counted as a failed search of it,
+// as its request won't count towards that batch when it's done, and without it that batch would never finish.
+func (v *VinylDB) AddOutstandingScans(tx *postgres.Tx, batchID int64, scans []OutstandingScan) error {
+	querier := v.Q(tx)
+	for _, s := range scans {
+		takenOver := []int64{}
+		err := querier.Select(&takenOver, querier.Rebind(`
+			DELETE FROM outstanding_scans WHERE artist_id = ? AND retailer_id = ? AND batch_id <> ?
+			RETURNING batch_id
+		`), s.ArtistID, s.RetailerID, batchID)
+		if err != nil {
+			return errors.Wrapf(err, "failed to take over outstanding scan of artist %v at retailer %v", s.ArtistID, s.RetailerID)
+		}
+		for _, earlierBatchID := range takenOver {
+			_, err = v.IncrementBatchSearchFailedCount(tx, earlierBatchID)
+			if err != nil {
+				return errors.Wrapf(err, "failed to count taken over scan of artist %v at retailer %v", s.ArtistID, s.RetailerID)
+			}
+		}
+		_, err = querier.Exec(querier.Rebind(`
+			INSERT INTO outstanding_scans (artist_id, retailer_id, batch_id) VALUES (?, ?, ?)
+			ON CONFLICT (artist_id, retailer_id) DO UPDATE SET queued_at = NOW()
+		`), s.ArtistID, s.RetailerID, batchID)
+		if err != nil {
+			return errors.Wrapf(err, "failed to add outstanding scan of artist %v at retailer %v", s.ArtistID, s.RetailerID)
+		}
+	}
+	return nil
+}
+
+// ClearOutstandingScan records the batch's scan of the artist at the retailer as done with (whether it succeeded or
+// was given up on), returning false if it already had been. A scan since queued by another batch is left
+// outstanding.
+func (v *VinylDB) ClearOutstandingScan(tx *postgres.Tx, batchID int64, artistID int64, retailerID int64) (bool, error) {
+	querier := v.Q(tx)
+	result, err := querier.Exec(querier.Rebind(`
+		DELETE FROM outstanding_scans WHERE artist_id = ? AND retailer_id = ? AND batch_id = ?
+	`), artistID, retailerID, batchID)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to clear outstanding scan of artist %v at retailer %v", artistID, retailerID)
+	}
+	cleared, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to clear outstanding scan of artist %v at retailer %v", artistID, retailerID)
+	}
+	return cleared > 0, nil
+}
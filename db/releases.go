@@ -1,8 +1,13 @@
 package db
 
 import (
+	"fmt"
+	"github.com/gavinturner/vinylretailers/retailers"
 	"github.com/gavinturner/vinylretailers/util/postgres"
 	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -26,3 +31,370 @@ func (v *VinylDB) UpsertRelease(tx *postgres.Tx, artistId int64, title string) (
 	}
 	return id, err
 }
+
+// ReleaseReviewStatus is where a low confidence match is in the review queue.
+type ReleaseReviewStatus string
+
+const (
+	ReleaseReview_Pending  ReleaseReviewStatus = "pending"
+	ReleaseReview_Accepted ReleaseReviewStatus = "accepted" // the listing's release was merged into the candidate
+	ReleaseReview_Rejected ReleaseReviewStatus = "rejected" // they are different releases
+)
+
+// ReleaseReview is a listing that might be an existing (candidate) release, queued for someone to check.
+type ReleaseReview struct {
+	ID                 int64               `db:"id" json:"id"`
+	ReleaseID          null.Int            `db:"release_id" json:"releaseId"` // null once it has been merged into the candidate
+	CandidateReleaseID int64               `db:"candidate_release_id" json:"candidateReleaseId"`
+	CandidateTitle     string              `db:"candidate_title" json:"candidateTitle"`
+	RetailerID         null.Int            `db:"retailer_id" json:"retailerId"`
+	ListingTitle       string              `db:"listing_title" json:"listingTitle"`
+	Score              float64             `db:"score" json:"score"`
+	Status             ReleaseReviewStatus `db:"status" json:"status"`
+	CreatedAt          time.Time           `db:"created_at" json:"createdAt"`
+	ResolvedAt         null.Time           `db:"resolved_at" json:"resolvedAt"`
+}
+
+// ReleaseMatch is the release a listing was matched to.
+type ReleaseMatch struct {
+	ReleaseID int64
+	Score     float64        // similarity of the listing's title to the release's (1 for an exact match)
+	Code      string         // the barcode or catalogue number the listing was matched on, if it was
+	Review    *ReleaseReview // set if the listing was queued for review against an existing release
+}
+
+// ReleaseCodeKind is the kind of code identifying a release.
+type ReleaseCodeKind string
+
+const (
+	ReleaseCode_Barcode         ReleaseCodeKind = "barcode"          // EAN-13
+	ReleaseCode_CatalogueNumber ReleaseCodeKind = "catalogue_number" // the label's, normalised
+)
+
+func (v *VinylDB) GetReleasesForArtist(tx *postgres.Tx, artistID int64) ([]Release, error) {
+	querier := v.Q(tx)
+	releases := []Release{}
+	err := querier.Select(&releases, querier.Rebind(`
+		SELECT id, title AS name, artist_id, created_at, updated_at
+		FROM releases
+		WHERE artist_id = ?
+		ORDER BY id
+	`), artistID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve releases for artist %v", artistID)
+	}
+	return releases, nil
+}
+
+// MatchRelease finds the canonical release for a retailer's listing of one of the artist's releases, so that
+// listings of the same release from every retailer share it however each titles it. A listing the retailer has
+// linked to before is the release it was matched to then. Otherwise a barcode seen before on a listing of a release
+// is the strongest signal and matches whatever the title, followed by a catalogue number as long as the titles are
+// at least similar. Failing that titles are compared once normalised: an exact match (or a title matched before) is
+// the release, as is a title similar enough to match automatically. Otherwise the listing gets a release of its own,
+// and if it is close to an existing release the pair are queued for review. The listing's codes are recorded
+// against the release it is matched to.
+//
+// The sku history of a release is kept per retailer, so a release the retailer lists under a different link (another
+// pressing or edition titled alike, or a second copy) is never matched: the listing gets a release of its own.
+func (v *VinylDB) MatchRelease(tx *postgres.Tx, artistID int64, retailerID int64, title string, itemURL string, barcodes []string, catalogueNos []string) (match ReleaseMatch, err error) {
+	querier := v.Q(tx)
+	normalised := retailers.NormaliseTitle(title)
+	if normalised == "" {
+		normalised = strings.ToLower(strings.TrimSpace(title))
+	}
+	defer func() {
+		if err == nil {
+			err = v.addReleaseCodes(tx, artistID, match.ReleaseID, barcodes, catalogueNos)
+		}
+	}()
+
+	listed, err := v.GetCurrentSKUs(tx, artistID, retailerID)
+	if err != nil {
+		return match, err
+	}
+	taken := map[int64]struct{}{}
+	urlKey := retailers.ProductURLKey(itemURL)
+	for _, sku := range listed {
+		skuKey := retailers.ProductURLKey(sku.ItemUrl)
+		if urlKey == "" || skuKey == "" {
+			// listings without links can't be told apart
+			continue
+		}
+		if skuKey == urlKey {
+			return ReleaseMatch{ReleaseID: sku.ReleaseID, Score: 1}, nil
+		}
+		if sku.Price.Availability != retailers.Availability_Delisted {
+			taken[sku.ReleaseID] = struct{}{}
+		}
+	}
+	isTaken := func(releaseID int64) bool {
+		_, ok := taken[releaseID]
+		return ok
+	}
+
+	for _, codes := range []struct {
+		kind   ReleaseCodeKind
+		values []string
+	}{{ReleaseCode_Barcode, barcodes}, {ReleaseCode_CatalogueNumber, catalogueNos}} {
+		for _, code := range codes.values {
+			coded := []Release{}
+			err = querier.Select(&coded, querier.Rebind(`
+				SELECT r.id, r.title AS name, r.artist_id, r.created_at, r.updated_at
+				FROM release_codes rc
+				JOIN releases r ON rc.release_id = r.id
+				WHERE rc.artist_id = ? AND rc.kind = ? AND rc.value = ?
+			`), artistID, string(codes.kind), code)
+			if err != nil {
+				return match, errors.Wrapf(err, "failed to retrieve release by %s '%s'", codes.kind, code)
+			}
+			if len(coded) == 0 || isTaken(coded[0].ID) {
+				continue
+			}
+			// labels' catalogue numbers can collide with each other (and with stock codes), so unlike a barcode
+			// one only matches a release titled close enough that it would at least be reviewed
+			score := retailers.TitleSimilarity(normalised, retailers.NormaliseTitle(coded[0].Name))
+			if codes.kind == ReleaseCode_CatalogueNumber && score < retailers.TITLE_MATCH_REVIEW {
+				continue
+			}
+			match = ReleaseMatch{ReleaseID: coded[0].ID, Score: 1, Code: code}
+			return match, v.addReleaseAlias(tx, artistID, match.ReleaseID, normalised)
+		}
+	}
+
+	var aliased []int64
+	err = querier.Select(&aliased, querier.Rebind(`
+		SELECT release_id FROM release_aliases WHERE artist_id = ? AND normalised_title = ?
+	`), artistID, normalised)
+	if err != nil {
+		return match, errors.Wrapf(err, "failed to retrieve release aliases")
+	}
+	if len(aliased) > 0 && !isTaken(aliased[0]) {
+		return ReleaseMatch{ReleaseID: aliased[0], Score: 1}, nil
+	}
+	releases, err := v.GetReleasesForArtist(tx, artistID)
+	if err != nil {
+		return match, err
+	}
+	var candidate *Release
+	for i, release := range releases {
+		if isTaken(release.ID) {
+			continue
+		}
+		score := retailers.TitleSimilarity(normalised, retailers.NormaliseTitle(release.Name))
+		if score > match.Score {
+			match.Score, candidate = score, &releases[i]
+		}
+	}
+
+	if candidate != nil && match.Score >= retailers.TITLE_MATCH_AUTO {
+		match.ReleaseID = candidate.ID
+	} else {
+		// a release the retailer lists under another link can have the same title, so number this one's
+		releaseTitle := title
+		for n := 2; ; n++ {
+			match.ReleaseID, err = v.UpsertRelease(tx, artistID, releaseTitle)
+			if err != nil {
+				return match, err
+			}
+			if !isTaken(match.ReleaseID) {
+				break
+			}
+			releaseTitle = fmt.Sprintf("%s (%d)", title, n)
+		}
+	}
+	err = v.addReleaseAlias(tx, artistID, match.ReleaseID, normalised)
+	if err != nil {
+		return match, err
+	}
+	if candidate == nil || match.Score >= retailers.TITLE_MATCH_AUTO || match.Score < retailers.TITLE_MATCH_REVIEW {
+		return match, nil
+	}
+	match.Review = &ReleaseReview{
+		ReleaseID:          null.IntFrom(match.ReleaseID),
+		CandidateReleaseID: candidate.ID,
+		CandidateTitle:     candidate.Name,
+		RetailerID:         null.IntFrom(retailerID),
+		ListingTitle:       title,
+		Score:              match.Score,
+		Status:             ReleaseReview_Pending,
+	}
+	err = querier.Get(&match.Review.ID, querier.Rebind(`
+		INSERT INTO release_reviews (release_id, candidate_release_id, retailer_id, listing_title, score)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (release_id, candidate_release_id) DO UPDATE SET listing_title = EXCLUDED.listing_title
+		RETURNING id
+	`), match.Review.ReleaseID, match.Review.CandidateReleaseID, match.Review.RetailerID, title, match.Score)
+	if err != nil {
+		return match, errors.Wrapf(err, "failed to queue release review")
+	}
+	return match, nil
+}
+
+func (v *VinylDB) addReleaseAlias(tx *postgres.Tx, artistID int64, releaseID int64, normalised string) error {
+	querier := v.Q(tx)
+	_, err := querier.Exec(querier.Rebind(`
+		INSERT INTO release_aliases (release_id, artist_id, normalised_title) VALUES (?, ?, ?)
+		ON CONFLICT (artist_id, normalised_title) DO NOTHING
+	`), releaseID, artistID, normalised)
+	if err != nil {
+		return errors.Wrapf(err, "failed to add release alias '%s'", normalised)
+	}
+	return nil
+}
+
+func (v *VinylDB) addReleaseCodes(tx *postgres.Tx, artistID int64, releaseID int64, barcodes []string, catalogueNos []string) error {
+	querier := v.Q(tx)
+	for kind, values := range map[ReleaseCodeKind][]string{ReleaseCode_Barcode: barcodes, ReleaseCode_CatalogueNumber: catalogueNos} {
+		for _, value := range values {
+			_, err := querier.Exec(querier.Rebind(`
+				INSERT INTO release_codes (release_id, artist_id, kind, value) VALUES (?, ?, ?, ?)
+				ON CONFLICT (artist_id, kind, value) DO NOTHING
+			`), releaseID, artistID, string(kind), value)
+			if err != nil {
+				return errors.Wrapf(err, "failed to add release %s '%s'", kind, value)
+			}
+		}
+	}
+	return nil
+}
+
+// GetReleasesByBarcode returns the releases listed with the barcode (EAN-13 or UPC-A), one for each artist on it
+// (so more than one for a split release), or none if no retailer has listed it.
+func (v *VinylDB) GetReleasesByBarcode(tx *postgres.Tx, barcode string) ([]Release, error) {
+	querier := v.Q(tx)
+	releases := []Release{}
+	normalised := retailers.NormaliseBarcode(barcode)
+	if normalised == "" {
+		return nil, errors.Errorf("'%s' is not a valid barcode", barcode)
+	}
+	err := querier.Select(&releases, querier.Rebind(`
+		SELECT r.id, r.title AS name, r.artist_id, r.created_at, r.updated_at
+		FROM release_codes rc
+		JOIN releases r ON rc.release_id = r.id
+		WHERE rc.kind = ? AND rc.value = ?
+		ORDER BY r.id
+	`), string(ReleaseCode_Barcode), normalised)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve releases with barcode %s", normalised)
+	}
+	return releases, nil
+}
+
+func (v *VinylDB) GetPendingReleaseReviews(tx *postgres.Tx) ([]ReleaseReview, error) {
+	querier := v.Q(tx)
+	reviews := []ReleaseReview{}
+	err := querier.Select(&reviews, querier.Rebind(`
+		SELECT rr.id, rr.release_id, rr.candidate_release_id, r.title AS candidate_title, rr.retailer_id, rr.listing_title,
+			rr.score, rr.status, rr.created_at, rr.resolved_at
+		FROM release_reviews rr
+		JOIN releases r ON rr.candidate_release_id = r.id
+		WHERE rr.status = ?
+		ORDER BY rr.score DESC, rr.id
+	`), string(ReleaseReview_Pending))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve pending release reviews")
+	}
+	return reviews, nil
+}
+
+// ReleaseMergeConflict is why a review can't be accepted: both releases are listed at the same retailers, and merging
+// them would interleave the two listings in one price history.
+type ReleaseMergeConflict struct {
+	ReleaseID          int64
+	CandidateReleaseID int64
+	RetailerIDs        []int64
+}
+
+func (e *ReleaseMergeConflict) Error() string {
+	return fmt.Sprintf("releases %v and %v are both listed at retailers %v", e.ReleaseID, e.CandidateReleaseID, e.RetailerIDs)
+}
+
+// ResolveReleaseReview accepts or rejects a queued match. Accepting merges the listing's release into the candidate:
+// its skus, aliases and codes move to the candidate (so it is matched automatically from now on) and it is deleted, along
+// with any other pending reviews of it. The review is kept as a record of the decision. A review of two releases that
+// are both listed at the same retailer is refused with a ReleaseMergeConflict, and should be rejected instead.
+func (v *VinylDB) ResolveReleaseReview(tx *postgres.Tx, reviewID int64, accept bool) error {
+	querier := v.Q(tx)
+	status := ReleaseReview_Rejected
+	if accept {
+		status = ReleaseReview_Accepted
+	}
+	review := ReleaseReview{}
+	err := querier.Get(&review, querier.Rebind(`
+		UPDATE release_reviews SET status = ?, resolved_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?
+		RETURNING id, release_id, candidate_release_id
+	`), string(status), reviewID, string(ReleaseReview_Pending))
+	if err != nil {
+		return errors.Wrapf(err, "failed to resolve release review %v (is it still pending?)", reviewID)
+	}
+	if !accept {
+		return nil
+	}
+	releaseID := review.ReleaseID.Int64
+	listings, err := v.GetReleasePrices(tx, releaseID)
+	if err != nil {
+		return err
+	}
+	candidateListings, err := v.GetReleasePrices(tx, review.CandidateReleaseID)
+	if err != nil {
+		return err
+	}
+	if shared := sharedRetailers(listings, candidateListings); len(shared) > 0 {
+		return &ReleaseMergeConflict{ReleaseID: releaseID, CandidateReleaseID: review.CandidateReleaseID, RetailerIDs: shared}
+	}
+	for _, query := range []string{
+		`UPDATE skus SET release_id = ? WHERE release_id = ?`,
+		`UPDATE release_aliases SET release_id = ? WHERE release_id = ?`,
+		`UPDATE release_codes SET release_id = ? WHERE release_id = ?`,
+	} {
+		_, err = querier.Exec(querier.Rebind(query), review.CandidateReleaseID, releaseID)
+		if err != nil {
+			return errors.Wrapf(err, "failed to merge release %v into %v", releaseID, review.CandidateReleaseID)
+		}
+	}
+	_, err = querier.Exec(querier.Rebind(`DELETE FROM release_reviews WHERE release_id = ? AND status = ?`), releaseID, string(ReleaseReview_Pending))
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete pending reviews of merged release %v", releaseID)
+	}
+	// resolved reviews of the release (this one included) are kept, with their release_id nulled
+	_, err = querier.Exec(querier.Rebind(`DELETE FROM releases WHERE id = ?`), releaseID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete merged release %v", releaseID)
+	}
+	return nil
+}
+
+// sharedRetailers returns the retailers with a listing of both releases, in order.
+func sharedRetailers(listings []SKU, candidateListings []SKU) []int64 {
+	listed := map[int64]struct{}{}
+	for _, sku := range listings {
+		listed[sku.RetailerID] = struct{}{}
+	}
+	shared := []int64{}
+	for _, sku := range candidateListings {
+		if _, ok := listed[sku.RetailerID]; ok {
+			shared = append(shared, sku.RetailerID)
+			delete(listed, sku.RetailerID)
+		}
+	}
+	sort.Slice(shared, func(i, j int) bool { return shared[i] < shared[j] })
+	return shared
+}
+
+// GetReleasePrices returns the current sku for the release at each retailer that lists it.
+func (v *VinylDB) GetReleasePrices(tx *postgres.Tx, releaseID int64) ([]SKU, error) {
+	querier := v.Q(tx)
+	skus := []SKU{}
+	err := querier.Select(&skus, querier.Rebind(`
+		SELECT DISTINCT ON (retailer_id) `+SKU_COLUMNS+`
+		FROM skus
+		WHERE release_id = ?
+		ORDER BY retailer_id, created_at DESC, id DESC
+	`), releaseID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve prices for release %v", releaseID)
+	}
+	return skus, nil
+}
@@ -0,0 +1,25 @@
+//go:build unit_test
+// +build unit_test
+
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleases_MergeConflict(t *testing.T) {
+	t.Parallel()
+
+	// the listing's release is at retailers 1 and 3, the candidate at 3 and 2, so merging them would leave retailer 3
+	// with two listings of the one release
+	listings := []SKU{{ID: 1, RetailerID: 1}, {ID: 2, RetailerID: 3}}
+	candidateListings := []SKU{{ID: 3, RetailerID: 3}, {ID: 4, RetailerID: 2}}
+	assert.Equal(t, []int64{3}, sharedRetailers(listings, candidateListings))
+
+	assert.Empty(t, sharedRetailers(listings, []SKU{{ID: 4, RetailerID: 2}}), "Expected releases at different retailers to merge")
+
+	conflict := &ReleaseMergeConflict{ReleaseID: 10, CandidateReleaseID: 11, RetailerIDs: []int64{3}}
+	assert.Equal(t, "releases 10 and 11 are both listed at retailers [3]", conflict.Error())
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/gavinturner/vinylretailers/retailers"
 	"github.com/gavinturner/vinylretailers/util/postgres"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 	"gopkg.in/guregu/null.v3"
 	"time"
@@ -14,6 +15,8 @@ type Batch struct {
 	ID                   int64     `db:"id" json:"id"`
 	NumRequiredSearches  int       `db:"req_searches" json:"numRequiredSearches"`
 	NumCompletedSearches int       `db:"completed_searches" json:"numCompletedSearches"`
+	NumFailedSearches    int       `db:"failed_searches" json:"numFailedSearches"`
+	NumRetriedSearches   int       `db:"retried_searches" json:"numRetriedSearches"`
 	CreatedAt            time.Time `db:"created_at"`
 	UpdatedAt            time.Time `db:"updated_at"`
 	ReportedAt           time.Time `db:"reported_at" json:"reportedAt"`
@@ -36,14 +39,18 @@ type BatchedReport struct {
 	BatchID   int64  `db:"batch_id" json:"batchId"`
 }
 
-//
+// ReportSKU is a sku attached to a report, along with why it was reported
+type ReportSKU struct {
+	retailers.SKU
+	Change SKUChange `db:"change" json:"change"`
+}
+
 // AddNewBatch
 // Create a new batch instance, representing one full set of scans to be completed over the set of users
 // currently watching a set of artists. This batch instance will have a set of empty reports connected to
 // it, one per watching user, and each such report will cover the set of artists currently being watched
 // by the user for whom the report pertains. The batch also stores the required number of searches to be
 // performed (artist x retailer) so that we can keep track of whether a batch has been completed or not.
-//
 func (v *VinylDB) AddNewBatch(tx *postgres.Tx, numRequiredSearches int, userArtists map[int64][]WatchedArtist) (batchId int64, err error) {
 	querier := v.Q(tx)
 
@@ -113,55 +120,108 @@ func (v *VinylDB) DeleteBatch(tx *postgres.Tx, batchId int64) error {
 	return nil
 }
 
-//
 // IncrementBatchSearchCompletedCount
 // When scanning is complete for a specific artist + retailer, this method allows the scanner to increment
 // the number of scans completed that is stored against the batch. We use UPDATE here as an atomic operation
-// on the batches table and as such this method will be thread-safe in terms of getting all increments.
-//
-func (v *VinylDB) IncrementBatchSearchCompletedCount(tx *postgres.Tx, batchId int64) error {
+// on the batches table and as such this method will be thread-safe in terms of getting all increments. Returns
+// whether this search completed the batch, which is only true for one of them.
+func (v *VinylDB) IncrementBatchSearchCompletedCount(tx *postgres.Tx, batchId int64) (batchCompleted bool, err error) {
 	querier := v.Q(tx)
-	rows, err := querier.Exec(querier.Rebind(`
+	err = querier.Get(&batchCompleted, querier.Rebind(`
 		UPDATE batches SET completed_searches = completed_searches+1 WHERE id = ?
+		RETURNING COALESCE(completed_searches = req_searches, FALSE)
 	`), batchId)
-	if err != nil {
-		return errors.Wrapf(err, "failed to add new batch")
+	if err == sql.ErrNoRows {
+		return false, fmt.Errorf("batch %v was not incremented. does it exist?", batchId)
+	} else if err != nil {
+		return false, errors.Wrapf(err, "failed to add new batch")
 	}
-	affected, err := rows.RowsAffected()
-	if err != nil {
-		return errors.Wrapf(err, "failed to get rows affected")
+	return batchCompleted, nil
+}
+
+// IncrementBatchSearchFailedCount
+// When scanning an artist + retailer has failed for good (retrying won't help, or it has been retried as many
+// times as it can be), this method counts the search as completed, so the batch can still finish, and as failed.
+// Returns whether this search completed the batch.
+func (v *VinylDB) IncrementBatchSearchFailedCount(tx *postgres.Tx, batchId int64) (batchCompleted bool, err error) {
+	querier := v.Q(tx)
+	err = querier.Get(&batchCompleted, querier.Rebind(`
+		UPDATE batches SET completed_searches = completed_searches+1, failed_searches = failed_searches+1 WHERE id = ?
+		RETURNING COALESCE(completed_searches = req_searches, FALSE)
+	`), batchId)
+	if err == sql.ErrNoRows {
+		return false, fmt.Errorf("batch %v was not incremented. does it exist?", batchId)
+	} else if err != nil {
+		return false, errors.Wrapf(err, "failed to increment failed searches for batch %v", batchId)
 	}
-	if affected == 0 {
-		return fmt.Errorf("batch %v was not incremented. does it exist?", batchId)
+	return batchCompleted, nil
+}
+
+// IncrementBatchSearchRetriedCount counts a scan that failed and was requeued to be retried against the batch.
+func (v *VinylDB) IncrementBatchSearchRetriedCount(tx *postgres.Tx, batchId int64) error {
+	querier := v.Q(tx)
+	_, err := querier.Exec(querier.Rebind(`
+		UPDATE batches SET retried_searches = retried_searches+1 WHERE id = ?
+	`), batchId)
+	if err != nil {
+		return errors.Wrapf(err, "failed to increment retried searches for batch %v", batchId)
 	}
 	return nil
 }
 
-//
 // AddSKUToReportsForBatch
 // Given a particular SKU sound for an artist + retailer, this method looks at all the reports attached to the
 // current batch, determines if the SKU artist is covered by the report, and if so attaches the SKU to the final
 // report. We assume that it has already been determined whether the SKU represents a valid result for the report
 // (for example the price has changed).
-//
-func (v *VinylDB) AddSKUToReportsForBatch(tx *postgres.Tx, batchId int64, sku *SKU) error {
+func (v *VinylDB) AddSKUToReportsForBatch(tx *postgres.Tx, batchId int64, sku *SKU, change SKUChange) error {
 	querier := v.Q(tx)
-	reportIds := []int64{}
-	err := querier.Select(&reportIds, querier.Rebind(`
-		SELECT report_id FROM report_artists WHERE batch_id = ? AND artist_id = ?
-	`), batchId, sku.ArtistID)
+	// users can follow an artist for vinyl only, or only for particular pressings (matched against the edition of
+	// the listing or any of its variants)
+	vinyl := false
+	for _, format := range sku.Variants.Formats(sku.Format) {
+		vinyl = vinyl || format.IsVinyl()
+	}
+	editions := []string{sku.Edition}
+	for _, variant := range sku.Variants {
+		editions = append(editions, variant.Edition)
+	}
+	reports := []struct {
+		ReportID int64 `db:"report_id"`
+		Wanted   bool  `db:"wanted"`
+	}{}
+	err := querier.Select(&reports, querier.Rebind(`
+		SELECT
+			ra.report_id,
+			(NOT COALESCE(fa.vinyl_only, false) OR ?)
+				AND (COALESCE(fa.edition_filter, '') = ''
+					OR EXISTS (SELECT 1 FROM unnest(?::text[]) e WHERE e ILIKE '%' || fa.edition_filter || '%')) AS wanted
+		FROM report_artists ra
+			JOIN reports r ON ra.report_id = r.id
+			LEFT JOIN users_following_artists fa ON fa.user_id = r.user_id AND fa.artist_id = ra.artist_id
+		WHERE ra.batch_id = ? AND ra.artist_id = ?
+	`), vinyl, pq.Array(editions), batchId, sku.ArtistID)
 	if err != nil {
 		return errors.Wrapf(err, "failed to get reports for batch artist")
 	}
-	if len(reportIds) == 0 {
+	if len(reports) == 0 {
 		return fmt.Errorf("no reports for artist %v in batch %v", sku.ArtistID, batchId)
 	}
+	reportIds := []int64{}
+	for _, report := range reports {
+		if report.Wanted {
+			reportIds = append(reportIds, report.ReportID)
+		}
+	}
+	if len(reportIds) == 0 {
+		return nil
+	}
 
-	query := "INSERT INTO report_skus (report_id, sku_id) VALUES "
+	query := "INSERT INTO report_skus (report_id, sku_id, change) VALUES "
 	args := []interface{}{}
 	for idx, reportId := range reportIds {
-		args = append(args, reportId, sku.ID)
-		query += "(?, ?)"
+		args = append(args, reportId, sku.ID, string(change))
+		query += "(?, ?, ?)"
 		if idx < len(reportIds)-1 {
 			query += ","
 		}
@@ -173,6 +233,8 @@ func (v *VinylDB) AddSKUToReportsForBatch(tx *postgres.Tx, batchId int64, sku *S
 	return nil
 }
 
+// GetAllCompletedUnsentReports returns the unsent reports of batches whose searches are all done. Scan requests are
+// delivered at least once, so a search can be counted twice (the scanner died between committing and acking it).
 func (v *VinylDB) GetAllCompletedUnsentReports(tx *postgres.Tx) ([]BatchedReport, error) {
 	querier := v.Q(tx)
 	batches := []BatchedReport{}
@@ -189,7 +251,7 @@ func (v *VinylDB) GetAllCompletedUnsentReports(tx *postgres.Tx) ([]BatchedReport
 			JOIN users u ON r.user_id = u.id
 		WHERE 
 			b.reported_at IS NULL AND r.completed_at IS NULL
-			AND b.req_searches = b.completed_searches	
+			AND b.completed_searches >= b.req_searches
 	`)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get reports for batch artist")
@@ -272,9 +334,9 @@ func (v *VinylDB) DeleteReport(tx *postgres.Tx, reportId int64) error {
 	return nil
 }
 
-func (v *VinylDB) GetSkusForReport(tx *postgres.Tx, reportId int64) ([]retailers.SKU, error) {
+func (v *VinylDB) GetSkusForReport(tx *postgres.Tx, reportId int64) ([]ReportSKU, error) {
 	querier := v.Q(tx)
-	skus := []retailers.SKU{}
+	skus := []ReportSKU{}
 	err := querier.Select(&skus, querier.Rebind(`
 		SELECT
 			rt.name as retailer,
@@ -283,7 +345,14 @@ func (v *VinylDB) GetSkusForReport(tx *postgres.Tx, reportId int64) ([]retailers
 			r.title as name,
     		s.item_url,
     		s.image_url,
-    		s.price
+    		s.price_amount AS "price.amount",
+    		s.price_currency AS "price.currency",
+    		s.availability AS "price.availability",
+    		s.stock_quantity AS "price.stock",
+    		COALESCE(s.format, '') AS format,
+    		COALESCE(s.edition, '') AS edition,
+    		s.variants,
+    		rs.change
 		FROM 
 			report_skus rs 
 			JOIN skus s ON rs.sku_id = s.id
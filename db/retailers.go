@@ -2,23 +2,29 @@ package db
 
 import (
 	"github.com/gavinturner/vinylretailers/util/postgres"
+	"github.com/jmoiron/sqlx/types"
 	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
 	"time"
 )
 
 type Retailer struct {
-	ID        int64     `db:"id" json:"id"`
-	Name      string    `db:"name" json:"name"`
-	Url       string    `db:"url" json:"url"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
+	ID             int64          `db:"id" json:"id"`
+	Name           string         `db:"name" json:"name"`
+	Url            string         `db:"url" json:"url"`
+	ScraperKey     null.String    `db:"scraper_key" json:"scraperKey"`
+	ScraperConfig  types.JSONText `db:"scraper_config" json:"scraperConfig"`
+	Enabled        bool           `db:"enabled" json:"enabled"` // disabled retailers aren't scanned
+	DisabledReason null.String    `db:"disabled_reason" json:"disabledReason"`
+	CreatedAt      time.Time      `db:"created_at"`
+	UpdatedAt      time.Time      `db:"updated_at"`
 }
 
 func (v *VinylDB) GetAllRetailers(tx *postgres.Tx) ([]Retailer, error) {
 	querier := v.Q(tx)
 	retailers := []Retailer{}
 	err := querier.Select(&retailers, `
-		SELECT id, name, url, created_at, updated_at 
+		SELECT id, name, url, scraper_key, scraper_config, enabled, disabled_reason, created_at, updated_at 
 		FROM retailers
 	`)
 	if err != nil {
@@ -26,3 +32,20 @@ func (v *VinylDB) GetAllRetailers(tx *postgres.Tx) ([]Retailer, error) {
 	}
 	return retailers, nil
 }
+
+func (v *VinylDB) GetRetailer(tx *postgres.Tx, retailerId int64) (*Retailer, error) {
+	querier := v.Q(tx)
+	retailers := []Retailer{}
+	err := querier.Select(&retailers, querier.Rebind(`
+		SELECT id, name, url, scraper_key, scraper_config, enabled, disabled_reason, created_at, updated_at 
+		FROM retailers
+		WHERE id = ?
+	`), retailerId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve retailer %v", retailerId)
+	}
+	if len(retailers) == 0 {
+		return nil, nil
+	}
+	return &retailers[0], nil
+}
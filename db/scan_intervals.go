@@ -0,0 +1,104 @@
+package db
+
+import (
+	"github.com/gavinturner/vinylretailers/util/postgres"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+	"time"
+)
+
+// ListingChanges is how much an artist's listings at a retailer have changed, from the sku history.
+type ListingChanges struct {
+	ArtistID       int64     `db:"artist_id" json:"artistId"`
+	RetailerID     int64     `db:"retailer_id" json:"retailerId"`
+	Changes        int       `db:"changes" json:"changes"`                // new skus (price or availability changes)
+	RecentArrivals int       `db:"recent_arrivals" json:"recentArrivals"` // new listings and restocks
+	LastChangeAt   null.Time `db:"last_change_at" json:"lastChangeAt"`
+}
+
+// ScanInterval is how often an artist is scanned at a retailer.
+type ScanInterval struct {
+	ArtistID        int64     `db:"artist_id" json:"artistId"`
+	ArtistName      string    `db:"artist_name" json:"artistName"`
+	RetailerID      int64     `db:"retailer_id" json:"retailerId"`
+	RetailerName    string    `db:"retailer_name" json:"retailerName"`
+	IntervalSecs    int       `db:"interval_secs" json:"intervalSecs"`
+	Changes         int       `db:"changes" json:"changes"`
+	RecentArrivals  int       `db:"recent_arrivals" json:"recentArrivals"`
+	LastChangeAt    null.Time `db:"last_change_at" json:"lastChangeAt"`
+	LastScheduledAt null.Time `db:"last_scheduled_at" json:"lastScheduledAt"`
+	NextScanAt      null.Time `db:"next_scan_at" json:"nextScanAt"`
+	UpdatedAt       time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+// GetListingChanges returns how much each artist's listings at each retailer have changed since changesSince, and how
+// many have been newly listed or restocked since arrivalsSince. Artists that have never been listed at a retailer
+// aren't included.
+func (v *VinylDB) GetListingChanges(tx *postgres.Tx, changesSince time.Time, arrivalsSince time.Time) ([]ListingChanges, error) {
+	querier := v.Q(tx)
+	changes := []ListingChanges{}
+	err := querier.Select(&changes, querier.Rebind(`
+		WITH history AS (
+			SELECT artist_id, retailer_id, availability, created_at,
+				LAG(availability) OVER (PARTITION BY retailer_id, release_id ORDER BY created_at, id) AS previous
+			FROM skus
+		)
+		SELECT artist_id, retailer_id,
+			COUNT(*) FILTER (WHERE created_at >= ?) AS changes,
+			COUNT(*) FILTER (WHERE created_at >= ? AND availability NOT IN ('sold_out', 'delisted')
+				AND (previous IS NULL OR previous IN ('sold_out', 'delisted'))) AS recent_arrivals,
+			MAX(created_at) AS last_change_at
+		FROM history
+		GROUP BY artist_id, retailer_id
+	`), changesSince, arrivalsSince)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve listing changes")
+	}
+	return changes, nil
+}
+
+// GetScanIntervals returns the scan interval of each artist at each retailer (or just the one, if given).
+func (v *VinylDB) GetScanIntervals(tx *postgres.Tx, retailerID *int64) ([]ScanInterval, error) {
+	querier := v.Q(tx)
+	intervals := []ScanInterval{}
+	args := []interface{}{}
+	where := ""
+	if retailerID != nil {
+		where = "WHERE i.retailer_id = ?"
+		args = append(args, *retailerID)
+	}
+	err := querier.Select(&intervals, querier.Rebind(`
+		SELECT i.artist_id, a.name AS artist_name, i.retailer_id, r.name AS retailer_name, i.interval_secs, i.changes,
+			i.recent_arrivals, i.last_change_at, i.last_scheduled_at, i.next_scan_at, i.updated_at
+		FROM scan_intervals i
+		JOIN artists a ON i.artist_id = a.id
+		JOIN retailers r ON i.retailer_id = r.id
+		`+where+`
+		ORDER BY r.name, i.interval_secs, a.name
+	`), args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve scan intervals")
+	}
+	return intervals, nil
+}
+
+// SaveScanIntervals adds or updates the scan intervals.
+func (v *VinylDB) SaveScanIntervals(tx *postgres.Tx, intervals []ScanInterval) error {
+	querier := v.Q(tx)
+	for _, i := range intervals {
+		_, err := querier.Exec(querier.Rebind(`
+			INSERT INTO scan_intervals (artist_id, retailer_id, interval_secs, changes, recent_arrivals, last_change_at,
+				last_scheduled_at, next_scan_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (artist_id, retailer_id) DO UPDATE SET interval_secs = EXCLUDED.interval_secs,
+				changes = EXCLUDED.changes, recent_arrivals = EXCLUDED.recent_arrivals,
+				last_change_at = EXCLUDED.last_change_at, last_scheduled_at = EXCLUDED.last_scheduled_at,
+				next_scan_at = EXCLUDED.next_scan_at, updated_at = NOW()
+		`), i.ArtistID, i.RetailerID, i.IntervalSecs, i.Changes, i.RecentArrivals, i.LastChangeAt, i.LastScheduledAt,
+			i.NextScanAt)
+		if err != nil {
+			return errors.Wrapf(err, "failed to save scan interval of artist %v at retailer %v", i.ArtistID, i.RetailerID)
+		}
+	}
+	return nil
+}
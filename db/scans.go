@@ -0,0 +1,197 @@
+package db
+
+import (
+	"fmt"
+	"github.com/gavinturner/vinylretailers/util/postgres"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+	"time"
+)
+
+// ScanStatus is how a scan of an artist at a retailer ended.
+type ScanStatus string
+
+const (
+	ScanStatus_Succeeded  ScanStatus = "succeeded"
+	ScanStatus_Failed     ScanStatus = "failed"
+	ScanStatus_TimedOut   ScanStatus = "timed_out"   // retried until the request runs out of attempts
+	ScanStatus_ParseError ScanStatus = "parse_error" // the retailer's markup has probably changed
+	ScanStatus_Disallowed ScanStatus = "disallowed"  // the retailer's robots.txt refused the scraper, so it's not the retailer failing
+)
+
+// ScanOutcome is what became of a scan request after an attempt at it.
+type ScanOutcome string
+
+const (
+	ScanOutcome_Succeeded ScanOutcome = "succeeded"
+	ScanOutcome_Retrying  ScanOutcome = "retrying" // requeued to be tried again after a backoff
+	ScanOutcome_Failed    ScanOutcome = "failed"   // given up on, as retrying won't help or it has run out of attempts
+)
+
+// Scan is one attempt at a scan request.
+type Scan struct {
+	ID           int64       `db:"id" json:"id"`
+	BatchID      int64       `db:"batch_id" json:"batchId"`
+	RetailerID   int64       `db:"retailer_id" json:"retailerId"`
+	ArtistID     int64       `db:"artist_id" json:"artistId"`
+	Attempt      int         `db:"attempt" json:"attempt"`
+	Status       ScanStatus  `db:"status" json:"status"`
+	Outcome      ScanOutcome `db:"outcome" json:"outcome"`
+	StartedAt    time.Time   `db:"started_at" json:"startedAt"`
+	DurationMS   int64       `db:"duration_ms" json:"durationMs"`
+	ResultCount  int         `db:"result_count" json:"resultCount"`   // listings found, across the artist's variants
+	PagesFetched int         `db:"pages_fetched" json:"pagesFetched"` // pages of search results read
+	Error        null.String `db:"error" json:"error"`
+	CreatedAt    time.Time   `db:"created_at" json:"createdAt"`
+}
+
+func (v *VinylDB) AddScan(tx *postgres.Tx, scan *Scan) error {
+	if scan == nil {
+		return errors.New("nil scan")
+	}
+	querier := v.Q(tx)
+	err := querier.Get(&scan.ID, querier.Rebind(`
+		INSERT INTO scans (batch_id, retailer_id, artist_id, attempt, status, outcome, started_at, duration_ms, result_count, pages_fetched, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`), scan.BatchID, scan.RetailerID, scan.ArtistID, scan.Attempt, string(scan.Status), string(scan.Outcome), scan.StartedAt,
+		scan.DurationMS, scan.ResultCount, scan.PagesFetched, scan.Error)
+	if err != nil {
+		return errors.Wrapf(err, "failed to add scan of artist %v at retailer %v", scan.ArtistID, scan.RetailerID)
+	}
+	return nil
+}
+
+func (v *VinylDB) GetScansForBatch(tx *postgres.Tx, batchID int64) ([]Scan, error) {
+	querier := v.Q(tx)
+	scans := []Scan{}
+	err := querier.Select(&scans, querier.Rebind(`
+		SELECT id, batch_id, retailer_id, artist_id, attempt, status, outcome, started_at, duration_ms, result_count,
+			pages_fetched, error, created_at
+		FROM scans
+		WHERE batch_id = ?
+		ORDER BY id
+	`), batchID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve scans for batch %v", batchID)
+	}
+	return scans, nil
+}
+
+// RetailerHealth compares how a retailer's scans have gone recently (over the health window) with how they went
+// over the baseline window before it. Listings found are compared artist by artist, as a window that happened to scan
+// only artists with few listings shouldn't look like the retailer has broken: Results and BaselineResults sum the
+// average listings found for each artist scanned successfully in both windows.
+type RetailerHealth struct {
+	RetailerID        int64     `db:"retailer_id" json:"retailerId"`
+	RetailerName      string    `db:"retailer_name" json:"retailerName"`
+	LastScanAt        time.Time `db:"last_scan_at" json:"lastScanAt"`
+	Scans             int       `db:"scans" json:"scans"`
+	Succeeded         int       `db:"succeeded" json:"succeeded"`
+	BaselineScans     int       `db:"baseline_scans" json:"baselineScans"`
+	BaselineSucceeded int       `db:"baseline_succeeded" json:"baselineSucceeded"`
+	ComparedArtists   int       `db:"compared_artists" json:"comparedArtists"`
+	Results           float64   `db:"results" json:"results"`
+	BaselineResults   float64   `db:"baseline_results" json:"baselineResults"`
+}
+
+// GetRetailerHealth returns the health of each retailer (or just the one, if given) scanned since the baseline
+// window began, over the window up to now against the baseline window before it. Scans the retailer's robots.txt
+// refused aren't the retailer failing, so they aren't counted.
+func (v *VinylDB) GetRetailerHealth(tx *postgres.Tx, retailerID *int64, window time.Duration, baseline time.Duration, now time.Time) ([]RetailerHealth, error) {
+	querier := v.Q(tx)
+	health := []RetailerHealth{}
+	windowStart := now.Add(-window)
+	args := []interface{}{windowStart, windowStart.Add(-baseline)}
+	where := ""
+	if retailerID != nil {
+		where = "AND s.retailer_id = ?"
+		args = append(args, *retailerID)
+	}
+	err := querier.Select(&health, querier.Rebind(`
+		WITH windowed AS (
+			SELECT s.retailer_id, s.artist_id, s.status, s.result_count, s.started_at, s.started_at >= ? AS recent
+			FROM scans s
+			WHERE s.started_at >= ? AND s.status <> 'disallowed' `+where+`
+		), artists AS (
+			SELECT retailer_id, artist_id,
+				AVG(result_count) FILTER (WHERE recent AND status = 'succeeded') AS results,
+				AVG(result_count) FILTER (WHERE NOT recent AND status = 'succeeded') AS baseline_results
+			FROM windowed
+			GROUP BY retailer_id, artist_id
+		), compared AS (
+			SELECT retailer_id, COUNT(*) AS compared_artists, SUM(results) AS results, SUM(baseline_results) AS baseline_results
+			FROM artists
+			WHERE results IS NOT NULL AND baseline_results IS NOT NULL
+			GROUP BY retailer_id
+		)
+		SELECT w.retailer_id, r.name AS retailer_name, MAX(w.started_at) AS last_scan_at,
+			COUNT(*) FILTER (WHERE w.recent) AS scans,
+			COUNT(*) FILTER (WHERE w.recent AND w.status = 'succeeded') AS succeeded,
+			COUNT(*) FILTER (WHERE NOT w.recent) AS baseline_scans,
+			COUNT(*) FILTER (WHERE NOT w.recent AND w.status = 'succeeded') AS baseline_succeeded,
+			COALESCE(c.compared_artists, 0) AS compared_artists, COALESCE(c.results, 0) AS results,
+			COALESCE(c.baseline_results, 0) AS baseline_results
+		FROM windowed w
+		JOIN retailers r ON w.retailer_id = r.id
+		LEFT JOIN compared c ON c.retailer_id = w.retailer_id
+		GROUP BY w.retailer_id, r.name, c.compared_artists, c.results, c.baseline_results
+		ORDER BY r.name
+	`), args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve retailer health")
+	}
+	return health, nil
+}
+
+// SuccessRate is the fraction of the window's scans that succeeded.
+func (h RetailerHealth) SuccessRate() float64 {
+	return ratio(h.Succeeded, h.Scans)
+}
+
+func (h RetailerHealth) BaselineSuccessRate() float64 {
+	return ratio(h.BaselineSucceeded, h.BaselineScans)
+}
+
+// ResultsPerArtist is the average number of listings the window's successful scans found for an artist, over the
+// artists that can be compared with the baseline.
+func (h RetailerHealth) ResultsPerArtist() float64 {
+	if h.ComparedArtists == 0 {
+		return 0
+	}
+	return h.Results / float64(h.ComparedArtists)
+}
+
+func (h RetailerHealth) BaselineResultsPerArtist() float64 {
+	if h.ComparedArtists == 0 {
+		return 0
+	}
+	return h.BaselineResults / float64(h.ComparedArtists)
+}
+
+// Problem describes why the retailer looks broken, or is "" if it looks healthy. It looks broken once the window
+// has had at least minScans scans and either its success rate, or the listings its scans find for the same artists,
+// have dropped by dropPercent or more from the baseline. A retailer that stops finding anything has usually changed
+// its markup in a way that doesn't fail parsing. Without a baseline there is nothing to compare with, so it looks
+// healthy.
+func (h RetailerHealth) Problem(minScans int, dropPercent int) string {
+	if h.Scans < minScans || h.BaselineScans == 0 {
+		return ""
+	}
+	keep := float64(100-dropPercent) / 100
+	if h.BaselineSuccessRate() > 0 && h.SuccessRate() <= h.BaselineSuccessRate()*keep {
+		return fmt.Sprintf("%.0f%% of scans succeeded, down from %.0f%%", h.SuccessRate()*100, h.BaselineSuccessRate()*100)
+	}
+	if h.BaselineResults > 0 && h.Results <= h.BaselineResults*keep {
+		return fmt.Sprintf("scans found %.1f listings an artist, down from %.1f for the same %v artists", h.ResultsPerArtist(),
+			h.BaselineResultsPerArtist(), h.ComparedArtists)
+	}
+	return ""
+}
+
+func ratio(n int, of int) float64 {
+	if of == 0 {
+		return 0
+	}
+	return float64(n) / float64(of)
+}
@@ -0,0 +1,28 @@
+//go:build unit_test
+// +build unit_test
+
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScans_RetailerHealthProblem(t *testing.T) {
+	t.Parallel()
+
+	// the window scanned two artists with few listings, which found what they have always found
+	health := RetailerHealth{Scans: 10, Succeeded: 10, BaselineScans: 100, BaselineSucceeded: 100, ComparedArtists: 2, Results: 2, BaselineResults: 2}
+	assert.Equal(t, "", health.Problem(5, 50), "Expected artists finding what they always have to look healthy")
+
+	health.Results = 0.5
+	assert.Equal(t, "scans found 0.2 listings an artist, down from 1.0 for the same 2 artists", health.Problem(5, 50))
+
+	health = RetailerHealth{Scans: 10, Succeeded: 4, BaselineScans: 100, BaselineSucceeded: 90}
+	assert.Equal(t, "40% of scans succeeded, down from 90%", health.Problem(5, 50))
+	assert.Equal(t, "", health.Problem(20, 50), "Expected too few scans in the window not to be judged")
+
+	health.BaselineScans, health.BaselineSucceeded = 0, 0
+	assert.Equal(t, "", health.Problem(5, 50), "Expected a retailer without a baseline to look healthy")
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"github.com/gavinturner/vinylretailers/util/postgres"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+	"time"
+)
+
+// Schedule is when the scheduler starts batches for a retailer, or for the retailers without a schedule of their own.
+type Schedule struct {
+	ID          int64       `db:"id" json:"id"`
+	Name        string      `db:"name" json:"name"`
+	RetailerID  null.Int    `db:"retailer_id" json:"retailerId"` // null for a schedule of the retailers without their own
+	Cron        string      `db:"cron" json:"cron"`
+	Timezone    string      `db:"timezone" json:"timezone"`
+	QuietStart  null.String `db:"quiet_start" json:"quietStart"` // HH:MM
+	QuietEnd    null.String `db:"quiet_end" json:"quietEnd"`
+	JitterSecs  int         `db:"jitter_secs" json:"jitterSecs"`
+	Enabled     bool        `db:"enabled" json:"enabled"`
+	LastRunAt   null.Time   `db:"last_run_at" json:"lastRunAt"`
+	LastBatchID null.Int    `db:"last_batch_id" json:"lastBatchId"`
+	NextRunAt   null.Time   `db:"next_run_at" json:"nextRunAt"` // null until the scheduler first sees the schedule
+	CreatedAt   time.Time   `db:"created_at"`
+	UpdatedAt   time.Time   `db:"updated_at"`
+}
+
+func (v *VinylDB) GetEnabledSchedules(tx *postgres.Tx) ([]Schedule, error) {
+	querier := v.Q(tx)
+	schedules := []Schedule{}
+	err := querier.Select(&schedules, `
+		SELECT id, name, retailer_id, cron, timezone, quiet_start, quiet_end, jitter_secs, enabled, last_run_at,
+			last_batch_id, next_run_at, created_at, updated_at
+		FROM schedules
+		WHERE enabled
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve schedules")
+	}
+	return schedules, nil
+}
+
+// MoveScheduleNextRun sets when the schedule next runs, as long as it is still due to run when it was (so only one
+// scheduler claims a run). Returns false if it wasn't.
+func (v *VinylDB) MoveScheduleNextRun(tx *postgres.Tx, scheduleID int64, from null.Time, to time.Time) (bool, error) {
+	querier := v.Q(tx)
+	result, err := querier.Exec(querier.Rebind(`
+		UPDATE schedules SET next_run_at = ?, updated_at = NOW()
+		WHERE id = ? AND next_run_at IS NOT DISTINCT FROM ?
+	`), to, scheduleID, from)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to move next run of schedule %v", scheduleID)
+	}
+	moved, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get rows affected")
+	}
+	return moved == 1, nil
+}
+
+func (v *VinylDB) SetScheduleLastRun(tx *postgres.Tx, scheduleID int64, ranAt time.Time, batchID int64) error {
+	querier := v.Q(tx)
+	_, err := querier.Exec(querier.Rebind(`
+		UPDATE schedules SET last_run_at = ?, last_batch_id = ?, updated_at = NOW() WHERE id = ?
+	`), ranAt, null.NewInt(batchID, batchID != 0), scheduleID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set last run of schedule %v", scheduleID)
+	}
+	return nil
+}
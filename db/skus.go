@@ -2,32 +2,61 @@ package db
 
 import (
 	"fmt"
+	"github.com/gavinturner/vinylretailers/retailers"
 	"github.com/gavinturner/vinylretailers/util/postgres"
 	"github.com/pkg/errors"
-	"strings"
+	"gopkg.in/guregu/null.v3"
 	"time"
 )
 
+// SKU_COLUMNS selects a sku row, mapping the price columns onto the nested price struct
+const SKU_COLUMNS = `id, retailer_id,  release_id, artist_id, item_url, image_url,
+		price_amount AS "price.amount", price_currency AS "price.currency", availability AS "price.availability",
+		stock_quantity AS "price.stock", COALESCE(search_term, '') AS search_term, missed_scans,
+		COALESCE(format, '') AS format, COALESCE(edition, '') AS edition, variants,
+		COALESCE(barcode, '') AS barcode, COALESCE(catalogue_number, '') AS catalogue_number, created_at`
+
+// SKUChange describes how a sku differs from the previous state of the release at the retailer
+type SKUChange string
+
+const (
+	SKUChange_None         SKUChange = ""
+	SKUChange_New          SKUChange = "new"          // first time the release has been seen at the retailer
+	SKUChange_Restock      SKUChange = "restock"      // was sold out, now available again
+	SKUChange_SoldOut      SKUChange = "sold_out"     // was available, now sold out
+	SKUChange_Delisted     SKUChange = "delisted"     // the retailer no longer lists it
+	SKUChange_Price        SKUChange = "price"        // price has changed
+	SKUChange_Availability SKUChange = "availability" // same price, but now low stock, pre-order etc
+	SKUChange_Variants     SKUChange = "variants"     // same price, but the price or availability of a variant has changed
+)
+
 type SKU struct {
-	ID         int64     `db:"id" json:"id"`
-	Name       string    `json:"name"`
-	ReleaseID  int64     `db:"release_id" json:"releaseId"`
-	RetailerID int64     `db:"retailer_id" json:"retailerId"`
-	ArtistID   int64     `db:"artist_id" json:"artistId"`
-	ItemUrl    string    `db:"item_url" json:"itemUrl"`
-	ImageUrl   string    `db:"image_url" json:"imageUrl"`
-	Price      string    `db:"price" json:"price"`
-	CreatedAt  time.Time `db:"created_at" json:"createdAt"`
+	ID          int64              `db:"id" json:"id"`
+	Name        string             `json:"name"`
+	ReleaseID   int64              `db:"release_id" json:"releaseId"`
+	RetailerID  int64              `db:"retailer_id" json:"retailerId"`
+	ArtistID    int64              `db:"artist_id" json:"artistId"`
+	ItemUrl     string             `db:"item_url" json:"itemUrl"`
+	ImageUrl    string             `db:"image_url" json:"imageUrl"`
+	Price       retailers.Price    `db:"price" json:"price"`
+	SearchTerm  string             `db:"search_term" json:"searchTerm"`   // the artist name (or variant) the listing was found with
+	MissedScans int                `db:"missed_scans" json:"missedScans"` // successful scans in a row that haven't found the listing
+	Format      retailers.Format   `db:"format" json:"format"`
+	Edition     string             `db:"edition" json:"edition"`
+	Variants    retailers.Variants `db:"variants" json:"variants"`
+	Barcode     string             `db:"barcode" json:"barcode"`
+	CatalogueNo string             `db:"catalogue_number" json:"catalogueNumber"`
+	CreatedAt   time.Time          `db:"created_at" json:"createdAt"`
 }
 
 func (v *VinylDB) GetCurrentSKUForRelease(tx *postgres.Tx, releaseID int64, retailerID int64) (*SKU, error) {
 	querier := v.Q(tx)
 	skus := []SKU{}
 	err := querier.Select(&skus, querier.Rebind(`
-		SELECT id, retailer_id,  release_id, artist_id, item_url, image_url, price, created_at
+		SELECT `+SKU_COLUMNS+`
 		FROM skus
 		WHERE retailer_id = ? AND release_id = ?
-		ORDER BY created_at DESC
+		ORDER BY created_at DESC, id DESC
 		LIMIT 1
 	`), retailerID, releaseID)
 	if err != nil {
@@ -39,12 +68,28 @@ func (v *VinylDB) GetCurrentSKUForRelease(tx *postgres.Tx, releaseID int64, reta
 	return &skus[0], nil
 }
 
+// GetCurrentSKUs returns the most recent sku for each of the artist's releases at the retailer.
+func (v *VinylDB) GetCurrentSKUs(tx *postgres.Tx, artistID int64, retailerID int64) ([]SKU, error) {
+	querier := v.Q(tx)
+	skus := []SKU{}
+	err := querier.Select(&skus, querier.Rebind(`
+		SELECT DISTINCT ON (release_id) `+SKU_COLUMNS+`
+		FROM skus
+		WHERE artist_id = ? AND retailer_id = ?
+		ORDER BY release_id, created_at DESC, id DESC
+	`), artistID, retailerID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve current skus for artist %v at retailer %v", artistID, retailerID)
+	}
+	return skus, nil
+}
+
 func (v *VinylDB) GetAllSKUs(tx *postgres.Tx, artistId *int64, retailerId *int64) ([]SKU, error) {
 	querier := v.Q(tx)
 	skus := []SKU{}
 	args := []interface{}{}
 	query := `
-		SELECT id, retailer_id,  release_id, artist_id, item_url, image_url, price, created_at
+		SELECT ` + SKU_COLUMNS + `
 		FROM skus
 	`
 	if artistId != nil || retailerId != nil {
@@ -77,40 +122,87 @@ func (v *VinylDB) UpdateSKU(tx *postgres.Tx, sku *SKU) error {
 		return errors.New("nil sku")
 	}
 	_, err := querier.Exec(querier.Rebind(`
-		UPDATE skus SET item_url=?, image_url=?, price=?
+		UPDATE skus SET item_url=?, image_url=?, price_amount=?, price_currency=?, availability=?, stock_quantity=?
 		WHERE id=?
-	`), sku.ItemUrl, sku.ImageUrl, sku.Price, sku.ID)
+	`), sku.ItemUrl, sku.ImageUrl, sku.Price.Amount, sku.Price.Currency, string(sku.Price.Availability), sku.Price.Stock, sku.ID)
 	if err != nil {
-		return errors.Wrapf(err, "failed to retrieve all skus")
+		return errors.Wrapf(err, "failed to update sku %v", sku.ID)
 	}
 	return nil
 }
 
-func (v *VinylDB) UpsertSKU(tx *postgres.Tx, sku *SKU) (same bool, err error) {
+// SetSKUMissedScans records how many successful scans in a row the sku's listing has been missing from.
+func (v *VinylDB) SetSKUMissedScans(tx *postgres.Tx, skuID int64, missedScans int) error {
+	querier := v.Q(tx)
+	_, err := querier.Exec(querier.Rebind(`
+		UPDATE skus SET missed_scans = ? WHERE id = ?
+	`), missedScans, skuID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set missed scans for sku %v", skuID)
+	}
+	return nil
+}
+
+// UpsertSKU inserts a new sku record for the release if its price or availability (or that of any of its variants)
+// differs from the most recent one, returning how it changed. If nothing has changed the sku is set to the existing
+// record and SKUChange_None returned. Either way the listing has been found, so it is no longer counted as missing.
+func (v *VinylDB) UpsertSKU(tx *postgres.Tx, sku *SKU) (change SKUChange, err error) {
 	if sku == nil {
-		return false, fmt.Errorf("supplied sku is nil")
+		return SKUChange_None, fmt.Errorf("supplied sku is nil")
 	}
 	querier := v.Q(tx)
 
 	// first get the current price to see if we should insert a new record
-	sku.Price = strings.ToLower(strings.TrimSpace(sku.Price))
 	existingSku, err := v.GetCurrentSKUForRelease(tx, sku.ReleaseID, sku.RetailerID)
 	if err != nil {
-		return false, errors.Wrapf(err, "failed to retrieve existing sku")
+		return SKUChange_None, errors.Wrapf(err, "failed to retrieve existing sku")
 	}
-	if existingSku != nil && existingSku.Price == sku.Price {
+	if existingSku != nil && existingSku.Price.Equal(sku.Price) && existingSku.Variants.Equal(sku.Variants) {
+		if existingSku.MissedScans > 0 {
+			err = v.SetSKUMissedScans(tx, existingSku.ID, 0)
+			if err != nil {
+				return SKUChange_None, err
+			}
+			existingSku.MissedScans = 0
+		}
 		*sku = *existingSku
-		return true, nil
+		return SKUChange_None, nil
+	}
+	change = CompareSKUPrices(existingSku, sku.Price)
+	if change == SKUChange_None {
+		change = SKUChange_Variants
 	}
 	var id int64
 	err = querier.Get(&id, querier.Rebind(`
-		INSERT INTO skus (retailer_id, release_id, artist_id, item_url, image_url, price) 
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO skus (retailer_id, release_id, artist_id, item_url, image_url, price_amount, price_currency, availability, stock_quantity, search_term,
+			format, edition, variants, barcode, catalogue_number) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		RETURNING id
-	`), sku.RetailerID, sku.ReleaseID, sku.ArtistID, sku.ItemUrl, sku.ImageUrl, sku.Price)
+	`), sku.RetailerID, sku.ReleaseID, sku.ArtistID, sku.ItemUrl, sku.ImageUrl, sku.Price.Amount, sku.Price.Currency, string(sku.Price.Availability), sku.Price.Stock, null.NewString(sku.SearchTerm, sku.SearchTerm != ""),
+		null.NewString(string(sku.Format), sku.Format != retailers.Format_Unknown), null.NewString(sku.Edition, sku.Edition != ""), sku.Variants,
+		null.NewString(sku.Barcode, sku.Barcode != ""), null.NewString(sku.CatalogueNo, sku.CatalogueNo != ""))
 	if err != nil {
-		return false, errors.Wrapf(err, "failed to upsert release")
+		return SKUChange_None, errors.Wrapf(err, "failed to upsert release")
 	}
 	sku.ID = id
-	return false, nil
+	return change, nil
+}
+
+// CompareSKUPrices classifies the change from the existing sku (nil if there is none) to the new price.
+func CompareSKUPrices(existing *SKU, price retailers.Price) SKUChange {
+	switch {
+	case existing == nil:
+		return SKUChange_New
+	case existing.Price.Equal(price):
+		return SKUChange_None
+	case !existing.Price.IsAvailable() && price.IsAvailable():
+		return SKUChange_Restock
+	case price.Availability == retailers.Availability_Delisted:
+		return SKUChange_Delisted
+	case !price.IsAvailable():
+		return SKUChange_SoldOut
+	case existing.Price.Amount != price.Amount || existing.Price.Currency != price.Currency:
+		return SKUChange_Price
+	}
+	return SKUChange_Availability
 }
@@ -3,54 +3,126 @@
 package db
 
 import (
-	"github.com/gavinturner/vinylretailers/retailers"
+	"time"
+
 	"github.com/gavinturner/vinylretailers/util/postgres"
+	"gopkg.in/guregu/null.v3"
 )
 
 // VinylDS ...
 type VinylDS interface {
-	//
 	// AddNewBatch
 	// Create a new batch instance, representing one full set of scans to be completed over the set of users
 	// currently watching a set of artists. This batch instance will have a set of empty reports connected to
 	// it, one per watching user, and each such report will cover the set of artists currently being watched
 	// by the user for whom the report pertains. The batch also stores the required number of searches to be
 	// performed (artist x retailer) so that we can keep track of whether a batch has been completed or not.
-	//
 	AddNewBatch(tx *postgres.Tx, numRequiredSearches int, userArtists map[int64][]WatchedArtist) (batchId int64, err error)
-	//
+	// AddOutstandingScans records the scans as queued for the batch, taking over any that were already outstanding for
+	// an earlier batch. A scan that is taken over is cleared for the earlier batch and counted as a failed search of it,
+	// as its request won't count towards that batch when it's done, and without it that batch would never finish.
+	AddOutstandingScans(tx *postgres.Tx, batchID int64, scans []OutstandingScan) error
 	// AddSKUToReportsForBatch
 	// Given a particular SKU sound for an artist + retailer, this method looks at all the reports attached to the
 	// current batch, determines if the SKU artist is covered by the report, and if so attaches the SKU to the final
 	// report. We assume that it has already been determined whether the SKU represents a valid result for the report
 	// (for example the price has changed).
-	//
-	AddSKUToReportsForBatch(tx *postgres.Tx, batchId int64, sku *SKU) error
+	AddSKUToReportsForBatch(tx *postgres.Tx, batchId int64, sku *SKU, change SKUChange) error
+	AddScan(tx *postgres.Tx, scan *Scan) error
+	// ClearOutstandingScan records the batch's scan of the artist at the retailer as done with (whether it succeeded or
+	// was given up on), returning false if it already had been. A scan since queued by another batch is left
+	// outstanding.
+	ClearOutstandingScan(tx *postgres.Tx, batchID int64, artistID int64, retailerID int64) (bool, error)
 	CloseTransaction(tx *postgres.Tx, err error) error
 	DeleteBatch(tx *postgres.Tx, batchId int64) error
 	DeleteReport(tx *postgres.Tx, reportId int64) error
 	DeleteReportsForBatch(tx *postgres.Tx, batchId int64) error
 	GetAllArtists(tx *postgres.Tx) ([]Artist, error)
+	// GetAllCompletedUnsentReports returns the unsent reports of batches whose searches are all done. Scan requests are
+	// delivered at least once, so a search can be counted twice (the scanner died between committing and acking it).
 	GetAllCompletedUnsentReports(tx *postgres.Tx) ([]BatchedReport, error)
 	GetAllRetailers(tx *postgres.Tx) ([]Retailer, error)
 	GetAllSKUs(tx *postgres.Tx, artistId *int64, retailerId *int64) ([]SKU, error)
 	GetCurrentSKUForRelease(tx *postgres.Tx, releaseID int64, retailerID int64) (*SKU, error)
-	GetSkusForReport(tx *postgres.Tx, reportId int64) ([]retailers.SKU, error)
+	// GetCurrentSKUs returns the most recent sku for each of the artist's releases at the retailer.
+	GetCurrentSKUs(tx *postgres.Tx, artistID int64, retailerID int64) ([]SKU, error)
+	GetEnabledSchedules(tx *postgres.Tx) ([]Schedule, error)
+	// GetListingChanges returns how much each artist's listings at each retailer have changed since changesSince, and how
+	// many have been newly listed or restocked since arrivalsSince. Artists that have never been listed at a retailer
+	// aren't included.
+	GetListingChanges(tx *postgres.Tx, changesSince time.Time, arrivalsSince time.Time) ([]ListingChanges, error)
+	// GetOutstandingScans returns the scans queued since the given time that haven't been done with. Older ones are
+	// taken to have been lost from the queue.
+	GetOutstandingScans(tx *postgres.Tx, queuedSince time.Time) ([]OutstandingScan, error)
+	GetPendingReleaseReviews(tx *postgres.Tx) ([]ReleaseReview, error)
+	// GetReleasePrices returns the current sku for the release at each retailer that lists it.
+	GetReleasePrices(tx *postgres.Tx, releaseID int64) ([]SKU, error)
+	// GetReleasesByBarcode returns the releases listed with the barcode (EAN-13 or UPC-A), one for each artist on it
+	// (so more than one for a split release), or none if no retailer has listed it.
+	GetReleasesByBarcode(tx *postgres.Tx, barcode string) ([]Release, error)
+	GetReleasesForArtist(tx *postgres.Tx, artistID int64) ([]Release, error)
+	GetRetailer(tx *postgres.Tx, retailerId int64) (*Retailer, error)
+	// GetRetailerHealth returns the health of each retailer (or just the one, if given) scanned since the baseline
+	// window began, over the window up to now against the baseline window before it. Scans the retailer's robots.txt
+	// refused aren't the retailer failing, so they aren't counted.
+	GetRetailerHealth(tx *postgres.Tx, retailerID *int64, window time.Duration, baseline time.Duration, now time.Time) ([]RetailerHealth, error)
+	// GetScanIntervals returns the scan interval of each artist at each retailer (or just the one, if given).
+	GetScanIntervals(tx *postgres.Tx, retailerID *int64) ([]ScanInterval, error)
+	GetScansForBatch(tx *postgres.Tx, batchID int64) ([]Scan, error)
+	GetSkusForReport(tx *postgres.Tx, reportId int64) ([]ReportSKU, error)
 	GetWatchedArtists(tx *postgres.Tx) (map[int64][]WatchedArtist, error)
-	//
 	// IncrementBatchSearchCompletedCount
 	// When scanning is complete for a specific artist + retailer, this method allows the scanner to increment
 	// the number of scans completed that is stored against the batch. We use UPDATE here as an atomic operation
-	// on the batches table and as such this method will be thread-safe in terms of getting all increments.
-	//
-	IncrementBatchSearchCompletedCount(tx *postgres.Tx, batchId int64) error
+	// on the batches table and as such this method will be thread-safe in terms of getting all increments. Returns
+	// whether this search completed the batch, which is only true for one of them.
+	IncrementBatchSearchCompletedCount(tx *postgres.Tx, batchId int64) (batchCompleted bool, err error)
+	// IncrementBatchSearchFailedCount
+	// When scanning an artist + retailer has failed for good (retrying won't help, or it has been retried as many
+	// times as it can be), this method counts the search as completed, so the batch can still finish, and as failed.
+	// Returns whether this search completed the batch.
+	IncrementBatchSearchFailedCount(tx *postgres.Tx, batchId int64) (batchCompleted bool, err error)
+	// IncrementBatchSearchRetriedCount counts a scan that failed and was requeued to be retried against the batch.
+	IncrementBatchSearchRetriedCount(tx *postgres.Tx, batchId int64) error
 	MarkBatchReported(tx *postgres.Tx, batchId int64) error
 	MarkReportSent(tx *postgres.Tx, reportId int64) error
+	// MatchRelease finds the canonical release for a retailer's listing of one of the artist's releases, so that
+	// listings of the same release from every retailer share it however each titles it. A listing the retailer has
+	// linked to before is the release it was matched to then. Otherwise a barcode seen before on a listing of a release
+	// is the strongest signal and matches whatever the title, followed by a catalogue number as long as the titles are
+	// at least similar. Failing that titles are compared once normalised: an exact match (or a title matched before) is
+	// the release, as is a title similar enough to match automatically. Otherwise the listing gets a release of its own,
+	// and if it is close to an existing release the pair are queued for review. The listing's codes are recorded
+	// against the release it is matched to.
+	//
+	// The sku history of a release is kept per retailer, so a release the retailer lists under a different link (another
+	// pressing or edition titled alike, or a second copy) is never matched: the listing gets a release of its own.
+	MatchRelease(tx *postgres.Tx, artistID int64, retailerID int64, title string, itemURL string, barcodes []string, catalogueNos []string) (match ReleaseMatch, err error)
+	// MoveScheduleNextRun sets when the schedule next runs, as long as it is still due to run when it was (so only one
+	// scheduler claims a run). Returns false if it wasn't.
+	MoveScheduleNextRun(tx *postgres.Tx, scheduleID int64, from null.Time, to time.Time) (bool, error)
 	Q(tx *postgres.Tx) postgres.Querier
+	// ResolveReleaseReview accepts or rejects a queued match. Accepting merges the listing's release into the candidate:
+	// its skus, aliases and codes move to the candidate (so it is matched automatically from now on) and it is deleted, along
+	// with any other pending reviews of it. The review is kept as a record of the decision. A review of two releases that
+	// are both listed at the same retailer is refused with a ReleaseMergeConflict, and should be rejected instead.
+	ResolveReleaseReview(tx *postgres.Tx, reviewID int64, accept bool) error
+	// SaveScanIntervals adds or updates the scan intervals.
+	SaveScanIntervals(tx *postgres.Tx, intervals []ScanInterval) error
+	// SetFollowFilter limits what is reported to the user for an artist they follow: vinyl only (listings of an unknown
+	// format are kept, as they might be), and/or only listings whose edition contains the filter e.g. "test pressing".
+	// An empty filter reports every edition.
+	SetFollowFilter(tx *postgres.Tx, userID int64, artistID int64, vinylOnly bool, editionFilter string) error
+	// SetSKUMissedScans records how many successful scans in a row the sku's listing has been missing from.
+	SetSKUMissedScans(tx *postgres.Tx, skuID int64, missedScans int) error
+	SetScheduleLastRun(tx *postgres.Tx, scheduleID int64, ranAt time.Time, batchID int64) error
 	StartTransaction() (*postgres.Tx, error)
 	UpdateSKU(tx *postgres.Tx, sku *SKU) error
 	UpsertRelease(tx *postgres.Tx, artistId int64, title string) (id int64, err error)
-	UpsertSKU(tx *postgres.Tx, sku *SKU) (same bool, err error)
+	// UpsertSKU inserts a new sku record for the release if its price or availability (or that of any of its variants)
+	// differs from the most recent one, returning how it changed. If nothing has changed the sku is set to the existing
+	// record and SKUChange_None returned. Either way the listing has been found, so it is no longer counted as missing.
+	UpsertSKU(tx *postgres.Tx, sku *SKU) (change SKUChange, err error)
 	VerifySchema() error
 	WaitForDbUp(timeoutSecs int64) error
 }
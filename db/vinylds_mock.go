@@ -4,9 +4,10 @@
 package db
 
 import (
-	"github.com/gavinturner/vinylretailers/retailers"
 	"github.com/gavinturner/vinylretailers/util/postgres"
+	"gopkg.in/guregu/null.v3"
 	"sync"
+	"time"
 )
 
 // Ensure, that VinylDSMock does implement VinylDS.
@@ -22,9 +23,18 @@ var _ VinylDS = &VinylDSMock{}
 // 			AddNewBatchFunc: func(tx *postgres.Tx, numRequiredSearches int, userArtists map[int64][]WatchedArtist) (int64, error) {
 // 				panic("mock out the AddNewBatch method")
 // 			},
-// 			AddSKUToReportsForBatchFunc: func(tx *postgres.Tx, batchId int64, sku *SKU) error {
+// 			AddOutstandingScansFunc: func(tx *postgres.Tx, batchID int64, scans []OutstandingScan) error {
+// 				panic("mock out the AddOutstandingScans method")
+// 			},
+// 			AddSKUToReportsForBatchFunc: func(tx *postgres.Tx, batchId int64, sku *SKU, change SKUChange) error {
 // 				panic("mock out the AddSKUToReportsForBatch method")
 // 			},
+// 			AddScanFunc: func(tx *postgres.Tx, scan *Scan) error {
+// 				panic("mock out the AddScan method")
+// 			},
+// 			ClearOutstandingScanFunc: func(tx *postgres.Tx, batchID int64, artistID int64, retailerID int64) (bool, error) {
+// 				panic("mock out the ClearOutstandingScan method")
+// 			},
 // 			CloseTransactionFunc: func(tx *postgres.Tx, err error) error {
 // 				panic("mock out the CloseTransaction method")
 // 			},
@@ -52,24 +62,87 @@ var _ VinylDS = &VinylDSMock{}
 // 			GetCurrentSKUForReleaseFunc: func(tx *postgres.Tx, releaseID int64, retailerID int64) (*SKU, error) {
 // 				panic("mock out the GetCurrentSKUForRelease method")
 // 			},
-// 			GetSkusForReportFunc: func(tx *postgres.Tx, reportId int64) ([]retailers.SKU, error) {
+// 			GetCurrentSKUsFunc: func(tx *postgres.Tx, artistID int64, retailerID int64) ([]SKU, error) {
+// 				panic("mock out the GetCurrentSKUs method")
+// 			},
+// 			GetEnabledSchedulesFunc: func(tx *postgres.Tx) ([]Schedule, error) {
+// 				panic("mock out the GetEnabledSchedules method")
+// 			},
+// 			GetListingChangesFunc: func(tx *postgres.Tx, changesSince time.Time, arrivalsSince time.Time) ([]ListingChanges, error) {
+// 				panic("mock out the GetListingChanges method")
+// 			},
+// 			GetOutstandingScansFunc: func(tx *postgres.Tx, queuedSince time.Time) ([]OutstandingScan, error) {
+// 				panic("mock out the GetOutstandingScans method")
+// 			},
+// 			GetPendingReleaseReviewsFunc: func(tx *postgres.Tx) ([]ReleaseReview, error) {
+// 				panic("mock out the GetPendingReleaseReviews method")
+// 			},
+// 			GetReleasePricesFunc: func(tx *postgres.Tx, releaseID int64) ([]SKU, error) {
+// 				panic("mock out the GetReleasePrices method")
+// 			},
+// 			GetReleasesByBarcodeFunc: func(tx *postgres.Tx, barcode string) ([]Release, error) {
+// 				panic("mock out the GetReleasesByBarcode method")
+// 			},
+// 			GetReleasesForArtistFunc: func(tx *postgres.Tx, artistID int64) ([]Release, error) {
+// 				panic("mock out the GetReleasesForArtist method")
+// 			},
+// 			GetRetailerFunc: func(tx *postgres.Tx, retailerId int64) (*Retailer, error) {
+// 				panic("mock out the GetRetailer method")
+// 			},
+// 			GetRetailerHealthFunc: func(tx *postgres.Tx, retailerID *int64, window time.Duration, baseline time.Duration, now time.Time) ([]RetailerHealth, error) {
+// 				panic("mock out the GetRetailerHealth method")
+// 			},
+// 			GetScanIntervalsFunc: func(tx *postgres.Tx, retailerID *int64) ([]ScanInterval, error) {
+// 				panic("mock out the GetScanIntervals method")
+// 			},
+// 			GetScansForBatchFunc: func(tx *postgres.Tx, batchID int64) ([]Scan, error) {
+// 				panic("mock out the GetScansForBatch method")
+// 			},
+// 			GetSkusForReportFunc: func(tx *postgres.Tx, reportId int64) ([]ReportSKU, error) {
 // 				panic("mock out the GetSkusForReport method")
 // 			},
 // 			GetWatchedArtistsFunc: func(tx *postgres.Tx) (map[int64][]WatchedArtist, error) {
 // 				panic("mock out the GetWatchedArtists method")
 // 			},
-// 			IncrementBatchSearchCompletedCountFunc: func(tx *postgres.Tx, batchId int64) error {
+// 			IncrementBatchSearchCompletedCountFunc: func(tx *postgres.Tx, batchId int64) (bool, error) {
 // 				panic("mock out the IncrementBatchSearchCompletedCount method")
 // 			},
+// 			IncrementBatchSearchFailedCountFunc: func(tx *postgres.Tx, batchId int64) (bool, error) {
+// 				panic("mock out the IncrementBatchSearchFailedCount method")
+// 			},
+// 			IncrementBatchSearchRetriedCountFunc: func(tx *postgres.Tx, batchId int64) error {
+// 				panic("mock out the IncrementBatchSearchRetriedCount method")
+// 			},
 // 			MarkBatchReportedFunc: func(tx *postgres.Tx, batchId int64) error {
 // 				panic("mock out the MarkBatchReported method")
 // 			},
 // 			MarkReportSentFunc: func(tx *postgres.Tx, reportId int64) error {
 // 				panic("mock out the MarkReportSent method")
 // 			},
+// 			MatchReleaseFunc: func(tx *postgres.Tx, artistID int64, retailerID int64, title string, itemURL string, barcodes []string, catalogueNos []string) (ReleaseMatch, error) {
+// 				panic("mock out the MatchRelease method")
+// 			},
+// 			MoveScheduleNextRunFunc: func(tx *postgres.Tx, scheduleID int64, from null.Time, to time.Time) (bool, error) {
+// 				panic("mock out the MoveScheduleNextRun method")
+// 			},
 // 			QFunc: func(tx *postgres.Tx) postgres.Querier {
 // 				panic("mock out the Q method")
 // 			},
+// 			ResolveReleaseReviewFunc: func(tx *postgres.Tx, reviewID int64, accept bool) error {
+// 				panic("mock out the ResolveReleaseReview method")
+// 			},
+// 			SaveScanIntervalsFunc: func(tx *postgres.Tx, intervals []ScanInterval) error {
+// 				panic("mock out the SaveScanIntervals method")
+// 			},
+// 			SetFollowFilterFunc: func(tx *postgres.Tx, userID int64, artistID int64, vinylOnly bool, editionFilter string) error {
+// 				panic("mock out the SetFollowFilter method")
+// 			},
+// 			SetSKUMissedScansFunc: func(tx *postgres.Tx, skuID int64, missedScans int) error {
+// 				panic("mock out the SetSKUMissedScans method")
+// 			},
+// 			SetScheduleLastRunFunc: func(tx *postgres.Tx, scheduleID int64, ranAt time.Time, batchID int64) error {
+// 				panic("mock out the SetScheduleLastRun method")
+// 			},
 // 			StartTransactionFunc: func() (*postgres.Tx, error) {
 // 				panic("mock out the StartTransaction method")
 // 			},
@@ -79,7 +152,7 @@ var _ VinylDS = &VinylDSMock{}
 // 			UpsertReleaseFunc: func(tx *postgres.Tx, artistId int64, title string) (int64, error) {
 // 				panic("mock out the UpsertRelease method")
 // 			},
-// 			UpsertSKUFunc: func(tx *postgres.Tx, sku *SKU) (bool, error) {
+// 			UpsertSKUFunc: func(tx *postgres.Tx, sku *SKU) (SKUChange, error) {
 // 				panic("mock out the UpsertSKU method")
 // 			},
 // 			VerifySchemaFunc: func() error {
@@ -98,8 +171,17 @@ type VinylDSMock struct {
 	// AddNewBatchFunc mocks the AddNewBatch method.
 	AddNewBatchFunc func(tx *postgres.Tx, numRequiredSearches int, userArtists map[int64][]WatchedArtist) (int64, error)
 
+	// AddOutstandingScansFunc mocks the AddOutstandingScans method.
+	AddOutstandingScansFunc func(tx *postgres.Tx, batchID int64, scans []OutstandingScan) error
+
 	// AddSKUToReportsForBatchFunc mocks the AddSKUToReportsForBatch method.
-	AddSKUToReportsForBatchFunc func(tx *postgres.Tx, batchId int64, sku *SKU) error
+	AddSKUToReportsForBatchFunc func(tx *postgres.Tx, batchId int64, sku *SKU, change SKUChange) error
+
+	// AddScanFunc mocks the AddScan method.
+	AddScanFunc func(tx *postgres.Tx, scan *Scan) error
+
+	// ClearOutstandingScanFunc mocks the ClearOutstandingScan method.
+	ClearOutstandingScanFunc func(tx *postgres.Tx, batchID int64, artistID int64, retailerID int64) (bool, error)
 
 	// CloseTransactionFunc mocks the CloseTransaction method.
 	CloseTransactionFunc func(tx *postgres.Tx, err error) error
@@ -128,14 +210,56 @@ type VinylDSMock struct {
 	// GetCurrentSKUForReleaseFunc mocks the GetCurrentSKUForRelease method.
 	GetCurrentSKUForReleaseFunc func(tx *postgres.Tx, releaseID int64, retailerID int64) (*SKU, error)
 
+	// GetCurrentSKUsFunc mocks the GetCurrentSKUs method.
+	GetCurrentSKUsFunc func(tx *postgres.Tx, artistID int64, retailerID int64) ([]SKU, error)
+
+	// GetEnabledSchedulesFunc mocks the GetEnabledSchedules method.
+	GetEnabledSchedulesFunc func(tx *postgres.Tx) ([]Schedule, error)
+
+	// GetListingChangesFunc mocks the GetListingChanges method.
+	GetListingChangesFunc func(tx *postgres.Tx, changesSince time.Time, arrivalsSince time.Time) ([]ListingChanges, error)
+
+	// GetOutstandingScansFunc mocks the GetOutstandingScans method.
+	GetOutstandingScansFunc func(tx *postgres.Tx, queuedSince time.Time) ([]OutstandingScan, error)
+
+	// GetPendingReleaseReviewsFunc mocks the GetPendingReleaseReviews method.
+	GetPendingReleaseReviewsFunc func(tx *postgres.Tx) ([]ReleaseReview, error)
+
+	// GetReleasePricesFunc mocks the GetReleasePrices method.
+	GetReleasePricesFunc func(tx *postgres.Tx, releaseID int64) ([]SKU, error)
+
+	// GetReleasesByBarcodeFunc mocks the GetReleasesByBarcode method.
+	GetReleasesByBarcodeFunc func(tx *postgres.Tx, barcode string) ([]Release, error)
+
+	// GetReleasesForArtistFunc mocks the GetReleasesForArtist method.
+	GetReleasesForArtistFunc func(tx *postgres.Tx, artistID int64) ([]Release, error)
+
+	// GetRetailerFunc mocks the GetRetailer method.
+	GetRetailerFunc func(tx *postgres.Tx, retailerId int64) (*Retailer, error)
+
+	// GetRetailerHealthFunc mocks the GetRetailerHealth method.
+	GetRetailerHealthFunc func(tx *postgres.Tx, retailerID *int64, window time.Duration, baseline time.Duration, now time.Time) ([]RetailerHealth, error)
+
+	// GetScanIntervalsFunc mocks the GetScanIntervals method.
+	GetScanIntervalsFunc func(tx *postgres.Tx, retailerID *int64) ([]ScanInterval, error)
+
+	// GetScansForBatchFunc mocks the GetScansForBatch method.
+	GetScansForBatchFunc func(tx *postgres.Tx, batchID int64) ([]Scan, error)
+
 	// GetSkusForReportFunc mocks the GetSkusForReport method.
-	GetSkusForReportFunc func(tx *postgres.Tx, reportId int64) ([]retailers.SKU, error)
+	GetSkusForReportFunc func(tx *postgres.Tx, reportId int64) ([]ReportSKU, error)
 
 	// GetWatchedArtistsFunc mocks the GetWatchedArtists method.
 	GetWatchedArtistsFunc func(tx *postgres.Tx) (map[int64][]WatchedArtist, error)
 
 	// IncrementBatchSearchCompletedCountFunc mocks the IncrementBatchSearchCompletedCount method.
-	IncrementBatchSearchCompletedCountFunc func(tx *postgres.Tx, batchId int64) error
+	IncrementBatchSearchCompletedCountFunc func(tx *postgres.Tx, batchId int64) (bool, error)
+
+	// IncrementBatchSearchFailedCountFunc mocks the IncrementBatchSearchFailedCount method.
+	IncrementBatchSearchFailedCountFunc func(tx *postgres.Tx, batchId int64) (bool, error)
+
+	// IncrementBatchSearchRetriedCountFunc mocks the IncrementBatchSearchRetriedCount method.
+	IncrementBatchSearchRetriedCountFunc func(tx *postgres.Tx, batchId int64) error
 
 	// MarkBatchReportedFunc mocks the MarkBatchReported method.
 	MarkBatchReportedFunc func(tx *postgres.Tx, batchId int64) error
@@ -143,9 +267,30 @@ type VinylDSMock struct {
 	// MarkReportSentFunc mocks the MarkReportSent method.
 	MarkReportSentFunc func(tx *postgres.Tx, reportId int64) error
 
+	// MatchReleaseFunc mocks the MatchRelease method.
+	MatchReleaseFunc func(tx *postgres.Tx, artistID int64, retailerID int64, title string, itemURL string, barcodes []string, catalogueNos []string) (ReleaseMatch, error)
+
+	// MoveScheduleNextRunFunc mocks the MoveScheduleNextRun method.
+	MoveScheduleNextRunFunc func(tx *postgres.Tx, scheduleID int64, from null.Time, to time.Time) (bool, error)
+
 	// QFunc mocks the Q method.
 	QFunc func(tx *postgres.Tx) postgres.Querier
 
+	// ResolveReleaseReviewFunc mocks the ResolveReleaseReview method.
+	ResolveReleaseReviewFunc func(tx *postgres.Tx, reviewID int64, accept bool) error
+
+	// SaveScanIntervalsFunc mocks the SaveScanIntervals method.
+	SaveScanIntervalsFunc func(tx *postgres.Tx, intervals []ScanInterval) error
+
+	// SetFollowFilterFunc mocks the SetFollowFilter method.
+	SetFollowFilterFunc func(tx *postgres.Tx, userID int64, artistID int64, vinylOnly bool, editionFilter string) error
+
+	// SetSKUMissedScansFunc mocks the SetSKUMissedScans method.
+	SetSKUMissedScansFunc func(tx *postgres.Tx, skuID int64, missedScans int) error
+
+	// SetScheduleLastRunFunc mocks the SetScheduleLastRun method.
+	SetScheduleLastRunFunc func(tx *postgres.Tx, scheduleID int64, ranAt time.Time, batchID int64) error
+
 	// StartTransactionFunc mocks the StartTransaction method.
 	StartTransactionFunc func() (*postgres.Tx, error)
 
@@ -156,7 +301,7 @@ type VinylDSMock struct {
 	UpsertReleaseFunc func(tx *postgres.Tx, artistId int64, title string) (int64, error)
 
 	// UpsertSKUFunc mocks the UpsertSKU method.
-	UpsertSKUFunc func(tx *postgres.Tx, sku *SKU) (bool, error)
+	UpsertSKUFunc func(tx *postgres.Tx, sku *SKU) (SKUChange, error)
 
 	// VerifySchemaFunc mocks the VerifySchema method.
 	VerifySchemaFunc func() error
@@ -175,6 +320,15 @@ type VinylDSMock struct {
 			// UserArtists is the userArtists argument value.
 			UserArtists map[int64][]WatchedArtist
 		}
+		// AddOutstandingScans holds details about calls to the AddOutstandingScans method.
+		AddOutstandingScans []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// BatchID is the batchID argument value.
+			BatchID int64
+			// Scans is the scans argument value.
+			Scans []OutstandingScan
+		}
 		// AddSKUToReportsForBatch holds details about calls to the AddSKUToReportsForBatch method.
 		AddSKUToReportsForBatch []struct {
 			// Tx is the tx argument value.
@@ -183,6 +337,26 @@ type VinylDSMock struct {
 			BatchId int64
 			// Sku is the sku argument value.
 			Sku *SKU
+			// Change is the change argument value.
+			Change SKUChange
+		}
+		// AddScan holds details about calls to the AddScan method.
+		AddScan []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// Scan is the scan argument value.
+			Scan *Scan
+		}
+		// ClearOutstandingScan holds details about calls to the ClearOutstandingScan method.
+		ClearOutstandingScan []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// BatchID is the batchID argument value.
+			BatchID int64
+			// ArtistID is the artistID argument value.
+			ArtistID int64
+			// RetailerID is the retailerID argument value.
+			RetailerID int64
 		}
 		// CloseTransaction holds details about calls to the CloseTransaction method.
 		CloseTransaction []struct {
@@ -245,6 +419,96 @@ type VinylDSMock struct {
 			// RetailerID is the retailerID argument value.
 			RetailerID int64
 		}
+		// GetCurrentSKUs holds details about calls to the GetCurrentSKUs method.
+		GetCurrentSKUs []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// ArtistID is the artistID argument value.
+			ArtistID int64
+			// RetailerID is the retailerID argument value.
+			RetailerID int64
+		}
+		// GetEnabledSchedules holds details about calls to the GetEnabledSchedules method.
+		GetEnabledSchedules []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+		}
+		// GetListingChanges holds details about calls to the GetListingChanges method.
+		GetListingChanges []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// ChangesSince is the changesSince argument value.
+			ChangesSince time.Time
+			// ArrivalsSince is the arrivalsSince argument value.
+			ArrivalsSince time.Time
+		}
+		// GetOutstandingScans holds details about calls to the GetOutstandingScans method.
+		GetOutstandingScans []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// QueuedSince is the queuedSince argument value.
+			QueuedSince time.Time
+		}
+		// GetPendingReleaseReviews holds details about calls to the GetPendingReleaseReviews method.
+		GetPendingReleaseReviews []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+		}
+		// GetReleasePrices holds details about calls to the GetReleasePrices method.
+		GetReleasePrices []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// ReleaseID is the releaseID argument value.
+			ReleaseID int64
+		}
+		// GetReleasesByBarcode holds details about calls to the GetReleasesByBarcode method.
+		GetReleasesByBarcode []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// Barcode is the barcode argument value.
+			Barcode string
+		}
+		// GetReleasesForArtist holds details about calls to the GetReleasesForArtist method.
+		GetReleasesForArtist []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// ArtistID is the artistID argument value.
+			ArtistID int64
+		}
+		// GetRetailer holds details about calls to the GetRetailer method.
+		GetRetailer []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// RetailerId is the retailerId argument value.
+			RetailerId int64
+		}
+		// GetRetailerHealth holds details about calls to the GetRetailerHealth method.
+		GetRetailerHealth []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// RetailerID is the retailerID argument value.
+			RetailerID *int64
+			// Window is the window argument value.
+			Window time.Duration
+			// Baseline is the baseline argument value.
+			Baseline time.Duration
+			// Now is the now argument value.
+			Now time.Time
+		}
+		// GetScanIntervals holds details about calls to the GetScanIntervals method.
+		GetScanIntervals []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// RetailerID is the retailerID argument value.
+			RetailerID *int64
+		}
+		// GetScansForBatch holds details about calls to the GetScansForBatch method.
+		GetScansForBatch []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// BatchID is the batchID argument value.
+			BatchID int64
+		}
 		// GetSkusForReport holds details about calls to the GetSkusForReport method.
 		GetSkusForReport []struct {
 			// Tx is the tx argument value.
@@ -264,6 +528,20 @@ type VinylDSMock struct {
 			// BatchId is the batchId argument value.
 			BatchId int64
 		}
+		// IncrementBatchSearchFailedCount holds details about calls to the IncrementBatchSearchFailedCount method.
+		IncrementBatchSearchFailedCount []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// BatchId is the batchId argument value.
+			BatchId int64
+		}
+		// IncrementBatchSearchRetriedCount holds details about calls to the IncrementBatchSearchRetriedCount method.
+		IncrementBatchSearchRetriedCount []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// BatchId is the batchId argument value.
+			BatchId int64
+		}
 		// MarkBatchReported holds details about calls to the MarkBatchReported method.
 		MarkBatchReported []struct {
 			// Tx is the tx argument value.
@@ -278,11 +556,88 @@ type VinylDSMock struct {
 			// ReportId is the reportId argument value.
 			ReportId int64
 		}
+		// MatchRelease holds details about calls to the MatchRelease method.
+		MatchRelease []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// ArtistID is the artistID argument value.
+			ArtistID int64
+			// RetailerID is the retailerID argument value.
+			RetailerID int64
+			// Title is the title argument value.
+			Title string
+			// ItemURL is the itemURL argument value.
+			ItemURL string
+			// Barcodes is the barcodes argument value.
+			Barcodes []string
+			// CatalogueNos is the catalogueNos argument value.
+			CatalogueNos []string
+		}
+		// MoveScheduleNextRun holds details about calls to the MoveScheduleNextRun method.
+		MoveScheduleNextRun []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// ScheduleID is the scheduleID argument value.
+			ScheduleID int64
+			// From is the from argument value.
+			From null.Time
+			// To is the to argument value.
+			To time.Time
+		}
 		// Q holds details about calls to the Q method.
 		Q []struct {
 			// Tx is the tx argument value.
 			Tx *postgres.Tx
 		}
+		// ResolveReleaseReview holds details about calls to the ResolveReleaseReview method.
+		ResolveReleaseReview []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// ReviewID is the reviewID argument value.
+			ReviewID int64
+			// Accept is the accept argument value.
+			Accept bool
+		}
+		// SaveScanIntervals holds details about calls to the SaveScanIntervals method.
+		SaveScanIntervals []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// Intervals is the intervals argument value.
+			Intervals []ScanInterval
+		}
+		// SetFollowFilter holds details about calls to the SetFollowFilter method.
+		SetFollowFilter []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// UserID is the userID argument value.
+			UserID int64
+			// ArtistID is the artistID argument value.
+			ArtistID int64
+			// VinylOnly is the vinylOnly argument value.
+			VinylOnly bool
+			// EditionFilter is the editionFilter argument value.
+			EditionFilter string
+		}
+		// SetSKUMissedScans holds details about calls to the SetSKUMissedScans method.
+		SetSKUMissedScans []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// SkuID is the skuID argument value.
+			SkuID int64
+			// MissedScans is the missedScans argument value.
+			MissedScans int
+		}
+		// SetScheduleLastRun holds details about calls to the SetScheduleLastRun method.
+		SetScheduleLastRun []struct {
+			// Tx is the tx argument value.
+			Tx *postgres.Tx
+			// ScheduleID is the scheduleID argument value.
+			ScheduleID int64
+			// RanAt is the ranAt argument value.
+			RanAt time.Time
+			// BatchID is the batchID argument value.
+			BatchID int64
+		}
 		// StartTransaction holds details about calls to the StartTransaction method.
 		StartTransaction []struct {
 		}
@@ -319,7 +674,10 @@ type VinylDSMock struct {
 		}
 	}
 	lockAddNewBatch                        sync.RWMutex
+	lockAddOutstandingScans                sync.RWMutex
 	lockAddSKUToReportsForBatch            sync.RWMutex
+	lockAddScan                            sync.RWMutex
+	lockClearOutstandingScan               sync.RWMutex
 	lockCloseTransaction                   sync.RWMutex
 	lockDeleteBatch                        sync.RWMutex
 	lockDeleteReport                       sync.RWMutex
@@ -329,12 +687,33 @@ type VinylDSMock struct {
 	lockGetAllRetailers                    sync.RWMutex
 	lockGetAllSKUs                         sync.RWMutex
 	lockGetCurrentSKUForRelease            sync.RWMutex
+	lockGetCurrentSKUs                     sync.RWMutex
+	lockGetEnabledSchedules                sync.RWMutex
+	lockGetListingChanges                  sync.RWMutex
+	lockGetOutstandingScans                sync.RWMutex
+	lockGetPendingReleaseReviews           sync.RWMutex
+	lockGetReleasePrices                   sync.RWMutex
+	lockGetReleasesByBarcode               sync.RWMutex
+	lockGetReleasesForArtist               sync.RWMutex
+	lockGetRetailer                        sync.RWMutex
+	lockGetRetailerHealth                  sync.RWMutex
+	lockGetScanIntervals                   sync.RWMutex
+	lockGetScansForBatch                   sync.RWMutex
 	lockGetSkusForReport                   sync.RWMutex
 	lockGetWatchedArtists                  sync.RWMutex
 	lockIncrementBatchSearchCompletedCount sync.RWMutex
+	lockIncrementBatchSearchFailedCount    sync.RWMutex
+	lockIncrementBatchSearchRetriedCount   sync.RWMutex
 	lockMarkBatchReported                  sync.RWMutex
 	lockMarkReportSent                     sync.RWMutex
+	lockMatchRelease                       sync.RWMutex
+	lockMoveScheduleNextRun                sync.RWMutex
 	lockQ                                  sync.RWMutex
+	lockResolveReleaseReview               sync.RWMutex
+	lockSaveScanIntervals                  sync.RWMutex
+	lockSetFollowFilter                    sync.RWMutex
+	lockSetSKUMissedScans                  sync.RWMutex
+	lockSetScheduleLastRun                 sync.RWMutex
 	lockStartTransaction                   sync.RWMutex
 	lockUpdateSKU                          sync.RWMutex
 	lockUpsertRelease                      sync.RWMutex
@@ -382,8 +761,47 @@ func (mock *VinylDSMock) AddNewBatchCalls() []struct {
 	return calls
 }
 
+// AddOutstandingScans calls AddOutstandingScansFunc.
+func (mock *VinylDSMock) AddOutstandingScans(tx *postgres.Tx, batchID int64, scans []OutstandingScan) error {
+	if mock.AddOutstandingScansFunc == nil {
+		panic("VinylDSMock.AddOutstandingScansFunc: method is nil but VinylDS.AddOutstandingScans was just called")
+	}
+	callInfo := struct {
+		Tx      *postgres.Tx
+		BatchID int64
+		Scans   []OutstandingScan
+	}{
+		Tx:      tx,
+		BatchID: batchID,
+		Scans:   scans,
+	}
+	mock.lockAddOutstandingScans.Lock()
+	mock.calls.AddOutstandingScans = append(mock.calls.AddOutstandingScans, callInfo)
+	mock.lockAddOutstandingScans.Unlock()
+	return mock.AddOutstandingScansFunc(tx, batchID, scans)
+}
+
+// AddOutstandingScansCalls gets all the calls that were made to AddOutstandingScans.
+// Check the length with:
+//     len(mockedVinylDS.AddOutstandingScansCalls())
+func (mock *VinylDSMock) AddOutstandingScansCalls() []struct {
+	Tx      *postgres.Tx
+	BatchID int64
+	Scans   []OutstandingScan
+} {
+	var calls []struct {
+		Tx      *postgres.Tx
+		BatchID int64
+		Scans   []OutstandingScan
+	}
+	mock.lockAddOutstandingScans.RLock()
+	calls = mock.calls.AddOutstandingScans
+	mock.lockAddOutstandingScans.RUnlock()
+	return calls
+}
+
 // AddSKUToReportsForBatch calls AddSKUToReportsForBatchFunc.
-func (mock *VinylDSMock) AddSKUToReportsForBatch(tx *postgres.Tx, batchId int64, sku *SKU) error {
+func (mock *VinylDSMock) AddSKUToReportsForBatch(tx *postgres.Tx, batchId int64, sku *SKU, change SKUChange) error {
 	if mock.AddSKUToReportsForBatchFunc == nil {
 		panic("VinylDSMock.AddSKUToReportsForBatchFunc: method is nil but VinylDS.AddSKUToReportsForBatch was just called")
 	}
@@ -391,15 +809,17 @@ func (mock *VinylDSMock) AddSKUToReportsForBatch(tx *postgres.Tx, batchId int64,
 		Tx      *postgres.Tx
 		BatchId int64
 		Sku     *SKU
+		Change  SKUChange
 	}{
 		Tx:      tx,
 		BatchId: batchId,
 		Sku:     sku,
+		Change:  change,
 	}
 	mock.lockAddSKUToReportsForBatch.Lock()
 	mock.calls.AddSKUToReportsForBatch = append(mock.calls.AddSKUToReportsForBatch, callInfo)
 	mock.lockAddSKUToReportsForBatch.Unlock()
-	return mock.AddSKUToReportsForBatchFunc(tx, batchId, sku)
+	return mock.AddSKUToReportsForBatchFunc(tx, batchId, sku, change)
 }
 
 // AddSKUToReportsForBatchCalls gets all the calls that were made to AddSKUToReportsForBatch.
@@ -409,11 +829,13 @@ func (mock *VinylDSMock) AddSKUToReportsForBatchCalls() []struct {
 	Tx      *postgres.Tx
 	BatchId int64
 	Sku     *SKU
+	Change  SKUChange
 } {
 	var calls []struct {
 		Tx      *postgres.Tx
 		BatchId int64
 		Sku     *SKU
+		Change  SKUChange
 	}
 	mock.lockAddSKUToReportsForBatch.RLock()
 	calls = mock.calls.AddSKUToReportsForBatch
@@ -421,6 +843,84 @@ func (mock *VinylDSMock) AddSKUToReportsForBatchCalls() []struct {
 	return calls
 }
 
+// AddScan calls AddScanFunc.
+func (mock *VinylDSMock) AddScan(tx *postgres.Tx, scan *Scan) error {
+	if mock.AddScanFunc == nil {
+		panic("VinylDSMock.AddScanFunc: method is nil but VinylDS.AddScan was just called")
+	}
+	callInfo := struct {
+		Tx   *postgres.Tx
+		Scan *Scan
+	}{
+		Tx:   tx,
+		Scan: scan,
+	}
+	mock.lockAddScan.Lock()
+	mock.calls.AddScan = append(mock.calls.AddScan, callInfo)
+	mock.lockAddScan.Unlock()
+	return mock.AddScanFunc(tx, scan)
+}
+
+// AddScanCalls gets all the calls that were made to AddScan.
+// Check the length with:
+//     len(mockedVinylDS.AddScanCalls())
+func (mock *VinylDSMock) AddScanCalls() []struct {
+	Tx   *postgres.Tx
+	Scan *Scan
+} {
+	var calls []struct {
+		Tx   *postgres.Tx
+		Scan *Scan
+	}
+	mock.lockAddScan.RLock()
+	calls = mock.calls.AddScan
+	mock.lockAddScan.RUnlock()
+	return calls
+}
+
+// ClearOutstandingScan calls ClearOutstandingScanFunc.
+func (mock *VinylDSMock) ClearOutstandingScan(tx *postgres.Tx, batchID int64, artistID int64, retailerID int64) (bool, error) {
+	if mock.ClearOutstandingScanFunc == nil {
+		panic("VinylDSMock.ClearOutstandingScanFunc: method is nil but VinylDS.ClearOutstandingScan was just called")
+	}
+	callInfo := struct {
+		Tx         *postgres.Tx
+		BatchID    int64
+		ArtistID   int64
+		RetailerID int64
+	}{
+		Tx:         tx,
+		BatchID:    batchID,
+		ArtistID:   artistID,
+		RetailerID: retailerID,
+	}
+	mock.lockClearOutstandingScan.Lock()
+	mock.calls.ClearOutstandingScan = append(mock.calls.ClearOutstandingScan, callInfo)
+	mock.lockClearOutstandingScan.Unlock()
+	return mock.ClearOutstandingScanFunc(tx, batchID, artistID, retailerID)
+}
+
+// ClearOutstandingScanCalls gets all the calls that were made to ClearOutstandingScan.
+// Check the length with:
+//     len(mockedVinylDS.ClearOutstandingScanCalls())
+func (mock *VinylDSMock) ClearOutstandingScanCalls() []struct {
+	Tx         *postgres.Tx
+	BatchID    int64
+	ArtistID   int64
+	RetailerID int64
+} {
+	var calls []struct {
+		Tx         *postgres.Tx
+		BatchID    int64
+		ArtistID   int64
+		RetailerID int64
+	}
+	mock.lockClearOutstandingScan.RLock()
+	calls = mock.calls.ClearOutstandingScan
+	mock.lockClearOutstandingScan.RUnlock()
+	return calls
+}
+
 // CloseTransaction calls CloseTransactionFunc.
 func (mock *VinylDSMock) CloseTransaction(tx *postgres.Tx, err error) error {
 	if mock.CloseTransactionFunc == nil {
@@ -732,110 +1232,612 @@ func (mock *VinylDSMock) GetCurrentSKUForReleaseCalls() []struct {
 	return calls
 }
 
-// GetSkusForReport calls GetSkusForReportFunc.
-func (mock *VinylDSMock) GetSkusForReport(tx *postgres.Tx, reportId int64) ([]retailers.SKU, error) {
-	if mock.GetSkusForReportFunc == nil {
-		panic("VinylDSMock.GetSkusForReportFunc: method is nil but VinylDS.GetSkusForReport was just called")
+// GetCurrentSKUs calls GetCurrentSKUsFunc.
+func (mock *VinylDSMock) GetCurrentSKUs(tx *postgres.Tx, artistID int64, retailerID int64) ([]SKU, error) {
+	if mock.GetCurrentSKUsFunc == nil {
+		panic("VinylDSMock.GetCurrentSKUsFunc: method is nil but VinylDS.GetCurrentSKUs was just called")
 	}
 	callInfo := struct {
-		Tx       *postgres.Tx
-		ReportId int64
+		Tx         *postgres.Tx
+		ArtistID   int64
+		RetailerID int64
 	}{
-		Tx:       tx,
-		ReportId: reportId,
+		Tx:         tx,
+		ArtistID:   artistID,
+		RetailerID: retailerID,
 	}
-	mock.lockGetSkusForReport.Lock()
-	mock.calls.GetSkusForReport = append(mock.calls.GetSkusForReport, callInfo)
-	mock.lockGetSkusForReport.Unlock()
-	return mock.GetSkusForReportFunc(tx, reportId)
+	mock.lockGetCurrentSKUs.Lock()
+	mock.calls.GetCurrentSKUs = append(mock.calls.GetCurrentSKUs, callInfo)
+	mock.lockGetCurrentSKUs.Unlock()
+	return mock.GetCurrentSKUsFunc(tx, artistID, retailerID)
 }
 
-// GetSkusForReportCalls gets all the calls that were made to GetSkusForReport.
+// GetCurrentSKUsCalls gets all the calls that were made to GetCurrentSKUs.
 // Check the length with:
-//     len(mockedVinylDS.GetSkusForReportCalls())
-func (mock *VinylDSMock) GetSkusForReportCalls() []struct {
-	Tx       *postgres.Tx
-	ReportId int64
+//     len(mockedVinylDS.GetCurrentSKUsCalls())
+func (mock *VinylDSMock) GetCurrentSKUsCalls() []struct {
+	Tx         *postgres.Tx
+	ArtistID   int64
+	RetailerID int64
 } {
 	var calls []struct {
-		Tx       *postgres.Tx
-		ReportId int64
+		Tx         *postgres.Tx
+		ArtistID   int64
+		RetailerID int64
 	}
-	mock.lockGetSkusForReport.RLock()
-	calls = mock.calls.GetSkusForReport
-	mock.lockGetSkusForReport.RUnlock()
+	mock.lockGetCurrentSKUs.RLock()
+	calls = mock.calls.GetCurrentSKUs
+	mock.lockGetCurrentSKUs.RUnlock()
 	return calls
 }
 
-// GetWatchedArtists calls GetWatchedArtistsFunc.
-func (mock *VinylDSMock) GetWatchedArtists(tx *postgres.Tx) (map[int64][]WatchedArtist, error) {
-	if mock.GetWatchedArtistsFunc == nil {
-		panic("VinylDSMock.GetWatchedArtistsFunc: method is nil but VinylDS.GetWatchedArtists was just called")
+// GetEnabledSchedules calls GetEnabledSchedulesFunc.
+func (mock *VinylDSMock) GetEnabledSchedules(tx *postgres.Tx) ([]Schedule, error) {
+	if mock.GetEnabledSchedulesFunc == nil {
+		panic("VinylDSMock.GetEnabledSchedulesFunc: method is nil but VinylDS.GetEnabledSchedules was just called")
 	}
 	callInfo := struct {
 		Tx *postgres.Tx
 	}{
 		Tx: tx,
 	}
-	mock.lockGetWatchedArtists.Lock()
-	mock.calls.GetWatchedArtists = append(mock.calls.GetWatchedArtists, callInfo)
-	mock.lockGetWatchedArtists.Unlock()
-	return mock.GetWatchedArtistsFunc(tx)
+	mock.lockGetEnabledSchedules.Lock()
+	mock.calls.GetEnabledSchedules = append(mock.calls.GetEnabledSchedules, callInfo)
+	mock.lockGetEnabledSchedules.Unlock()
+	return mock.GetEnabledSchedulesFunc(tx)
 }
 
-// GetWatchedArtistsCalls gets all the calls that were made to GetWatchedArtists.
+// GetEnabledSchedulesCalls gets all the calls that were made to GetEnabledSchedules.
 // Check the length with:
-//     len(mockedVinylDS.GetWatchedArtistsCalls())
-func (mock *VinylDSMock) GetWatchedArtistsCalls() []struct {
+//     len(mockedVinylDS.GetEnabledSchedulesCalls())
+func (mock *VinylDSMock) GetEnabledSchedulesCalls() []struct {
 	Tx *postgres.Tx
 } {
 	var calls []struct {
 		Tx *postgres.Tx
 	}
-	mock.lockGetWatchedArtists.RLock()
-	calls = mock.calls.GetWatchedArtists
-	mock.lockGetWatchedArtists.RUnlock()
+	mock.lockGetEnabledSchedules.RLock()
+	calls = mock.calls.GetEnabledSchedules
+	mock.lockGetEnabledSchedules.RUnlock()
 	return calls
 }
 
-// IncrementBatchSearchCompletedCount calls IncrementBatchSearchCompletedCountFunc.
-func (mock *VinylDSMock) IncrementBatchSearchCompletedCount(tx *postgres.Tx, batchId int64) error {
-	if mock.IncrementBatchSearchCompletedCountFunc == nil {
-		panic("VinylDSMock.IncrementBatchSearchCompletedCountFunc: method is nil but VinylDS.IncrementBatchSearchCompletedCount was just called")
+// GetListingChanges calls GetListingChangesFunc.
+func (mock *VinylDSMock) GetListingChanges(tx *postgres.Tx, changesSince time.Time, arrivalsSince time.Time) ([]ListingChanges, error) {
+	if mock.GetListingChangesFunc == nil {
+		panic("VinylDSMock.GetListingChangesFunc: method is nil but VinylDS.GetListingChanges was just called")
 	}
 	callInfo := struct {
-		Tx      *postgres.Tx
-		BatchId int64
+		Tx            *postgres.Tx
+		ChangesSince  time.Time
+		ArrivalsSince time.Time
 	}{
-		Tx:      tx,
-		BatchId: batchId,
-	}
-	mock.lockIncrementBatchSearchCompletedCount.Lock()
-	mock.calls.IncrementBatchSearchCompletedCount = append(mock.calls.IncrementBatchSearchCompletedCount, callInfo)
-	mock.lockIncrementBatchSearchCompletedCount.Unlock()
-	return mock.IncrementBatchSearchCompletedCountFunc(tx, batchId)
+		Tx:            tx,
+		ChangesSince:  changesSince,
+		ArrivalsSince: arrivalsSince,
+	}
+	mock.lockGetListingChanges.Lock()
+	mock.calls.GetListingChanges = append(mock.calls.GetListingChanges, callInfo)
+	mock.lockGetListingChanges.Unlock()
+	return mock.GetListingChangesFunc(tx, changesSince, arrivalsSince)
 }
 
-// IncrementBatchSearchCompletedCountCalls gets all the calls that were made to IncrementBatchSearchCompletedCount.
+// GetListingChangesCalls gets all the calls that were made to GetListingChanges.
 // Check the length with:
-//     len(mockedVinylDS.IncrementBatchSearchCompletedCountCalls())
-func (mock *VinylDSMock) IncrementBatchSearchCompletedCountCalls() []struct {
-	Tx      *postgres.Tx
-	BatchId int64
+//     len(mockedVinylDS.GetListingChangesCalls())
+func (mock *VinylDSMock) GetListingChangesCalls() []struct {
+	Tx            *postgres.Tx
+	ChangesSince  time.Time
+	ArrivalsSince time.Time
 } {
 	var calls []struct {
-		Tx      *postgres.Tx
-		BatchId int64
+		Tx            *postgres.Tx
+		ChangesSince  time.Time
+		ArrivalsSince time.Time
 	}
-	mock.lockIncrementBatchSearchCompletedCount.RLock()
-	calls = mock.calls.IncrementBatchSearchCompletedCount
-	mock.lockIncrementBatchSearchCompletedCount.RUnlock()
+	mock.lockGetListingChanges.RLock()
+	calls = mock.calls.GetListingChanges
+	mock.lockGetListingChanges.RUnlock()
 	return calls
 }
 
-// MarkBatchReported calls MarkBatchReportedFunc.
-func (mock *VinylDSMock) MarkBatchReported(tx *postgres.Tx, batchId int64) error {
-	if mock.MarkBatchReportedFunc == nil {
+// GetOutstandingScans calls GetOutstandingScansFunc.
+func (mock *VinylDSMock) GetOutstandingScans(tx *postgres.Tx, queuedSince time.Time) ([]OutstandingScan, error) {
+	if mock.GetOutstandingScansFunc == nil {
+		panic("VinylDSMock.GetOutstandingScansFunc: method is nil but VinylDS.GetOutstandingScans was just called")
+	}
+	callInfo := struct {
+		Tx          *postgres.Tx
+		QueuedSince time.Time
+	}{
+		Tx:          tx,
+		QueuedSince: queuedSince,
+	}
+	mock.lockGetOutstandingScans.Lock()
+	mock.calls.GetOutstandingScans = append(mock.calls.GetOutstandingScans, callInfo)
+	mock.lockGetOutstandingScans.Unlock()
+	return mock.GetOutstandingScansFunc(tx, queuedSince)
+}
+
+// GetOutstandingScansCalls gets all the calls that were made to GetOutstandingScans.
+// Check the length with:
+//     len(mockedVinylDS.GetOutstandingScansCalls())
+func (mock *VinylDSMock) GetOutstandingScansCalls() []struct {
+	Tx          *postgres.Tx
+	QueuedSince time.Time
+} {
+	var calls []struct {
+		Tx          *postgres.Tx
+		QueuedSince time.Time
+	}
+	mock.lockGetOutstandingScans.RLock()
+	calls = mock.calls.GetOutstandingScans
+	mock.lockGetOutstandingScans.RUnlock()
+	return calls
+}
+
+// GetPendingReleaseReviews calls GetPendingReleaseReviewsFunc.
+func (mock *VinylDSMock) GetPendingReleaseReviews(tx *postgres.Tx) ([]ReleaseReview, error) {
+	if mock.GetPendingReleaseReviewsFunc == nil {
+		panic("VinylDSMock.GetPendingReleaseReviewsFunc: method is nil but VinylDS.GetPendingReleaseReviews was just called")
+	}
+	callInfo := struct {
+		Tx *postgres.Tx
+	}{
+		Tx: tx,
+	}
+	mock.lockGetPendingReleaseReviews.Lock()
+	mock.calls.GetPendingReleaseReviews = append(mock.calls.GetPendingReleaseReviews, callInfo)
+	mock.lockGetPendingReleaseReviews.Unlock()
+	return mock.GetPendingReleaseReviewsFunc(tx)
+}
+
+// GetPendingReleaseReviewsCalls gets all the calls that were made to GetPendingReleaseReviews.
+// Check the length with:
+//     len(mockedVinylDS.GetPendingReleaseReviewsCalls())
+func (mock *VinylDSMock) GetPendingReleaseReviewsCalls() []struct {
+	Tx *postgres.Tx
+} {
+	var calls []struct {
+		Tx *postgres.Tx
+	}
+	mock.lockGetPendingReleaseReviews.RLock()
+	calls = mock.calls.GetPendingReleaseReviews
+	mock.lockGetPendingReleaseReviews.RUnlock()
+	return calls
+}
+
+// GetReleasePrices calls GetReleasePricesFunc.
+func (mock *VinylDSMock) GetReleasePrices(tx *postgres.Tx, releaseID int64) ([]SKU, error) {
+	if mock.GetReleasePricesFunc == nil {
+		panic("VinylDSMock.GetReleasePricesFunc: method is nil but VinylDS.GetReleasePrices was just called")
+	}
+	callInfo := struct {
+		Tx        *postgres.Tx
+		ReleaseID int64
+	}{
+		Tx:        tx,
+		ReleaseID: releaseID,
+	}
+	mock.lockGetReleasePrices.Lock()
+	mock.calls.GetReleasePrices = append(mock.calls.GetReleasePrices, callInfo)
+	mock.lockGetReleasePrices.Unlock()
+	return mock.GetReleasePricesFunc(tx, releaseID)
+}
+
+// GetReleasePricesCalls gets all the calls that were made to GetReleasePrices.
+// Check the length with:
+//     len(mockedVinylDS.GetReleasePricesCalls())
+func (mock *VinylDSMock) GetReleasePricesCalls() []struct {
+	Tx        *postgres.Tx
+	ReleaseID int64
+} {
+	var calls []struct {
+		Tx        *postgres.Tx
+		ReleaseID int64
+	}
+	mock.lockGetReleasePrices.RLock()
+	calls = mock.calls.GetReleasePrices
+	mock.lockGetReleasePrices.RUnlock()
+	return calls
+}
+
+// GetReleasesByBarcode calls GetReleasesByBarcodeFunc.
+func (mock *VinylDSMock) GetReleasesByBarcode(tx *postgres.Tx, barcode string) ([]Release, error) {
+	if mock.GetReleasesByBarcodeFunc == nil {
+		panic("VinylDSMock.GetReleasesByBarcodeFunc: method is nil but VinylDS.GetReleasesByBarcode was just called")
+	}
+	callInfo := struct {
+		Tx      *postgres.Tx
+		Barcode string
+	}{
+		Tx:      tx,
+		Barcode: barcode,
+	}
+	mock.lockGetReleasesByBarcode.Lock()
+	mock.calls.GetReleasesByBarcode = append(mock.calls.GetReleasesByBarcode, callInfo)
+	mock.lockGetReleasesByBarcode.Unlock()
+	return mock.GetReleasesByBarcodeFunc(tx, barcode)
+}
+
+// GetReleasesByBarcodeCalls gets all the calls that were made to GetReleasesByBarcode.
+// Check the length with:
+//     len(mockedVinylDS.GetReleasesByBarcodeCalls())
+func (mock *VinylDSMock) GetReleasesByBarcodeCalls() []struct {
+	Tx      *postgres.Tx
+	Barcode string
+} {
+	var calls []struct {
+		Tx      *postgres.Tx
+		Barcode string
+	}
+	mock.lockGetReleasesByBarcode.RLock()
+	calls = mock.calls.GetReleasesByBarcode
+	mock.lockGetReleasesByBarcode.RUnlock()
+	return calls
+}
+
+// GetReleasesForArtist calls GetReleasesForArtistFunc.
+func (mock *VinylDSMock) GetReleasesForArtist(tx *postgres.Tx, artistID int64) ([]Release, error) {
+	if mock.GetReleasesForArtistFunc == nil {
+		panic("VinylDSMock.GetReleasesForArtistFunc: method is nil but VinylDS.GetReleasesForArtist was just called")
+	}
+	callInfo := struct {
+		Tx       *postgres.Tx
+		ArtistID int64
+	}{
+		Tx:       tx,
+		ArtistID: artistID,
+	}
+	mock.lockGetReleasesForArtist.Lock()
+	mock.calls.GetReleasesForArtist = append(mock.calls.GetReleasesForArtist, callInfo)
+	mock.lockGetReleasesForArtist.Unlock()
+	return mock.GetReleasesForArtistFunc(tx, artistID)
+}
+
+// GetReleasesForArtistCalls gets all the calls that were made to GetReleasesForArtist.
+// Check the length with:
+//     len(mockedVinylDS.GetReleasesForArtistCalls())
+func (mock *VinylDSMock) GetReleasesForArtistCalls() []struct {
+	Tx       *postgres.Tx
+	ArtistID int64
+} {
+	var calls []struct {
+		Tx       *postgres.Tx
+		ArtistID int64
+	}
+	mock.lockGetReleasesForArtist.RLock()
+	calls = mock.calls.GetReleasesForArtist
+	mock.lockGetReleasesForArtist.RUnlock()
+	return calls
+}
+
+// GetRetailer calls GetRetailerFunc.
+func (mock *VinylDSMock) GetRetailer(tx *postgres.Tx, retailerId int64) (*Retailer, error) {
+	if mock.GetRetailerFunc == nil {
+		panic("VinylDSMock.GetRetailerFunc: method is nil but VinylDS.GetRetailer was just called")
+	}
+	callInfo := struct {
+		Tx         *postgres.Tx
+		RetailerId int64
+	}{
+		Tx:         tx,
+		RetailerId: retailerId,
+	}
+	mock.lockGetRetailer.Lock()
+	mock.calls.GetRetailer = append(mock.calls.GetRetailer, callInfo)
+	mock.lockGetRetailer.Unlock()
+	return mock.GetRetailerFunc(tx, retailerId)
+}
+
+// GetRetailerCalls gets all the calls that were made to GetRetailer.
+// Check the length with:
+//     len(mockedVinylDS.GetRetailerCalls())
+func (mock *VinylDSMock) GetRetailerCalls() []struct {
+	Tx         *postgres.Tx
+	RetailerId int64
+} {
+	var calls []struct {
+		Tx         *postgres.Tx
+		RetailerId int64
+	}
+	mock.lockGetRetailer.RLock()
+	calls = mock.calls.GetRetailer
+	mock.lockGetRetailer.RUnlock()
+	return calls
+}
+
+// GetRetailerHealth calls GetRetailerHealthFunc.
+func (mock *VinylDSMock) GetRetailerHealth(tx *postgres.Tx, retailerID *int64, window time.Duration, baseline time.Duration, now time.Time) ([]RetailerHealth, error) {
+	if mock.GetRetailerHealthFunc == nil {
+		panic("VinylDSMock.GetRetailerHealthFunc: method is nil but VinylDS.GetRetailerHealth was just called")
+	}
+	callInfo := struct {
+		Tx         *postgres.Tx
+		RetailerID *int64
+		Window     time.Duration
+		Baseline   time.Duration
+		Now        time.Time
+	}{
+		Tx:         tx,
+		RetailerID: retailerID,
+		Window:     window,
+		Baseline:   baseline,
+		Now:        now,
+	}
+	mock.lockGetRetailerHealth.Lock()
+	mock.calls.GetRetailerHealth = append(mock.calls.GetRetailerHealth, callInfo)
+	mock.lockGetRetailerHealth.Unlock()
+	return mock.GetRetailerHealthFunc(tx, retailerID, window, baseline, now)
+}
+
+// GetRetailerHealthCalls gets all the calls that were made to GetRetailerHealth.
+// Check the length with:
+//     len(mockedVinylDS.GetRetailerHealthCalls())
+func (mock *VinylDSMock) GetRetailerHealthCalls() []struct {
+	Tx         *postgres.Tx
+	RetailerID *int64
+	Window     time.Duration
+	Baseline   time.Duration
+	Now        time.Time
+} {
+	var calls []struct {
+		Tx         *postgres.Tx
+		RetailerID *int64
+		Window     time.Duration
+		Baseline   time.Duration
+		Now        time.Time
+	}
+	mock.lockGetRetailerHealth.RLock()
+	calls = mock.calls.GetRetailerHealth
+	mock.lockGetRetailerHealth.RUnlock()
+	return calls
+}
+
+// GetScanIntervals calls GetScanIntervalsFunc.
+func (mock *VinylDSMock) GetScanIntervals(tx *postgres.Tx, retailerID *int64) ([]ScanInterval, error) {
+	if mock.GetScanIntervalsFunc == nil {
+		panic("VinylDSMock.GetScanIntervalsFunc: method is nil but VinylDS.GetScanIntervals was just called")
+	}
+	callInfo := struct {
+		Tx         *postgres.Tx
+		RetailerID *int64
+	}{
+		Tx:         tx,
+		RetailerID: retailerID,
+	}
+	mock.lockGetScanIntervals.Lock()
+	mock.calls.GetScanIntervals = append(mock.calls.GetScanIntervals, callInfo)
+	mock.lockGetScanIntervals.Unlock()
+	return mock.GetScanIntervalsFunc(tx, retailerID)
+}
+
+// GetScanIntervalsCalls gets all the calls that were made to GetScanIntervals.
+// Check the length with:
+//     len(mockedVinylDS.GetScanIntervalsCalls())
+func (mock *VinylDSMock) GetScanIntervalsCalls() []struct {
+	Tx         *postgres.Tx
+	RetailerID *int64
+} {
+	var calls []struct {
+		Tx         *postgres.Tx
+		RetailerID *int64
+	}
+	mock.lockGetScanIntervals.RLock()
+	calls = mock.calls.GetScanIntervals
+	mock.lockGetScanIntervals.RUnlock()
+	return calls
+}
+
+// GetScansForBatch calls GetScansForBatchFunc.
+func (mock *VinylDSMock) GetScansForBatch(tx *postgres.Tx, batchID int64) ([]Scan, error) {
+	if mock.GetScansForBatchFunc == nil {
+		panic("VinylDSMock.GetScansForBatchFunc: method is nil but VinylDS.GetScansForBatch was just called")
+	}
+	callInfo := struct {
+		Tx      *postgres.Tx
+		BatchID int64
+	}{
+		Tx:      tx,
+		BatchID: batchID,
+	}
+	mock.lockGetScansForBatch.Lock()
+	mock.calls.GetScansForBatch = append(mock.calls.GetScansForBatch, callInfo)
+	mock.lockGetScansForBatch.Unlock()
+	return mock.GetScansForBatchFunc(tx, batchID)
+}
+
+// GetScansForBatchCalls gets all the calls that were made to GetScansForBatch.
+// Check the length with:
+//     len(mockedVinylDS.GetScansForBatchCalls())
+func (mock *VinylDSMock) GetScansForBatchCalls() []struct {
+	Tx      *postgres.Tx
+	BatchID int64
+} {
+	var calls []struct {
+		Tx      *postgres.Tx
+		BatchID int64
+	}
+	mock.lockGetScansForBatch.RLock()
+	calls = mock.calls.GetScansForBatch
+	mock.lockGetScansForBatch.RUnlock()
+	return calls
+}
+
+// GetSkusForReport calls GetSkusForReportFunc.
+func (mock *VinylDSMock) GetSkusForReport(tx *postgres.Tx, reportId int64) ([]ReportSKU, error) {
+	if mock.GetSkusForReportFunc == nil {
+		panic("VinylDSMock.GetSkusForReportFunc: method is nil but VinylDS.GetSkusForReport was just called")
+	}
+	callInfo := struct {
+		Tx       *postgres.Tx
+		ReportId int64
+	}{
+		Tx:       tx,
+		ReportId: reportId,
+	}
+	mock.lockGetSkusForReport.Lock()
+	mock.calls.GetSkusForReport = append(mock.calls.GetSkusForReport, callInfo)
+	mock.lockGetSkusForReport.Unlock()
+	return mock.GetSkusForReportFunc(tx, reportId)
+}
+
+// GetSkusForReportCalls gets all the calls that were made to GetSkusForReport.
+// Check the length with:
+//     len(mockedVinylDS.GetSkusForReportCalls())
+func (mock *VinylDSMock) GetSkusForReportCalls() []struct {
+	Tx       *postgres.Tx
+	ReportId int64
+} {
+	var calls []struct {
+		Tx       *postgres.Tx
+		ReportId int64
+	}
+	mock.lockGetSkusForReport.RLock()
+	calls = mock.calls.GetSkusForReport
+	mock.lockGetSkusForReport.RUnlock()
+	return calls
+}
+
+// GetWatchedArtists calls GetWatchedArtistsFunc.
+func (mock *VinylDSMock) GetWatchedArtists(tx *postgres.Tx) (map[int64][]WatchedArtist, error) {
+	if mock.GetWatchedArtistsFunc == nil {
+		panic("VinylDSMock.GetWatchedArtistsFunc: method is nil but VinylDS.GetWatchedArtists was just called")
+	}
+	callInfo := struct {
+		Tx *postgres.Tx
+	}{
+		Tx: tx,
+	}
+	mock.lockGetWatchedArtists.Lock()
+	mock.calls.GetWatchedArtists = append(mock.calls.GetWatchedArtists, callInfo)
+	mock.lockGetWatchedArtists.Unlock()
+	return mock.GetWatchedArtistsFunc(tx)
+}
+
+// GetWatchedArtistsCalls gets all the calls that were made to GetWatchedArtists.
+// Check the length with:
+//     len(mockedVinylDS.GetWatchedArtistsCalls())
+func (mock *VinylDSMock) GetWatchedArtistsCalls() []struct {
+	Tx *postgres.Tx
+} {
+	var calls []struct {
+		Tx *postgres.Tx
+	}
+	mock.lockGetWatchedArtists.RLock()
+	calls = mock.calls.GetWatchedArtists
+	mock.lockGetWatchedArtists.RUnlock()
+	return calls
+}
+
+// IncrementBatchSearchCompletedCount calls IncrementBatchSearchCompletedCountFunc.
+func (mock *VinylDSMock) IncrementBatchSearchCompletedCount(tx *postgres.Tx, batchId int64) (bool, error) {
+	if mock.IncrementBatchSearchCompletedCountFunc == nil {
+		panic("VinylDSMock.IncrementBatchSearchCompletedCountFunc: method is nil but VinylDS.IncrementBatchSearchCompletedCount was just called")
+	}
+	callInfo := struct {
+		Tx      *postgres.Tx
+		BatchId int64
+	}{
+		Tx:      tx,
+		BatchId: batchId,
+	}
+	mock.lockIncrementBatchSearchCompletedCount.Lock()
+	mock.calls.IncrementBatchSearchCompletedCount = append(mock.calls.IncrementBatchSearchCompletedCount, callInfo)
+	mock.lockIncrementBatchSearchCompletedCount.Unlock()
+	return mock.IncrementBatchSearchCompletedCountFunc(tx, batchId)
+}
+
+// IncrementBatchSearchCompletedCountCalls gets all the calls that were made to IncrementBatchSearchCompletedCount.
+// Check the length with:
+//     len(mockedVinylDS.IncrementBatchSearchCompletedCountCalls())
+func (mock *VinylDSMock) IncrementBatchSearchCompletedCountCalls() []struct {
+	Tx      *postgres.Tx
+	BatchId int64
+} {
+	var calls []struct {
+		Tx      *postgres.Tx
+		BatchId int64
+	}
+	mock.lockIncrementBatchSearchCompletedCount.RLock()
+	calls = mock.calls.IncrementBatchSearchCompletedCount
+	mock.lockIncrementBatchSearchCompletedCount.RUnlock()
+	return calls
+}
+
+// IncrementBatchSearchFailedCount calls IncrementBatchSearchFailedCountFunc.
+func (mock *VinylDSMock) IncrementBatchSearchFailedCount(tx *postgres.Tx, batchId int64) (bool, error) {
+	if mock.IncrementBatchSearchFailedCountFunc == nil {
+		panic("VinylDSMock.IncrementBatchSearchFailedCountFunc: method is nil but VinylDS.IncrementBatchSearchFailedCount was just called")
+	}
+	callInfo := struct {
+		Tx      *postgres.Tx
+		BatchId int64
+	}{
+		Tx:      tx,
+		BatchId: batchId,
+	}
+	mock.lockIncrementBatchSearchFailedCount.Lock()
+	mock.calls.IncrementBatchSearchFailedCount = append(mock.calls.IncrementBatchSearchFailedCount, callInfo)
+	mock.lockIncrementBatchSearchFailedCount.Unlock()
+	return mock.IncrementBatchSearchFailedCountFunc(tx, batchId)
+}
+
+// IncrementBatchSearchFailedCountCalls gets all the calls that were made to IncrementBatchSearchFailedCount.
+// Check the length with:
+//     len(mockedVinylDS.IncrementBatchSearchFailedCountCalls())
+func (mock *VinylDSMock) IncrementBatchSearchFailedCountCalls() []struct {
+	Tx      *postgres.Tx
+	BatchId int64
+} {
+	var calls []struct {
+		Tx      *postgres.Tx
+		BatchId int64
+	}
+	mock.lockIncrementBatchSearchFailedCount.RLock()
+	calls = mock.calls.IncrementBatchSearchFailedCount
+	mock.lockIncrementBatchSearchFailedCount.RUnlock()
+	return calls
+}
+
+// IncrementBatchSearchRetriedCount calls IncrementBatchSearchRetriedCountFunc.
+func (mock *VinylDSMock) IncrementBatchSearchRetriedCount(tx *postgres.Tx, batchId int64) error {
+	if mock.IncrementBatchSearchRetriedCountFunc == nil {
+		panic("VinylDSMock.IncrementBatchSearchRetriedCountFunc: method is nil but VinylDS.IncrementBatchSearchRetriedCount was just called")
+	}
+	callInfo := struct {
+		Tx      *postgres.Tx
+		BatchId int64
+	}{
+		Tx:      tx,
+		BatchId: batchId,
+	}
+	mock.lockIncrementBatchSearchRetriedCount.Lock()
+	mock.calls.IncrementBatchSearchRetriedCount = append(mock.calls.IncrementBatchSearchRetriedCount, callInfo)
+	mock.lockIncrementBatchSearchRetriedCount.Unlock()
+	return mock.IncrementBatchSearchRetriedCountFunc(tx, batchId)
+}
+
+// IncrementBatchSearchRetriedCountCalls gets all the calls that were made to IncrementBatchSearchRetriedCount.
+// Check the length with:
+//     len(mockedVinylDS.IncrementBatchSearchRetriedCountCalls())
+func (mock *VinylDSMock) IncrementBatchSearchRetriedCountCalls() []struct {
+	Tx      *postgres.Tx
+	BatchId int64
+} {
+	var calls []struct {
+		Tx      *postgres.Tx
+		BatchId int64
+	}
+	mock.lockIncrementBatchSearchRetriedCount.RLock()
+	calls = mock.calls.IncrementBatchSearchRetriedCount
+	mock.lockIncrementBatchSearchRetriedCount.RUnlock()
+	return calls
+}
+
+// MarkBatchReported calls MarkBatchReportedFunc.
+func (mock *VinylDSMock) MarkBatchReported(tx *postgres.Tx, batchId int64) error {
+	if mock.MarkBatchReportedFunc == nil {
 		panic("VinylDSMock.MarkBatchReportedFunc: method is nil but VinylDS.MarkBatchReported was just called")
 	}
 	callInfo := struct {
@@ -903,6 +1905,104 @@ func (mock *VinylDSMock) MarkReportSentCalls() []struct {
 	return calls
 }
 
+// MatchRelease calls MatchReleaseFunc.
+func (mock *VinylDSMock) MatchRelease(tx *postgres.Tx, artistID int64, retailerID int64, title string, itemURL string, barcodes []string, catalogueNos []string) (ReleaseMatch, error) {
+	if mock.MatchReleaseFunc == nil {
+		panic("VinylDSMock.MatchReleaseFunc: method is nil but VinylDS.MatchRelease was just called")
+	}
+	callInfo := struct {
+		Tx           *postgres.Tx
+		ArtistID     int64
+		RetailerID   int64
+		Title        string
+		ItemURL      string
+		Barcodes     []string
+		CatalogueNos []string
+	}{
+		Tx:           tx,
+		ArtistID:     artistID,
+		RetailerID:   retailerID,
+		Title:        title,
+		ItemURL:      itemURL,
+		Barcodes:     barcodes,
+		CatalogueNos: catalogueNos,
+	}
+	mock.lockMatchRelease.Lock()
+	mock.calls.MatchRelease = append(mock.calls.MatchRelease, callInfo)
+	mock.lockMatchRelease.Unlock()
+	return mock.MatchReleaseFunc(tx, artistID, retailerID, title, itemURL, barcodes, catalogueNos)
+}
+
+// MatchReleaseCalls gets all the calls that were made to MatchRelease.
+// Check the length with:
+//     len(mockedVinylDS.MatchReleaseCalls())
+func (mock *VinylDSMock) MatchReleaseCalls() []struct {
+	Tx           *postgres.Tx
+	ArtistID     int64
+	RetailerID   int64
+	Title        string
+	ItemURL      string
+	Barcodes     []string
+	CatalogueNos []string
+} {
+	var calls []struct {
+		Tx           *postgres.Tx
+		ArtistID     int64
+		RetailerID   int64
+		Title        string
+		ItemURL      string
+		Barcodes     []string
+		CatalogueNos []string
+	}
+	mock.lockMatchRelease.RLock()
+	calls = mock.calls.MatchRelease
+	mock.lockMatchRelease.RUnlock()
+	return calls
+}
+
+// MoveScheduleNextRun calls MoveScheduleNextRunFunc.
+func (mock *VinylDSMock) MoveScheduleNextRun(tx *postgres.Tx, scheduleID int64, from null.Time, to time.Time) (bool, error) {
+	if mock.MoveScheduleNextRunFunc == nil {
+		panic("VinylDSMock.MoveScheduleNextRunFunc: method is nil but VinylDS.MoveScheduleNextRun was just called")
+	}
+	callInfo := struct {
+		Tx         *postgres.Tx
+		ScheduleID int64
+		From       null.Time
+		To         time.Time
+	}{
+		Tx:         tx,
+		ScheduleID: scheduleID,
+		From:       from,
+		To:         to,
+	}
+	mock.lockMoveScheduleNextRun.Lock()
+	mock.calls.MoveScheduleNextRun = append(mock.calls.MoveScheduleNextRun, callInfo)
+	mock.lockMoveScheduleNextRun.Unlock()
+	return mock.MoveScheduleNextRunFunc(tx, scheduleID, from, to)
+}
+
+// MoveScheduleNextRunCalls gets all the calls that were made to MoveScheduleNextRun.
+// Check the length with:
+//     len(mockedVinylDS.MoveScheduleNextRunCalls())
+func (mock *VinylDSMock) MoveScheduleNextRunCalls() []struct {
+	Tx         *postgres.Tx
+	ScheduleID int64
+	From       null.Time
+	To         time.Time
+} {
+	var calls []struct {
+		Tx         *postgres.Tx
+		ScheduleID int64
+		From       null.Time
+		To         time.Time
+	}
+	mock.lockMoveScheduleNextRun.RLock()
+	calls = mock.calls.MoveScheduleNextRun
+	mock.lockMoveScheduleNextRun.RUnlock()
+	return calls
+}
+
 // Q calls QFunc.
 func (mock *VinylDSMock) Q(tx *postgres.Tx) postgres.Querier {
 	if mock.QFunc == nil {
@@ -934,6 +2034,209 @@ func (mock *VinylDSMock) QCalls() []struct {
 	return calls
 }
 
+// ResolveReleaseReview calls ResolveReleaseReviewFunc.
+func (mock *VinylDSMock) ResolveReleaseReview(tx *postgres.Tx, reviewID int64, accept bool) error {
+	if mock.ResolveReleaseReviewFunc == nil {
+		panic("VinylDSMock.ResolveReleaseReviewFunc: method is nil but VinylDS.ResolveReleaseReview was just called")
+	}
+	callInfo := struct {
+		Tx       *postgres.Tx
+		ReviewID int64
+		Accept   bool
+	}{
+		Tx:       tx,
+		ReviewID: reviewID,
+		Accept:   accept,
+	}
+	mock.lockResolveReleaseReview.Lock()
+	mock.calls.ResolveReleaseReview = append(mock.calls.ResolveReleaseReview, callInfo)
+	mock.lockResolveReleaseReview.Unlock()
+	return mock.ResolveReleaseReviewFunc(tx, reviewID, accept)
+}
+
+// ResolveReleaseReviewCalls gets all the calls that were made to ResolveReleaseReview.
+// Check the length with:
+//     len(mockedVinylDS.ResolveReleaseReviewCalls())
+func (mock *VinylDSMock) ResolveReleaseReviewCalls() []struct {
+	Tx       *postgres.Tx
+	ReviewID int64
+	Accept   bool
+} {
+	var calls []struct {
+		Tx       *postgres.Tx
+		ReviewID int64
+		Accept   bool
+	}
+	mock.lockResolveReleaseReview.RLock()
+	calls = mock.calls.ResolveReleaseReview
+	mock.lockResolveReleaseReview.RUnlock()
+	return calls
+}
+
+// SaveScanIntervals calls SaveScanIntervalsFunc.
+func (mock *VinylDSMock) SaveScanIntervals(tx *postgres.Tx, intervals []ScanInterval) error {
+	if mock.SaveScanIntervalsFunc == nil {
+		panic("VinylDSMock.SaveScanIntervalsFunc: method is nil but VinylDS.SaveScanIntervals was just called")
+	}
+	callInfo := struct {
+		Tx        *postgres.Tx
+		Intervals []ScanInterval
+	}{
+		Tx:        tx,
+		Intervals: intervals,
+	}
+	mock.lockSaveScanIntervals.Lock()
+	mock.calls.SaveScanIntervals = append(mock.calls.SaveScanIntervals, callInfo)
+	mock.lockSaveScanIntervals.Unlock()
+	return mock.SaveScanIntervalsFunc(tx, intervals)
+}
+
+// SaveScanIntervalsCalls gets all the calls that were made to SaveScanIntervals.
+// Check the length with:
+//     len(mockedVinylDS.SaveScanIntervalsCalls())
+func (mock *VinylDSMock) SaveScanIntervalsCalls() []struct {
+	Tx        *postgres.Tx
+	Intervals []ScanInterval
+} {
+	var calls []struct {
+		Tx        *postgres.Tx
+		Intervals []ScanInterval
+	}
+	mock.lockSaveScanIntervals.RLock()
+	calls = mock.calls.SaveScanIntervals
+	mock.lockSaveScanIntervals.RUnlock()
+	return calls
+}
+
+// SetFollowFilter calls SetFollowFilterFunc.
+func (mock *VinylDSMock) SetFollowFilter(tx *postgres.Tx, userID int64, artistID int64, vinylOnly bool, editionFilter string) error {
+	if mock.SetFollowFilterFunc == nil {
+		panic("VinylDSMock.SetFollowFilterFunc: method is nil but VinylDS.SetFollowFilter was just called")
+	}
+	callInfo := struct {
+		Tx            *postgres.Tx
+		UserID        int64
+		ArtistID      int64
+		VinylOnly     bool
+		EditionFilter string
+	}{
+		Tx:            tx,
+		UserID:        userID,
+		ArtistID:      artistID,
+		VinylOnly:     vinylOnly,
+		EditionFilter: editionFilter,
+	}
+	mock.lockSetFollowFilter.Lock()
+	mock.calls.SetFollowFilter = append(mock.calls.SetFollowFilter, callInfo)
+	mock.lockSetFollowFilter.Unlock()
+	return mock.SetFollowFilterFunc(tx, userID, artistID, vinylOnly, editionFilter)
+}
+
+// SetFollowFilterCalls gets all the calls that were made to SetFollowFilter.
+// Check the length with:
+//     len(mockedVinylDS.SetFollowFilterCalls())
+func (mock *VinylDSMock) SetFollowFilterCalls() []struct {
+	Tx            *postgres.Tx
+	UserID        int64
+	ArtistID      int64
+	VinylOnly     bool
+	EditionFilter string
+} {
+	var calls []struct {
+		Tx            *postgres.Tx
+		UserID        int64
+		ArtistID      int64
+		VinylOnly     bool
+		EditionFilter string
+	}
+	mock.lockSetFollowFilter.RLock()
+	calls = mock.calls.SetFollowFilter
+	mock.lockSetFollowFilter.RUnlock()
+	return calls
+}
+
+// SetSKUMissedScans calls SetSKUMissedScansFunc.
+func (mock *VinylDSMock) SetSKUMissedScans(tx *postgres.Tx, skuID int64, missedScans int) error {
+	if mock.SetSKUMissedScansFunc == nil {
+		panic("VinylDSMock.SetSKUMissedScansFunc: method is nil but VinylDS.SetSKUMissedScans was just called")
+	}
+	callInfo := struct {
+		Tx          *postgres.Tx
+		SkuID       int64
+		MissedScans int
+	}{
+		Tx:          tx,
+		SkuID:       skuID,
+		MissedScans: missedScans,
+	}
+	mock.lockSetSKUMissedScans.Lock()
+	mock.calls.SetSKUMissedScans = append(mock.calls.SetSKUMissedScans, callInfo)
+	mock.lockSetSKUMissedScans.Unlock()
+	return mock.SetSKUMissedScansFunc(tx, skuID, missedScans)
+}
+
+// SetSKUMissedScansCalls gets all the calls that were made to SetSKUMissedScans.
+// Check the length with:
+//     len(mockedVinylDS.SetSKUMissedScansCalls())
+func (mock *VinylDSMock) SetSKUMissedScansCalls() []struct {
+	Tx          *postgres.Tx
+	SkuID       int64
+	MissedScans int
+} {
+	var calls []struct {
+		Tx          *postgres.Tx
+		SkuID       int64
+		MissedScans int
+	}
+	mock.lockSetSKUMissedScans.RLock()
+	calls = mock.calls.SetSKUMissedScans
+	mock.lockSetSKUMissedScans.RUnlock()
+	return calls
+}
+
+// SetScheduleLastRun calls SetScheduleLastRunFunc.
+func (mock *VinylDSMock) SetScheduleLastRun(tx *postgres.Tx, scheduleID int64, ranAt time.Time, batchID int64) error {
+	if mock.SetScheduleLastRunFunc == nil {
+		panic("VinylDSMock.SetScheduleLastRunFunc: method is nil but VinylDS.SetScheduleLastRun was just called")
+	}
+	callInfo := struct {
+		Tx         *postgres.Tx
+		ScheduleID int64
+		RanAt      time.Time
+		BatchID    int64
+	}{
+		Tx:         tx,
+		ScheduleID: scheduleID,
+		RanAt:      ranAt,
+		BatchID:    batchID,
+	}
+	mock.lockSetScheduleLastRun.Lock()
+	mock.calls.SetScheduleLastRun = append(mock.calls.SetScheduleLastRun, callInfo)
+	mock.lockSetScheduleLastRun.Unlock()
+	return mock.SetScheduleLastRunFunc(tx, scheduleID, ranAt, batchID)
+}
+
+// SetScheduleLastRunCalls gets all the calls that were made to SetScheduleLastRun.
+// Check the length with:
+//     len(mockedVinylDS.SetScheduleLastRunCalls())
+func (mock *VinylDSMock) SetScheduleLastRunCalls() []struct {
+	Tx         *postgres.Tx
+	ScheduleID int64
+	RanAt      time.Time
+	BatchID    int64
+} {
+	var calls []struct {
+		Tx         *postgres.Tx
+		ScheduleID int64
+		RanAt      time.Time
+		BatchID    int64
+	}
+	mock.lockSetScheduleLastRun.RLock()
+	calls = mock.calls.SetScheduleLastRun
+	mock.lockSetScheduleLastRun.RUnlock()
+	return calls
+}
+
 // StartTransaction calls StartTransactionFunc.
 func (mock *VinylDSMock) StartTransaction() (*postgres.Tx, error) {
 	if mock.StartTransactionFunc == nil {
@@ -1035,7 +2338,7 @@ func (mock *VinylDSMock) UpsertReleaseCalls() []struct {
 }
 
 // UpsertSKU calls UpsertSKUFunc.
-func (mock *VinylDSMock) UpsertSKU(tx *postgres.Tx, sku *SKU) (bool, error) {
+func (mock *VinylDSMock) UpsertSKU(tx *postgres.Tx, sku *SKU) (SKUChange, error) {
 	if mock.UpsertSKUFunc == nil {
 		panic("VinylDSMock.UpsertSKUFunc: method is nil but VinylDS.UpsertSKU was just called")
 	}
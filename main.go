@@ -7,6 +7,7 @@ import (
 	"github.com/gavinturner/vinylretailers/db"
 	"github.com/gavinturner/vinylretailers/util/cfg"
 	"github.com/gavinturner/vinylretailers/util/log"
+	"github.com/gavinturner/vinylretailers/util/queue"
 	"github.com/gavinturner/vinylretailers/util/redis"
 	_ "github.com/lib/pq"
 )
@@ -35,15 +36,11 @@ func main() {
 	defer psqlDB.Close()
 	vinylDS := db.NewDB(psqlDB)
 
-	scanningQueue, err := cmd.InitialiseRedisScanningQueue()
+	scanningQueue, err := cmd.InitialiseScanningQueue(queue.Options{})
 	if err != nil {
 		panic(err)
 	}
 	defer scanningQueue.Close()
-	_, err = scanningQueue.PingRedis()
-	if err != nil {
-		panic(err)
-	}
 
 	// grab the list of retailers
 	retailers, err := vinylDS.GetAllRetailers(nil)
@@ -1,6 +1,7 @@
 package retailers
 
 import (
+	"context"
 	"encoding/csv"
 	"github.com/gavinturner/vinylretailers/util/log"
 	"github.com/pkg/errors"
@@ -13,27 +14,36 @@ import (
 // https://damagedmusic.com.au/?s=clowns+vinyl&post_type=product
 
 const (
+	BD_SCRAPER_KEY  = "beatdiscrecords"
 	BD_URL_PREFIX   = "https://beatdisc.com.au"
 	DATA_DIR        = "./retailers/data"
 	EMPTY_IMAGE_URL = "https://www.freeiconspng.com/thumbs/no-image-icon/no-image-icon-6.png"
 )
 
-type BeatDiscRecords struct{}
+// BeatDiscRecords reads the latest stock list csv supplied by beatdisc from the data dir.
+type BeatDiscRecords struct {
+	httpFetcher
+	DataDir string `json:"dataDir"`
+}
+
+func init() {
+	RegisterScraper(BD_SCRAPER_KEY, configuredScraper(func() VinylRetailer { return &BeatDiscRecords{DataDir: DATA_DIR} }))
+}
 
 func (a *BeatDiscRecords) GetArtistQueryURL(artist string) string {
 	return "n/a"
 }
 
-func (a *BeatDiscRecords) ScrapeArtistReleases(artist string) (findings []SKU, err error) {
+func (a *BeatDiscRecords) ScrapeArtistReleases(ctx context.Context, artist string) (result ScrapeResult, err error) {
 
-	findings = []SKU{}
+	findings := []SKU{}
 	findingsMap := map[string]SKU{}
 
 	// find the latest beatdisc data file
-	files, err := ioutil.ReadDir(DATA_DIR)
+	files, err := ioutil.ReadDir(a.DataDir)
 	if err != nil {
-		log.Error(err, "Failed to list files in dir %s", DATA_DIR)
-		return []SKU{}, errors.Wrapf(err, "failed to list files in dir %s", DATA_DIR)
+		log.Error(err, "Failed to list files in dir %s", a.DataDir)
+		return ScrapeResult{}, errors.Wrapf(err, "failed to list files in dir %s", a.DataDir)
 	}
 
 	dataFiles := []string{}
@@ -43,15 +53,15 @@ func (a *BeatDiscRecords) ScrapeArtistReleases(artist string) (findings []SKU, e
 		}
 	}
 	if len(dataFiles) == 0 {
-		log.Errorf("No beatdisc stock file found in dir %s", DATA_DIR)
-		return []SKU{}, errors.Wrapf(err, "no beatdisc stock file found in dir %s", DATA_DIR)
+		log.Errorf("No beatdisc stock file found in dir %s", a.DataDir)
+		return ScrapeResult{}, errors.Wrapf(err, "no beatdisc stock file found in dir %s", a.DataDir)
 	}
 	sort.Strings(dataFiles)
-	path := DATA_DIR + "/" + dataFiles[len(dataFiles)-1]
+	path := a.DataDir + "/" + dataFiles[len(dataFiles)-1]
 	file, err := os.Open(path)
 	if err != nil {
 		log.Error(err, "Failed to open data file @ %s", path)
-		return []SKU{}, errors.Wrapf(err, "failed to open data file @ %s", path)
+		return ScrapeResult{}, errors.Wrapf(err, "failed to open data file @ %s", path)
 	}
 	defer file.Close()
 
@@ -60,7 +70,7 @@ func (a *BeatDiscRecords) ScrapeArtistReleases(artist string) (findings []SKU, e
 	data, err := csvReader.ReadAll()
 	if err != nil {
 		log.Error(err, "Failed to read csv data file @ %s", path)
-		return []SKU{}, errors.Wrapf(err, "failed to read csv data file @ %s", path)
+		return ScrapeResult{}, errors.Wrapf(err, "failed to read csv data file @ %s", path)
 	}
 	for i, line := range data {
 		if i > 0 { // omit header line
@@ -68,20 +78,20 @@ func (a *BeatDiscRecords) ScrapeArtistReleases(artist string) (findings []SKU, e
 				Url:    "unavailable online",
 				Artist: strings.ToLower(line[0]),
 				Name:   line[1] + " " + line[8] + " [" + line[11] + "]",
-				Price:  line[3],
+				Price:  ParsePrice(line[3]),
 				Image:  EMPTY_IMAGE_URL,
 			}
 			if sku.Artist != strings.ToLower(artist) {
 				continue
 			}
-			sku.Price = sku.Price[2:]
-			sku.Price = "$" + strings.TrimSpace(sku.Price)
-			image, err := FindCoverURL(artist, line[1])
-			if err != nil {
-				log.Error(err, "Failed to get image for release")
-				return []SKU{}, errors.Wrapf(err, "failed to get image for release")
+			image, err := a.findCoverURL(ctx, artist, line[1])
+			if err != nil && ctx.Err() != nil {
+				return ScrapeResult{}, errors.Wrapf(err, "failed to get image for release '%s'", line[1])
 			}
-			if image != "" {
+			if err != nil {
+				// the cover is only cosmetic, don't fail the scan for it
+				log.Warnf("Failed to get image for release '%s': %s", line[1], err.Error())
+			} else if image != "" {
 				sku.Image = image
 			}
 			// make sure we handel dupe titles
@@ -94,5 +104,6 @@ func (a *BeatDiscRecords) ScrapeArtistReleases(artist string) (findings []SKU, e
 		findings = append(findings, sku)
 	}
 
-	return findings, nil
+	// the stock list is a file rather than pages of search results
+	return ScrapeResult{SKUs: findings}, nil
 }
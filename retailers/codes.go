@@ -0,0 +1,63 @@
+package retailers
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	catalogueNumberRegex = regexp.MustCompile(`^[A-Z]{1,8}[0-9]{1,5}(?:[A-Z]{1,4}[0-9]{0,2})?$`)
+)
+
+// NormaliseBarcode returns the EAN-13 for a scanned or listed barcode (EAN-13, or a 12 digit UPC-A which is the
+// same code without the leading 0), or "" if it isn't a valid barcode. Spaces and dashes are ignored.
+func NormaliseBarcode(s string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(s))
+	if len(digits) == 12 {
+		digits = "0" + digits
+	}
+	if len(digits) != 13 {
+		return ""
+	}
+	sum := 0
+	for i, r := range digits {
+		if r < '0' || r > '9' {
+			return ""
+		}
+		d := int(r - '0')
+		if i == 12 {
+			if (10-sum%10)%10 != d {
+				return ""
+			}
+			break
+		}
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return digits
+}
+
+// NormaliseCatalogueNumber returns the label's catalogue number in a form that compares between retailers
+// ("FAT 123-LP" and "fat123lp" are both "FAT123LP"), or "" if it doesn't look like one. Catalogue numbers are a
+// label prefix followed by a number (and sometimes a format suffix). Retailers' own stock codes often go in the same
+// field, so anything else (all digits, barcodes, long codes) is ignored.
+func NormaliseCatalogueNumber(s string) string {
+	code := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '-' || r == '.' || r == '/' || r == '_' {
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, s)
+	if !catalogueNumberRegex.MatchString(code) {
+		return ""
+	}
+	return code
+}
@@ -0,0 +1,49 @@
+//go:build unit_test
+// +build unit_test
+
+package retailers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodes_Barcode(t *testing.T) {
+	t.Parallel()
+
+	for code, expected := range map[string]string{
+		"5060446124536":   "5060446124536",
+		"5 060446 124536": "5060446124536",
+		"751097091715":    "0751097091715", // UPC-A
+		"5060446124537":   "",              // bad check digit
+		"PCR102LP":        "",
+		"":                "",
+	} {
+		assert.Equal(t, expected, NormaliseBarcode(code), "Normalising barcode '%s'", code)
+	}
+}
+
+func TestCodes_CatalogueNumber(t *testing.T) {
+	t.Parallel()
+
+	for code, expected := range map[string]string{
+		"FAT 123-LP":    "FAT123LP",
+		"fat123lp":      "FAT123LP",
+		"PCR102LP":      "PCR102LP",
+		"EPI-86558":     "EPI86558",
+		"POS169393":     "",
+		"5060446124536": "",
+		"":              "",
+	} {
+		assert.Equal(t, expected, NormaliseCatalogueNumber(code), "Normalising catalogue number '%s'", code)
+	}
+
+	sku := SKU{Barcode: "5060446124536", CatalogueNo: "PCR102LP", Variants: Variants{
+		{Title: "Black", Barcode: "5060446124536"},
+		{Title: "Red", Barcode: "5060446124543", CatalogueNo: "PCR102LPR"},
+	}}
+	barcodes, catalogueNos := sku.Codes()
+	assert.Equal(t, []string{"5060446124536", "5060446124543"}, barcodes)
+	assert.Equal(t, []string{"PCR102LP", "PCR102LPR"}, catalogueNos)
+}
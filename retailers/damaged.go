@@ -1,10 +1,8 @@
 package retailers
 
 import (
+	"context"
 	"fmt"
-	"github.com/pkg/errors"
-	"io/ioutil"
-	"net/http"
 	"net/url"
 	"strings"
 )
@@ -12,64 +10,79 @@ import (
 // https://damagedmusic.com.au/?s=clowns+vinyl&post_type=product
 
 const (
-	DR_SEARCH_URL = "https://damagedmusic.com.au/?s=%s+vinyl&post_type=product"
+	DR_SCRAPER_KEY = "damagedrecords"
+	DR_SEARCH_URL  = "https://damagedmusic.com.au/?s=%s+vinyl&post_type=product"
 )
 
-type DamagedRecords struct{}
+type DamagedRecords struct {
+	httpFetcher
+}
+
+func init() {
+	RegisterScraper(DR_SCRAPER_KEY, configuredScraper(func() VinylRetailer { return &DamagedRecords{} }))
+}
 
 func (a *DamagedRecords) GetArtistQueryURL(artist string) string {
 	query := fmt.Sprintf(DR_SEARCH_URL, url.QueryEscape(artist))
 	return query
 }
 
-func (a *DamagedRecords) ScrapeArtistReleases(artist string) (findings []SKU, err error) {
+func (a *DamagedRecords) ScrapeArtistReleases(ctx context.Context, artist string) (result ScrapeResult, err error) {
 
 	// TODO: if damaged has only one result then it goes directly to the product page..
 
-	findings = []SKU{}
-	query := a.GetArtistQueryURL(artist)
-	resp, err := http.Get(query)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to retrieve search query %s", query)
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to extract body of search results %s", query)
-	}
-
-	toks := strings.Split(string(body), ">")
-	for idx, t := range toks {
-		if strings.Index(t, "class=\"product-wrap\"") >= 0 {
-			title := toks[idx+1]
-			title = title[strings.Index(title, "aria-label=")+12:]
-			title = title[0:strings.Index(title, "\"")]
-			name := title[0:strings.Index(title, " - ")]
-			title = title[strings.Index(title, " - ")+3:]
-			image := toks[idx+2]
-			image = image[strings.Index(image, "src=\"")+5:]
-			image = image[0:strings.Index(image, "\"")]
-			url := toks[idx+5]
-			url = url[strings.Index(url, "href=")+6:]
-			url = url[0:strings.Index(url, "\"")]
+	result.SKUs = []SKU{}
+	result.PagesFetched, err = a.getResultPages(ctx, a.GetArtistQueryURL(artist), func(query string, body string) (string, error) {
+		page, err := ParseHTML(DR_SCRAPER_KEY, query, body)
+		if err != nil {
+			return "", err
+		}
 
-			price := toks[idx+15]
-			if end := strings.Index(price, "</bdi"); end > 0 {
-				price = "$" + price[0:end]
+		for _, item := range page.All("li.product") {
+			// the product link is labelled "<artist> - <title>"
+			label, err := item.Attr("title", ".product-wrap a[aria-label]", "aria-label")
+			if err != nil {
+				return "", err
+			}
+			sep := strings.Index(label, " - ")
+			if sep < 0 {
+				continue
 			}
 			sku := SKU{
-				Url:    url,
-				Artist: strings.ToLower(strings.TrimSpace(name)),
-				Name:   strings.TrimSpace(title),
-				Price:  strings.TrimSpace(price),
-				Image:  image,
+				Artist: strings.ToLower(strings.TrimSpace(label[0:sep])),
+				Name:   strings.TrimSpace(label[sep+3:]),
 			}
-
 			// artist name can contain the searched for artist if it's a split etc.
 			if strings.Index(sku.Artist, strings.ToLower(artist)) < 0 {
 				continue
 			}
-			findings = append(findings, sku)
+			sku.Url, err = item.URL("url", ".product-wrap a[aria-label]", "href")
+			if err != nil {
+				return "", err
+			}
+			sku.Image, err = item.URL("image", ".product-wrap img", "src")
+			if err != nil {
+				return "", err
+			}
+			if item.HasClass("outofstock") {
+				sku.Price = SoldOutPrice()
+			} else {
+				// sale prices show the old price struck out, followed by the new one
+				price := item.OptionalText(".price ins .amount")
+				if price == "" {
+					price, err = item.Text("price", ".price .amount")
+					if err != nil {
+						return "", err
+					}
+				}
+				sku.Price = ParsePrice(price)
+			}
+			result.SKUs = append(result.SKUs, sku)
 		}
+		return page.OptionalURL("a.next.page-numbers", "href"), nil
+	})
+	if err != nil {
+		return ScrapeResult{}, err
 	}
-	return findings, nil
+	return result, nil
 }
@@ -0,0 +1,163 @@
+package retailers
+
+import (
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/pkg/errors"
+	"golang.org/x/net/html"
+	"net/url"
+	"strings"
+)
+
+// ParseError is returned when a scraper can't find a field it expects in a retailer's page, which usually means the
+// retailer has changed its markup. It names the retailer and field so the scraper to fix is obvious from the logs.
+type ParseError struct {
+	Retailer string
+	Field    string
+	Selector string
+	Reason   string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: failed to parse %s (%s): %s", e.Retailer, e.Field, e.Selector, e.Reason)
+}
+
+// IsParseError returns true if the cause of the error is a ParseError.
+func IsParseError(err error) bool {
+	_, ok := errors.Cause(err).(*ParseError)
+	return ok
+}
+
+// Node is an element (or the whole document) of a parsed retailer page. Fields are extracted from it with css
+// selectors relative to the node. An empty selector refers to the node itself.
+type Node struct {
+	retailer string
+	base     *url.URL
+	sel      *goquery.Selection
+}
+
+// ParseHTML parses a page returned by the retailer. base is the url of the page, used to resolve relative links.
+// The page is parsed as if scripting were disabled, so the contents of <noscript> (often the only plain <img> of
+// a lazy loaded image) can be selected.
+func ParseHTML(retailer string, base string, body string) (Node, error) {
+	root, err := html.ParseWithOptions(strings.NewReader(body), html.ParseOptionEnableScripting(false))
+	if err != nil {
+		return Node{}, &ParseError{Retailer: retailer, Field: "page", Reason: err.Error()}
+	}
+	doc := goquery.NewDocumentFromNode(root)
+	baseUrl, err := url.Parse(base)
+	if err != nil {
+		return Node{}, &ParseError{Retailer: retailer, Field: "page url", Selector: base, Reason: err.Error()}
+	}
+	return Node{retailer: retailer, base: baseUrl, sel: doc.Selection}, nil
+}
+
+func (n Node) find(selector string) *goquery.Selection {
+	if selector == "" {
+		return n.sel
+	}
+	return n.sel.Find(selector)
+}
+
+// All returns every element matching the selector, in page order.
+func (n Node) All(selector string) []Node {
+	nodes := []Node{}
+	n.find(selector).Each(func(_ int, s *goquery.Selection) {
+		nodes = append(nodes, Node{retailer: n.retailer, base: n.base, sel: s})
+	})
+	return nodes
+}
+
+// Has returns true if any element matches the selector.
+func (n Node) Has(selector string) bool {
+	return n.find(selector).Length() > 0
+}
+
+// Matches returns true if the node itself matches the selector.
+func (n Node) Matches(selector string) bool {
+	return n.sel.Is(selector)
+}
+
+// HasClass returns true if the node itself has the class.
+func (n Node) HasClass(class string) bool {
+	return n.sel.HasClass(class)
+}
+
+// Text returns the whitespace trimmed text of the first element matching the selector, failing if there is none.
+func (n Node) Text(field string, selector string) (string, error) {
+	s := n.find(selector).First()
+	if s.Length() == 0 {
+		return "", n.parseError(field, selector, "not found")
+	}
+	return strings.TrimSpace(s.Text()), nil
+}
+
+// OwnText returns the trimmed text directly inside the first element matching the selector, ignoring the text of
+// any child elements (labels like "From" or a struck out original price), failing if there is none.
+func (n Node) OwnText(field string, selector string) (string, error) {
+	s := n.find(selector).First()
+	if s.Length() == 0 {
+		return "", n.parseError(field, selector, "not found")
+	}
+	text := ""
+	for c := s.Nodes[0].FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			text += c.Data
+		}
+	}
+	return strings.TrimSpace(text), nil
+}
+
+// OptionalText returns the trimmed text of the first element matching the selector, or "" if there is none.
+func (n Node) OptionalText(selector string) string {
+	return strings.TrimSpace(n.find(selector).First().Text())
+}
+
+// Attr returns an attribute of the first element matching the selector, failing if either is missing.
+func (n Node) Attr(field string, selector string, attr string) (string, error) {
+	s := n.find(selector).First()
+	if s.Length() == 0 {
+		return "", n.parseError(field, selector, "not found")
+	}
+	value, ok := s.Attr(attr)
+	if !ok {
+		return "", n.parseError(field, selector, fmt.Sprintf("no %s attribute", attr))
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// OptionalAttr returns an attribute of the first element matching the selector, or "" if either is missing.
+func (n Node) OptionalAttr(selector string, attr string) string {
+	value, _ := n.find(selector).First().Attr(attr)
+	return strings.TrimSpace(value)
+}
+
+// URL returns a link attribute (href, src..) of the first element matching the selector, resolved against the page.
+func (n Node) URL(field string, selector string, attr string) (string, error) {
+	value, err := n.Attr(field, selector, attr)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(value)
+	if err != nil {
+		return "", n.parseError(field, selector, err.Error())
+	}
+	return n.base.ResolveReference(ref).String(), nil
+}
+
+// OptionalURL returns a link attribute of the first element matching the selector, resolved against the page, or
+// "" if either is missing (e.g. the next page link on the last page).
+func (n Node) OptionalURL(selector string, attr string) string {
+	if n.OptionalAttr(selector, attr) == "" {
+		return ""
+	}
+	value, err := n.URL("", selector, attr)
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
+func (n Node) parseError(field string, selector string, reason string) error {
+	return &ParseError{Retailer: n.retailer, Field: field, Selector: selector, Reason: reason}
+}
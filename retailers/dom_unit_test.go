@@ -0,0 +1,89 @@
+//go:build unit_test
+// +build unit_test
+
+package retailers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const domTestPage = `<html><body>
+<ul>
+  <li class="product sold">
+    <a href="/products/lucid-again?pos=1" title="Clowns - Lucid Again">Lucid Again</a>
+    <noscript><img src="//cdn.test/lucid.jpg"></noscript>
+    <span class="price"><span class="from">From</span> $40.00 <s>$45.00</s></span>
+  </li>
+  <li class="product">
+    <a href="https://store.test/products/nature-nurture">Nature/Nurture</a>
+  </li>
+</ul>
+</body></html>`
+
+func TestDOM_Extract(t *testing.T) {
+	t.Parallel()
+
+	page, err := ParseHTML("teststore", "https://store.test/search?q=clowns", domTestPage)
+	require.Nil(t, err)
+	items := page.All("li.product")
+	require.Equal(t, 2, len(items))
+
+	item := items[0]
+	assert.True(t, item.HasClass("sold"))
+	assert.True(t, item.Has(".price .from"))
+	title, err := item.Attr("title", "a", "title")
+	require.Nil(t, err)
+	assert.Equal(t, "Clowns - Lucid Again", title)
+	link, err := item.URL("url", "a", "href")
+	require.Nil(t, err)
+	assert.Equal(t, "https://store.test/products/lucid-again?pos=1", link)
+	image, err := item.URL("image", "noscript img", "src")
+	require.Nil(t, err, "Expected <noscript> to be parsed as markup")
+	assert.Equal(t, "https://cdn.test/lucid.jpg", image)
+	price, err := item.OwnText("price", ".price")
+	require.Nil(t, err)
+	assert.Equal(t, "$40.00", price)
+
+	link, err = items[1].URL("url", "a", "href")
+	require.Nil(t, err)
+	assert.Equal(t, "https://store.test/products/nature-nurture", link)
+	assert.Equal(t, "", items[1].OptionalText(".price"))
+}
+
+func TestDOM_ParseErrors(t *testing.T) {
+	t.Parallel()
+
+	page, err := ParseHTML("teststore", "https://store.test/search?q=clowns", domTestPage)
+	require.Nil(t, err)
+	item := page.All("li.product")[1]
+
+	_, err = item.Text("price", ".price")
+	require.NotNil(t, err)
+	assert.True(t, IsParseError(err))
+	assert.Equal(t, "teststore: failed to parse price (.price): not found", err.Error())
+
+	_, err = item.Attr("title", "a", "title")
+	assert.True(t, IsParseError(err))
+	assert.Contains(t, err.Error(), "no title attribute")
+}
+
+type panickingScraper struct{}
+
+func (p panickingScraper) GetArtistQueryURL(artist string) string { return "" }
+
+func (p panickingScraper) ScrapeArtistReleases(ctx context.Context, artist string) (ScrapeResult, error) {
+	toks := []string{}
+	return ScrapeResult{SKUs: []SKU{{Name: toks[1]}}}, nil
+}
+
+func TestDOM_ScrapeArtistRecovers(t *testing.T) {
+	t.Parallel()
+
+	_, err := ScrapeArtist(context.Background(), panickingScraper{}, "teststore", "clowns")
+	require.NotNil(t, err)
+	assert.True(t, IsParseError(err), "Expected a panic to be returned as a parse error")
+}
@@ -0,0 +1,381 @@
+package retailers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gavinturner/vinylretailers/util/log"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_FETCH_TIMEOUT_SECS     = 30
+	DEFAULT_FETCH_MAX_RETRIES      = 3
+	DEFAULT_FETCH_BACKOFF_MILLIS   = 500
+	DEFAULT_FETCH_MAX_BACKOFF_SECS = 60
+	DEFAULT_FETCH_INTERVAL_MILLIS  = 1000
+	DEFAULT_FETCH_MAX_CONCURRENT   = 2
+	DEFAULT_FETCH_MAX_PAGES        = 5
+	DEFAULT_FETCH_USER_AGENT       = "vinylretailers/1.0 (+https://github.com/gavinturner/vinylretailers)"
+)
+
+var (
+	// ErrDisallowedByRobots is returned (wrapped) when the retailer's robots.txt asks us not to fetch a page.
+	ErrDisallowedByRobots = errors.New("disallowed by robots.txt")
+	// ErrPageNotFound is returned (wrapped) when the retailer answers 404 for a page.
+	ErrPageNotFound = errors.New("page not found")
+)
+
+// RobotsPolicy is whether a retailer's robots.txt is obeyed. Each retailer's scraper config states its policy
+// ("fetch": {"robots": "obey"}), as ignoring it is a decision about that store rather than a default.
+type RobotsPolicy string
+
+const (
+	RobotsPolicy_Obey   RobotsPolicy = "obey"   // pages it disallows fail the scan, and its crawl delay is kept to
+	RobotsPolicy_Ignore RobotsPolicy = "ignore" // only for retailers that have agreed to be scanned
+)
+
+// FetchConfig controls how scrapers fetch pages. The defaults are set from config at startup (SetDefaultFetchConfig)
+// and can be overridden per retailer with a "fetch" object in the retailer's scraper config. Rate limits and
+// concurrency caps are applied per host, across every scraper in the process.
+type FetchConfig struct {
+	TimeoutSecs           int          `json:"timeoutSecs"`           // per request, including reading the body
+	MaxRetries            int          `json:"maxRetries"`            // retries after a network error, 429 or 5xx
+	BackoffMillis         int          `json:"backoffMillis"`         // wait before the first retry, doubled for each retry after
+	MaxBackoffSecs        int          `json:"maxBackoffSecs"`        // longest we'll wait to retry. a longer Retry-After gives up
+	RequestIntervalMillis int          `json:"requestIntervalMillis"` // minimum time between requests to the host
+	MaxConcurrent         int          `json:"maxConcurrent"`         // most requests in flight to the host (0 is unlimited)
+	MaxPages              int          `json:"maxPages"`              // most pages of search results read for an artist
+	UserAgent             string       `json:"userAgent"`
+	Robots                RobotsPolicy `json:"robots"` // obeyed if a retailer doesn't say
+}
+
+var (
+	defaultFetchConfig = FetchConfig{
+		TimeoutSecs:           DEFAULT_FETCH_TIMEOUT_SECS,
+		MaxRetries:            DEFAULT_FETCH_MAX_RETRIES,
+		BackoffMillis:         DEFAULT_FETCH_BACKOFF_MILLIS,
+		MaxBackoffSecs:        DEFAULT_FETCH_MAX_BACKOFF_SECS,
+		RequestIntervalMillis: DEFAULT_FETCH_INTERVAL_MILLIS,
+		MaxConcurrent:         DEFAULT_FETCH_MAX_CONCURRENT,
+		MaxPages:              DEFAULT_FETCH_MAX_PAGES,
+		UserAgent:             DEFAULT_FETCH_USER_AGENT,
+	}
+	defaultFetchConfigMutex = sync.RWMutex{}
+)
+
+// SetDefaultFetchConfig sets the fetch config used by scrapers created from now on, unless their retailer
+// config overrides it.
+func SetDefaultFetchConfig(config FetchConfig) {
+	defaultFetchConfigMutex.Lock()
+	defer defaultFetchConfigMutex.Unlock()
+	defaultFetchConfig = config
+}
+
+// DefaultFetchConfig returns the fetch config that scrapers are created with.
+func DefaultFetchConfig() FetchConfig {
+	defaultFetchConfigMutex.RLock()
+	defer defaultFetchConfigMutex.RUnlock()
+	return defaultFetchConfig
+}
+
+// HTTPScraper is implemented by scrapers that fetch pages over http. The transport can be replaced so that
+// scrapers can be run against recorded pages rather than the live sites.
+type HTTPScraper interface {
+	VinylRetailer
+	SetTransport(transport http.RoundTripper)
+}
+
+// httpFetcher is embedded in each scraper to make its http requests. A nil transport uses the default transport.
+type httpFetcher struct {
+	Transport http.RoundTripper `json:"-"`
+	Fetch     FetchConfig       `json:"fetch"`
+}
+
+func (f *httpFetcher) SetTransport(transport http.RoundTripper) {
+	f.Transport = transport
+}
+
+func (f *httpFetcher) setFetchConfig(config FetchConfig) {
+	f.Fetch = config
+}
+
+func (f *httpFetcher) fetchConfig() FetchConfig {
+	return f.Fetch
+}
+
+// configureScraper sets the default fetch config on the scraper and then applies the retailer's json config over
+// the top, so that a retailer only needs to configure what differs from the defaults.
+func configureScraper(scraper VinylRetailer, config json.RawMessage) error {
+	fetcher, ok := scraper.(interface {
+		setFetchConfig(FetchConfig)
+		fetchConfig() FetchConfig
+	})
+	if ok {
+		fetcher.setFetchConfig(DefaultFetchConfig())
+	}
+	if len(config) == 0 {
+		return nil
+	}
+	err := json.Unmarshal(config, scraper)
+	if err != nil {
+		return err
+	}
+	if ok {
+		switch fetcher.fetchConfig().Robots {
+		case "", RobotsPolicy_Obey, RobotsPolicy_Ignore:
+		default:
+			return fmt.Errorf("fetch.robots must be '%s' or '%s'", RobotsPolicy_Obey, RobotsPolicy_Ignore)
+		}
+	}
+	return nil
+}
+
+// ConfiguredRobotsPolicy returns the robots policy a retailer's scraper config states, or "" if it doesn't.
+func ConfiguredRobotsPolicy(config json.RawMessage) RobotsPolicy {
+	stated := struct {
+		Fetch struct {
+			Robots RobotsPolicy `json:"robots"`
+		} `json:"fetch"`
+	}{}
+	if len(config) > 0 {
+		_ = json.Unmarshal(config, &stated)
+	}
+	return stated.Fetch.Robots
+}
+
+func (f *httpFetcher) client() *http.Client {
+	return &http.Client{
+		Transport: f.Transport,
+		Timeout:   time.Duration(f.Fetch.TimeoutSecs) * time.Second,
+	}
+}
+
+// getPage returns the body of the page. Requests are throttled per host and checked against the host's robots.txt.
+// Network errors, 429s and 5xxs are retried with exponential backoff (or after Retry-After if the host asks for
+// longer). Any other response than a 200 fails, a 404 with ErrPageNotFound. The request, and any wait for the
+// throttle or to retry, ends when ctx is done.
+func (f *httpFetcher) getPage(ctx context.Context, query string) (string, error) {
+	u, err := url.Parse(query)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid url %s", query)
+	}
+	robots := &robotsRules{}
+	if f.Fetch.Robots != RobotsPolicy_Ignore {
+		robots = f.getRobots(ctx, u)
+		if !robots.allowed(u) {
+			return "", errors.Wrapf(ErrDisallowedByRobots, "can't fetch %s", query)
+		}
+	}
+
+	maxBackoff := time.Duration(f.Fetch.MaxBackoffSecs) * time.Second
+	for attempt := 0; ; attempt++ {
+		status, body, retryAfter, err := f.fetch(ctx, u, robots.crawlDelay)
+		if err == nil && status == http.StatusOK {
+			return body, nil
+		}
+		if err == nil && status == http.StatusNotFound {
+			return "", errors.Wrapf(ErrPageNotFound, "failed to retrieve %s", query)
+		}
+		if err == nil {
+			err = fmt.Errorf("unexpected status %v from %s", status, query)
+		}
+		if ctx.Err() != nil {
+			// out of time for the scan rather than a problem with the host
+			return "", errors.Wrapf(ctx.Err(), "failed to retrieve %s", query)
+		}
+		retryable := status == 0 || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+		if !retryable {
+			return "", err
+		}
+		if attempt >= f.Fetch.MaxRetries {
+			return "", errors.Wrapf(err, "giving up after %v attempts", attempt+1)
+		}
+		if retryAfter > maxBackoff {
+			return "", errors.Wrapf(err, "giving up as asked to retry after %v", retryAfter)
+		}
+		wait := time.Duration(f.Fetch.BackoffMillis) * time.Millisecond << uint(attempt)
+		if wait > maxBackoff {
+			wait = maxBackoff
+		}
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		log.Debugf("Retrying %s in %v: %s", query, wait, err.Error())
+		err = sleepContext(ctx, wait)
+		if err != nil {
+			return "", errors.Wrapf(err, "gave up waiting to retry %s", query)
+		}
+	}
+}
+
+// IsDisallowedByRobots returns true if the cause of the error is that the retailer's robots.txt disallows the page.
+// Retrying won't help until the retailer's robots policy is changed.
+func IsDisallowedByRobots(err error) bool {
+	return errors.Cause(err) == ErrDisallowedByRobots
+}
+
+// IsPageNotFound returns true if the cause of the error is that the retailer answered 404 for the page.
+func IsPageNotFound(err error) bool {
+	return errors.Cause(err) == ErrPageNotFound
+}
+
+// getResultPages reads the pages of search results starting from query. parse is called with each page and returns
+// the url of the next page, or "" if it was the last. At most Fetch.MaxPages are read, and the number read is
+// returned. A page that can't be fetched fails the search, as results missing from a later page would otherwise
+// look like releases that have gone.
+func (f *httpFetcher) getResultPages(ctx context.Context, query string, parse func(query string, body string) (next string, err error)) (pages int, err error) {
+	maxPages := f.Fetch.MaxPages
+	if maxPages < 1 {
+		maxPages = 1
+	}
+	read := map[string]struct{}{}
+	for query != "" {
+		if _, ok := read[query]; ok {
+			break
+		}
+		if pages >= maxPages {
+			log.Debugf("Not reading past page %v of search results (%s)", pages, query)
+			break
+		}
+		read[query] = struct{}{}
+		body, err := f.getPage(ctx, query)
+		if err != nil {
+			return pages, errors.Wrapf(err, "failed to retrieve search query %s", query)
+		}
+		pages++
+		query, err = parse(query, body)
+		if err != nil {
+			return pages, err
+		}
+	}
+	return pages, nil
+}
+
+// fetch makes a single request once the host's throttle allows, returning the status (0 for a network error), the
+// body and any Retry-After the host responded with.
+func (f *httpFetcher) fetch(ctx context.Context, u *url.URL, minInterval time.Duration) (status int, body string, retryAfter time.Duration, err error) {
+	interval := time.Duration(f.Fetch.RequestIntervalMillis) * time.Millisecond
+	if minInterval > interval {
+		interval = minInterval
+	}
+	release, err := hostThrottleFor(u.Host).acquire(ctx, interval, f.Fetch.MaxConcurrent)
+	if err != nil {
+		return 0, "", 0, errors.Wrapf(err, "gave up waiting to request %s", u)
+	}
+	defer release()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, "", 0, errors.Wrapf(err, "failed to create request for %s", u)
+	}
+	if f.Fetch.UserAgent != "" {
+		req.Header.Set("User-Agent", f.Fetch.UserAgent)
+	}
+	resp, err := f.client().Do(req)
+	if err != nil {
+		return 0, "", 0, errors.Wrapf(err, "failed to retrieve %s", u)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, "", 0, errors.Wrapf(err, "failed to extract body of %s", u)
+	}
+	return resp.StatusCode, string(data), parseRetryAfter(resp.Header.Get("Retry-After")), nil
+}
+
+// parseRetryAfter reads a Retry-After header, which is either a number of seconds or an http date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
+
+// hostThrottle spaces out requests to a host and caps how many are in flight at once. The interval and cap are
+// passed on each request so that they follow the config of the scraper making it. Scrapers for a host are
+// configured alike, so requests made with the same cap share a semaphore.
+type hostThrottle struct {
+	mutex sync.Mutex
+	slots map[int]chan struct{} // semaphore for each cap requests have been made with
+	next  time.Time
+}
+
+var (
+	hostThrottles      = map[string]*hostThrottle{}
+	hostThrottlesMutex = sync.Mutex{}
+)
+
+func hostThrottleFor(host string) *hostThrottle {
+	hostThrottlesMutex.Lock()
+	defer hostThrottlesMutex.Unlock()
+	throttle, ok := hostThrottles[host]
+	if !ok {
+		throttle = &hostThrottle{slots: map[int]chan struct{}{}}
+		hostThrottles[host] = throttle
+	}
+	return throttle
+}
+
+// acquire waits for a turn to make a request to the host, failing if ctx is done first. The caller must call
+// release once the request has been made.
+func (h *hostThrottle) acquire(ctx context.Context, interval time.Duration, maxConcurrent int) (release func(), err error) {
+	release = func() {}
+	if maxConcurrent > 0 {
+		h.mutex.Lock()
+		slots, ok := h.slots[maxConcurrent]
+		if !ok {
+			slots = make(chan struct{}, maxConcurrent)
+			h.slots[maxConcurrent] = slots
+		}
+		h.mutex.Unlock()
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		release = func() { <-slots }
+	}
+
+	h.mutex.Lock()
+	now := time.Now()
+	start := h.next
+	if start.Before(now) {
+		start = now
+	}
+	h.next = start.Add(interval)
+	h.mutex.Unlock()
+	err = sleepContext(ctx, time.Until(start))
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// sleepContext waits for d, returning ctx's error if it is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
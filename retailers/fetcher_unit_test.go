@@ -0,0 +1,236 @@
+//go:build unit_test
+// +build unit_test
+
+package retailers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFetcher() *httpFetcher {
+	return &httpFetcher{Fetch: FetchConfig{
+		TimeoutSecs:    5,
+		MaxRetries:     2,
+		BackoffMillis:  1,
+		MaxBackoffSecs: 1,
+		UserAgent:      "vinyltest/1.0",
+	}}
+}
+
+func TestFetcher_Retries(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		assert.Equal(t, "vinyltest/1.0", r.Header.Get("User-Agent"))
+		switch atomic.AddInt32(&requests, 1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	body, err := testFetcher().getPage(context.Background(), server.URL+"/search?q=clowns")
+	require.Nil(t, err, "Expected request to succeed after retries")
+	assert.Equal(t, "ok", body)
+	assert.Equal(t, int32(3), requests)
+}
+
+func TestFetcher_NoRetry(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// asks for longer than we are prepared to wait
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	_, err := testFetcher().getPage(context.Background(), server.URL+"/products/gone")
+	assert.NotNil(t, err, "Expected error for a 404")
+	_, err = testFetcher().getPage(context.Background(), server.URL+"/products/busy")
+	assert.NotNil(t, err, "Expected error for a long Retry-After")
+	assert.Equal(t, int32(2), requests, "Expected neither request to be retried")
+}
+
+func TestFetcher_ResultPages(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("page " + r.URL.Query().Get("page")))
+	}))
+	defer server.Close()
+
+	f := testFetcher()
+	f.Fetch.Robots = RobotsPolicy_Ignore
+	f.Fetch.MaxPages = 3
+	bodies := []string{}
+	pages, err := f.getResultPages(context.Background(), server.URL+"/search?page=1", func(query string, body string) (string, error) {
+		bodies = append(bodies, body)
+		return fmt.Sprintf("%s/search?page=%v", server.URL, len(bodies)+1), nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 3, pages, "Expected paging to stop at the max pages")
+	assert.Equal(t, []string{"page 1", "page 2", "page 3"}, bodies)
+
+	pages, err = f.getResultPages(context.Background(), server.URL+"/search?page=1", func(query string, body string) (string, error) {
+		return query, nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 1, pages, "Expected a next page link back to the same page to stop paging")
+}
+
+func TestFetcher_Robots(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nDisallow: /search\n"))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	_, err := testFetcher().getPage(context.Background(), server.URL+"/search?q=clowns")
+	assert.Equal(t, ErrDisallowedByRobots, errors.Cause(err))
+
+	f := testFetcher()
+	f.Fetch.Robots = RobotsPolicy_Ignore
+	_, err = f.getPage(context.Background(), server.URL+"/search?q=clowns")
+	assert.Nil(t, err, "Expected robots.txt to be ignored")
+}
+
+func TestFetcher_Deadline(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	f := testFetcher()
+	f.Fetch.Robots = RobotsPolicy_Ignore
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := f.getPage(ctx, server.URL+"/search?q=clowns")
+	require.NotNil(t, err)
+	assert.True(t, IsDeadlineExceeded(err), "Expected the deadline to be the cause: %v", err)
+	assert.True(t, time.Since(start) < time.Second, "Expected the request to end at the deadline")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "Expected no retries once out of time")
+
+	// waiting for the host's throttle ends at the deadline too
+	throttle := hostThrottleFor("deadline.test")
+	release, err := throttle.acquire(context.Background(), time.Hour, 0)
+	require.Nil(t, err)
+	release()
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = throttle.acquire(ctx, time.Hour, 0)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// as does waiting for a request to the host to finish
+	throttle = hostThrottleFor("busy.test")
+	release, err = throttle.acquire(context.Background(), 0, 1)
+	require.Nil(t, err)
+	defer release()
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = throttle.acquire(ctx, 0, 1)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestFetcher_ParseRobots(t *testing.T) {
+	t.Parallel()
+
+	robots := parseRobots(`
+# comment
+User-agent: *
+Disallow: /
+
+User-agent: Googlebot
+User-agent: vinylretailers
+Disallow: /checkout
+Disallow: /*?*sort_by=
+Allow: /checkout/public$
+Crawl-delay: 2
+`, DEFAULT_FETCH_USER_AGENT)
+	allowed := func(path string) bool {
+		u, err := url.Parse("https://store.com" + path)
+		require.Nil(t, err)
+		return robots.allowed(u)
+	}
+	assert.True(t, allowed("/search?q=clowns"))
+	assert.False(t, allowed("/checkout/cart"))
+	assert.True(t, allowed("/checkout/public"))
+	assert.False(t, allowed("/collections/all?page=2&sort_by=price"))
+	assert.Equal(t, 2*time.Second, robots.crawlDelay)
+
+	robots = parseRobots("User-agent: *\nDisallow: /\n", "otherbot/2.0")
+	assert.False(t, allowed("/search?q=clowns"), "Expected the * group to apply to other agents")
+
+	// every agent line of a group is read before its rules, whichever order they're listed in
+	robots = parseRobots(`
+User-agent: Googlebot
+User-agent: *
+Disallow: /search
+`, DEFAULT_FETCH_USER_AGENT)
+	assert.False(t, allowed("/search?q=clowns"), "Expected a group listing * after another agent to apply to us")
+	assert.True(t, allowed("/collections/all"))
+
+	robots = parseRobots(`
+User-agent: *
+User-agent: vinylretailers
+Disallow: /search
+
+User-agent: *
+Disallow: /collections
+`, DEFAULT_FETCH_USER_AGENT)
+	assert.False(t, allowed("/search?q=clowns"), "Expected a group naming us after * to be ours")
+	assert.True(t, allowed("/collections/all"), "Expected * groups not to apply once a group names us")
+}
+
+func TestFetcher_Throttle(t *testing.T) {
+	t.Parallel()
+
+	throttle := hostThrottleFor("throttle.test")
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		release, err := throttle.acquire(context.Background(), 20*time.Millisecond, 1)
+		require.Nil(t, err)
+		release()
+	}
+	assert.True(t, time.Since(start) >= 40*time.Millisecond, "Expected requests to be spaced by the interval")
+}
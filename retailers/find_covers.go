@@ -1,15 +1,19 @@
 package retailers
 
 import (
+	"context"
 	"fmt"
 	"github.com/pkg/errors"
-	"io/ioutil"
-	"net/http"
 	"net/url"
 	"strings"
 )
 
-func FindCoverURL(artist string, title string) (string, error) {
+func FindCoverURL(ctx context.Context, artist string, title string) (string, error) {
+	f := httpFetcher{Fetch: DefaultFetchConfig()}
+	return f.findCoverURL(ctx, artist, title)
+}
+
+func (f *httpFetcher) findCoverURL(ctx context.Context, artist string, title string) (string, error) {
 	// use discogs
 	//
 
@@ -21,23 +25,14 @@ func FindCoverURL(artist string, title string) (string, error) {
 	qT := url.QueryEscape(title)
 	query := fmt.Sprintf("https://www.discogs.com/search/?q=%s+%s+vinyl&type=all", qA, qT)
 
-	resp, err := http.Get(query)
+	body, err := f.getPage(ctx, query)
 	if err != nil {
 		return "", errors.Wrapf(err, "failed to retrieve search query %s", query)
 	}
-	body, err := ioutil.ReadAll(resp.Body)
+	page, err := ParseHTML("discogs", query, body)
 	if err != nil {
-		return "", errors.Wrapf(err, "failed to extract body of search results %s", query)
-	}
-	image := ""
-	toks := strings.Split(string(body), ">")
-	for idx, t := range toks {
-		if strings.Index(t, "thumbnail_center") >= 0 {
-			image = toks[idx+1]
-			image = image[strings.Index(image, "data-src=\"")+10:]
-			image = image[0:strings.Index(image, "\"")]
-			break
-		}
+		return "", err
 	}
-	return image, nil
+	// no results is no image, not an error
+	return page.OptionalAttr(".thumbnail_center img", "data-src"), nil
 }
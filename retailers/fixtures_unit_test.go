@@ -0,0 +1,211 @@
+//go:build unit_test
+// +build unit_test
+
+package retailers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Each scraper is run against pages recorded from the retailer and the skus it produces are compared with the
+// golden file recorded alongside them in testdata/<scraper key>/. When a retailer changes its markup, fix the
+// scraper and refresh its fixtures from the live site with:
+//
+//	go test -tags unit_test ./retailers/ -run TestScraperFixtures/<scraper key> -record
+//
+// or regenerate only the golden files from the pages already recorded with -update.
+//
+// A retailer's robots.txt is recorded with its pages. Fixtures that replay the pages of a retailer whose robots.txt
+// disallows the scraper set its robots policy to ignore, and a disallowed fixture checks the scraper obeys it.
+//
+// Golden files must hold the skus the scraper found, so a retailer is only given a fixture once the product pages
+// its search links to are recorded too. Oh Jean Records has only its search page recorded, which the shopify tests
+// read, so it has no fixture here yet.
+//
+// Selector scraper definitions (definitions/<name>.json) are run against the pages recorded for the retailer of
+// the same name, which is scanned with the definition put on its row by a migration.
+var (
+	recordFixtures = flag.Bool("record", false, "fetch fixture pages from the live retailer sites and rewrite golden files")
+	updateGoldens  = flag.Bool("update", false, "rewrite golden files from the recorded fixture pages")
+)
+
+var scraperFixtures = []struct {
+	key        string
+	config     string
+	definition string
+	artist     string
+	disallowed bool // the recorded robots.txt disallows the scraper, so it must fail rather than find anything
+}{
+	{key: AF_SCRAPER_KEY, artist: "nofx"},
+	{key: BD_SCRAPER_KEY, config: `{"dataDir": "./testdata/beatdiscrecords"}`, artist: "pixies"},
+	{key: DR_SCRAPER_KEY, artist: "clowns"},
+	{key: OFFW_SCRAPER_KEY, artist: "pennywise"},
+	{key: RR_SCRAPER_KEY, artist: "architects"},
+	{key: "dutchvinyl", artist: "pixies"},
+	{key: "grevillerecords", artist: "frank black"},
+	{key: "musicfarmers", artist: "clowns"},
+	{key: "poisoncity", config: `{"fetch": {"robots": "ignore"}}`, artist: "clowns"},
+	{key: "poisoncity", config: `{"fetch": {"robots": "obey"}}`, artist: "clowns", disallowed: true},
+	{key: "utopia", artist: "nofx"},
+	{key: SELECTOR_SCRAPER_KEY, definition: "clarityrecords", artist: "nofx"},
+	{key: SELECTOR_SCRAPER_KEY, definition: "repressedrecords", artist: "clowns"},
+	{key: SELECTOR_SCRAPER_KEY, definition: "strangeworldrecords", artist: "ramones"},
+}
+
+func TestScraperFixtures(t *testing.T) {
+	// replaying recorded pages needn't be polite, but recording them from the live sites must be. only the first
+	// page of each search is recorded, paging is covered by the scrapers' own tests
+	defaultFetch := DefaultFetchConfig()
+	defer SetDefaultFetchConfig(defaultFetch)
+	fixtureFetch := defaultFetch
+	fixtureFetch.MaxPages = 1
+	if !*recordFixtures {
+		fixtureFetch.RequestIntervalMillis, fixtureFetch.MaxRetries = 0, 0
+	}
+	SetDefaultFetchConfig(fixtureFetch)
+
+	for _, fixture := range scraperFixtures {
+		fixture := fixture
+		name := fixture.key
+		if fixture.definition != "" {
+			name += "/" + fixture.definition
+		}
+		if fixture.disallowed {
+			name += "/disallowed"
+		}
+		t.Run(name+"/"+fixture.artist, func(t *testing.T) {
+			dir := filepath.Join("testdata", fixture.key)
+			config := fixture.config
+			if fixture.definition != "" {
+				dir = filepath.Join("testdata", fixture.definition)
+				data, err := ioutil.ReadFile(filepath.Join("definitions", fixture.definition+".json"))
+				require.Nil(t, err, "Failed to read selector scraper definition")
+				config = string(data)
+			}
+			record, update := *recordFixtures, *updateGoldens
+			scraper, err := NewVinylRetailer(fixture.key, json.RawMessage(config))
+			require.Nil(t, err, "Failed to create scraper")
+			httpScraper, ok := scraper.(HTTPScraper)
+			require.True(t, ok, "Expected scraper to accept a transport")
+			httpScraper.SetTransport(&fixtureTransport{t: t, dir: dir, record: record})
+
+			result, err := scraper.ScrapeArtistReleases(context.Background(), fixture.artist)
+			if fixture.disallowed {
+				assert.True(t, IsDisallowedByRobots(err), "Expected the recorded robots.txt to be obeyed: %v", err)
+				return
+			}
+			require.Nil(t, err, "Failed to scrape recorded pages")
+			skus := result.SKUs
+			sort.Slice(skus, func(i, j int) bool {
+				if skus[i].Url != skus[j].Url {
+					return skus[i].Url < skus[j].Url
+				}
+				return skus[i].Name < skus[j].Name
+			})
+
+			golden := filepath.Join(dir, fixtureFileName(fixture.artist)+".golden.json")
+			if record || update {
+				data, err := json.MarshalIndent(skus, "", "  ")
+				require.Nil(t, err, "Failed to marshal skus")
+				err = os.MkdirAll(dir, 0755)
+				require.Nil(t, err, "Failed to create fixture dir")
+				err = ioutil.WriteFile(golden, append(data, '\n'), 0644)
+				require.Nil(t, err, "Failed to write golden file")
+				return
+			}
+			data, err := ioutil.ReadFile(golden)
+			require.Nil(t, err, "Failed to read golden file (record it with -record or -update)")
+			expected := []SKU{}
+			err = json.Unmarshal(data, &expected)
+			require.Nil(t, err, "Failed to parse golden file")
+			require.NotEmpty(t, expected, "Golden file holds no skus (record the pages the scraper reads with -record)")
+			assert.Equal(t, expected, skus)
+		})
+	}
+}
+
+// fixtureTransport serves pages recorded in dir, answering 404 for any page that hasn't been recorded. In record
+// mode requests go to the live site and successful responses are saved for replay.
+type fixtureTransport struct {
+	t      *testing.T
+	dir    string
+	record bool
+}
+
+func (f *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := filepath.Join(f.dir, fixtureFileName(req.URL.Host+req.URL.RequestURI())+".html")
+	if f.record {
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			err = os.MkdirAll(f.dir, 0755)
+			if err == nil {
+				err = ioutil.WriteFile(path, body, 0644)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		f.t.Logf("No fixture recorded for %s", req.URL)
+		return fixtureResponse(req, http.StatusNotFound, nil), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fixtureResponse(req, http.StatusOK, body), nil
+}
+
+func fixtureResponse(req *http.Request, status int, body []byte) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{"text/html; charset=utf-8"}},
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}
+}
+
+var fixtureNameRegex = regexp.MustCompile(`[^a-zA-Z0-9.]+`)
+
+// fixtureFileName makes a readable file name from a url or artist, shortening long names with a hash.
+func fixtureFileName(s string) string {
+	s, _ = url.QueryUnescape(s)
+	name := strings.Trim(fixtureNameRegex.ReplaceAllString(strings.ToLower(s), "_"), "_.")
+	if len(name) > 100 {
+		name = fmt.Sprintf("%s_%x", name[:90], sha1.Sum([]byte(s)))[:100]
+	}
+	return name
+}
@@ -0,0 +1,200 @@
+package retailers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Format is the physical format of a listing. Stored as text against each sku.
+type Format string
+
+const (
+	Format_Unknown  Format = ""
+	Format_LP       Format = "lp"
+	Format_2LP      Format = "2lp"
+	Format_3LP      Format = "3lp"
+	Format_7In      Format = "7in"
+	Format_10In     Format = "10in"
+	Format_12In     Format = "12in"
+	Format_Vinyl    Format = "vinyl" // vinyl of a size we can't tell
+	Format_CD       Format = "cd"
+	Format_Cassette Format = "cassette"
+)
+
+// IsVinyl returns true if the format is a record. An unknown format might be, as most retailers are searched for
+// vinyl only and only some say which format a listing is.
+func (f Format) IsVinyl() bool {
+	return f != Format_CD && f != Format_Cassette
+}
+
+var (
+	// formats in the order they're looked for in a listing, most specific first
+	formatRegexes = []struct {
+		format Format
+		regex  *regexp.Regexp
+	}{
+		{Format_3LP, regexp.MustCompile(`(?i)\b(3\s*x?\s*lps?|triple\s+(lp|vinyl))\b`)},
+		{Format_2LP, regexp.MustCompile(`(?i)\b(2\s*x?\s*lps?|dlp|double\s+(lp|vinyl))\b`)},
+		{Format_7In, regexp.MustCompile(`(?i)\b7\s*(?:"|”|''|-?\s*inch\b|in\b)`)},
+		{Format_10In, regexp.MustCompile(`(?i)\b10\s*(?:"|”|''|-?\s*inch\b|in\b)`)},
+		{Format_12In, regexp.MustCompile(`(?i)\b12\s*(?:"|”|''|-?\s*inch\b|in\b)`)},
+		{Format_LP, regexp.MustCompile(`(?i)\blps?\b`)},
+		{Format_Vinyl, regexp.MustCompile(`(?i)\b(vinyl|record)\b`)},
+		{Format_CD, regexp.MustCompile(`(?i)\b(cd|compact disc)\b`)},
+		{Format_Cassette, regexp.MustCompile(`(?i)\b(cassette|tape|mc)\b`)},
+	}
+	editionBracketRegex = regexp.MustCompile(`[(\[{]([^)\]}]*)[)\]}]`)
+	editionVinylRegex   = regexp.MustCompile(`(?i)((?:[\p{L}\d/&]+\s+){1,4})vinyl\b`)
+	testPressingRegex   = regexp.MustCompile(`(?i)\btest\s+press(ing)?\b`)
+
+	// words that describe the pressing of a release rather than the release itself
+	editionWords = map[string]struct{}{
+		"edition": {}, "limited": {}, "ltd": {}, "reissue": {}, "repress": {}, "remaster": {}, "remastered": {},
+		"deluxe": {}, "gatefold": {}, "180g": {}, "180gm": {}, "gram": {}, "colour": {}, "coloured": {}, "color": {},
+		"colored": {}, "variant": {}, "pressing": {}, "press": {}, "splatter": {}, "marbled": {}, "marble": {},
+		"swirl": {}, "test": {}, "numbered": {}, "signed": {}, "picture": {}, "disc": {}, "clear": {},
+		"transparent": {}, "translucent": {}, "opaque": {}, "smoke": {}, "galaxy": {}, "black": {}, "white": {},
+		"red": {}, "blue": {}, "green": {}, "yellow": {}, "pink": {}, "purple": {}, "orange": {}, "gold": {},
+		"silver": {}, "grey": {}, "gray": {}, "brown": {}, "bone": {}, "cream": {}, "tan": {},
+	}
+)
+
+// ParseFormat reads the format of a listing from text that describes it (a title, variant name, product type..),
+// or Format_Unknown if the text doesn't say.
+func ParseFormat(s string) Format {
+	s = html.UnescapeString(s)
+	for _, f := range formatRegexes {
+		if f.regex.MatchString(s) {
+			return f.format
+		}
+	}
+	return Format_Unknown
+}
+
+// ParseEdition reads the colour or edition of a pressing from a listing title e.g. "Limited Pink Vinyl" from
+// "Everything Sucks (Limited Pink Vinyl)" or "Clowns - Bad Blood LP - Red Vinyl", or "" if the title doesn't say.
+func ParseEdition(title string) string {
+	title = html.UnescapeString(title)
+	editions := []string{}
+	for _, match := range editionBracketRegex.FindAllStringSubmatch(title, -1) {
+		if isEdition(match[1]) {
+			editions = append(editions, strings.TrimSpace(match[1]))
+		}
+	}
+	if len(editions) == 0 {
+		for _, match := range editionVinylRegex.FindAllStringSubmatch(title, -1) {
+			// only the edition words directly before "vinyl"
+			words := strings.Fields(match[1])
+			start := len(words)
+			for start > 0 && isEditionWord(words[start-1]) {
+				start--
+			}
+			for start < len(words) && isEditionJoin(words[start]) {
+				start++
+			}
+			if start < len(words) {
+				editions = append(editions, strings.Join(words[start:], " ")+" Vinyl")
+			}
+		}
+	}
+	if len(editions) == 0 {
+		if match := testPressingRegex.FindString(title); match != "" {
+			editions = append(editions, match)
+		}
+	}
+	return strings.Join(editions, ", ")
+}
+
+func isEdition(s string) bool {
+	if testPressingRegex.MatchString(s) {
+		return true
+	}
+	for _, word := range titleWords(strings.ToLower(s)) {
+		if _, ok := editionWords[word]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isEditionWord returns true for a word of an edition, including the joins in "black and white" or "red/blue".
+func isEditionWord(word string) bool {
+	_, ok := editionWords[strings.ToLower(strings.Trim(word, "/&-,"))]
+	return ok || isEditionJoin(word)
+}
+
+func isEditionJoin(word string) bool {
+	word = strings.ToLower(strings.Trim(word, "/&-,"))
+	return word == "" || word == "and" || word == "with"
+}
+
+// Variant is one purchasable version of a listing (a colour, or LP and CD) where the retailer sells several under
+// the one product.
+type Variant struct {
+	Title       string `json:"title"`
+	Format      Format `json:"format"`
+	Edition     string `json:"edition"`
+	Price       Price  `json:"price"`
+	Barcode     string `json:"barcode,omitempty"`
+	CatalogueNo string `json:"catalogueNumber,omitempty"`
+}
+
+// Variants are stored as json against each sku.
+type Variants []Variant
+
+// Equal returns true if both have the same variants, at the same prices and availability.
+func (v Variants) Equal(o Variants) bool {
+	if len(v) != len(o) {
+		return false
+	}
+	for i := range v {
+		if v[i].Title != o[i].Title || !v[i].Price.Equal(o[i].Price) {
+			return false
+		}
+	}
+	return true
+}
+
+// Formats returns the distinct formats of the variants, or just the listing's format if it has none.
+func (v Variants) Formats(listing Format) []Format {
+	if len(v) == 0 {
+		return []Format{listing}
+	}
+	formats := []Format{}
+	seen := map[Format]struct{}{}
+	for _, variant := range v {
+		format := variant.Format
+		if format == Format_Unknown {
+			format = listing
+		}
+		if _, ok := seen[format]; !ok {
+			seen[format] = struct{}{}
+			formats = append(formats, format)
+		}
+	}
+	return formats
+}
+
+func (v Variants) Value() (driver.Value, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+func (v *Variants) Scan(src interface{}) error {
+	switch data := src.(type) {
+	case nil:
+		*v = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(data, v)
+	case string:
+		return json.Unmarshal([]byte(data), v)
+	}
+	return fmt.Errorf("can't scan %T into variants", src)
+}
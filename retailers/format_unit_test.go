@@ -0,0 +1,71 @@
+//go:build unit_test
+// +build unit_test
+
+package retailers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat_Parse(t *testing.T) {
+	t.Parallel()
+
+	for text, expected := range map[string]Format{
+		"Everything Sucks LP":                Format_LP,
+		"Everything Sucks 2xLP":              Format_2LP,
+		"Bad Blood (Double Vinyl)":           Format_2LP,
+		"Split 7\"":                          Format_7In,
+		"Never Trust A Hippy 10 inch":        Format_10In,
+		"Lucid Again (Limited Pink Vinyl)":   Format_Vinyl,
+		"Nature/Nurture CD":                  Format_CD,
+		"Demos Cassette":                     Format_Cassette,
+		"Bad Blood":                          Format_Unknown,
+		"Vinyl LP / CD":                      Format_LP,
+		"Yummy! 12&quot; (180 gram reissue)": Format_12In,
+	} {
+		assert.Equal(t, expected, ParseFormat(text), "Parsing the format of '%s'", text)
+	}
+	assert.True(t, Format_Unknown.IsVinyl(), "Expected an unknown format to be kept by a vinyl only filter")
+	assert.False(t, Format_Cassette.IsVinyl())
+}
+
+func TestFormat_Edition(t *testing.T) {
+	t.Parallel()
+
+	for title, expected := range map[string]string{
+		"Everything Sucks (Limited Pink Vinyl)":  "Limited Pink Vinyl",
+		"Clowns - Bad Blood LP - Red Vinyl":      "Red Vinyl",
+		"Lucid Again Black and White Vinyl LP":   "Black and White Vinyl",
+		"Nature/Nurture Test Pressing":           "Test Pressing",
+		"Everything Sucks (Vinyl - New)":         "",
+		"Live At The Tote (Live)":                "",
+		"Bad Blood Vinyl":                        "",
+		"Yummy! [Gatefold] (Coloured Reissue)":   "Gatefold, Coloured Reissue",
+		"Split 7\" (w/ Pist Idiots) Clear Vinyl": "Clear Vinyl",
+	} {
+		assert.Equal(t, expected, ParseEdition(title), "Parsing the edition of '%s'", title)
+	}
+}
+
+func TestFormat_Variants(t *testing.T) {
+	t.Parallel()
+
+	variants := Variants{
+		{Title: "Black", Format: Format_Unknown, Price: NewPrice(3500)},
+		{Title: "Red / CD", Format: Format_CD, Price: SoldOutPrice()},
+	}
+	assert.Equal(t, []Format{Format_LP, Format_CD}, variants.Formats(Format_LP))
+	assert.Equal(t, []Format{Format_LP}, Variants(nil).Formats(Format_LP))
+
+	changed := Variants{variants[0], {Title: "Red / CD", Format: Format_CD, Price: NewPrice(2000)}}
+	assert.True(t, variants.Equal(Variants{variants[0], variants[1]}))
+	assert.False(t, variants.Equal(changed), "Expected a variant restock to be a change")
+
+	value, err := variants.Value()
+	assert.Nil(t, err)
+	scanned := Variants{}
+	assert.Nil(t, scanned.Scan(value))
+	assert.True(t, variants.Equal(scanned))
+}
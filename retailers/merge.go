@@ -0,0 +1,72 @@
+package retailers
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// MergeResults merges the results of searching a retailer for an artist under each of its names (e.g. "hard-ons"
+// and "hardons") into a single list. A listing found by more than one search is only kept once, from the first
+// result it appears in, so the artist's main name should be passed first. Listings are the same if they link to
+// the same product, or if they were found by different searches and have the same title once case, punctuation
+// and spacing are ignored. A store can list different products under one title (pressings, editions), so within
+// a search only the link tells them apart.
+func MergeResults(results ...ScrapeResult) ScrapeResult {
+	merged := ScrapeResult{SKUs: []SKU{}}
+	urls := map[string]struct{}{}
+	titles := map[string]struct{}{} // of the searches merged before the current one
+	for _, result := range results {
+		merged.PagesFetched += result.PagesFetched
+		merged.ProductsSkipped += result.ProductsSkipped
+		resultTitles := []string{}
+		for _, sku := range result.SKUs {
+			urlKey := ProductURLKey(sku.Url)
+			titleKey := titleKey(sku.Name)
+			if _, ok := urls[urlKey]; ok && urlKey != "" {
+				continue
+			}
+			if _, ok := titles[titleKey]; ok && titleKey != "" {
+				continue
+			}
+			urls[urlKey] = struct{}{}
+			resultTitles = append(resultTitles, titleKey)
+			merged.SKUs = append(merged.SKUs, sku)
+		}
+		for _, t := range resultTitles {
+			titles[t] = struct{}{}
+		}
+	}
+	return merged
+}
+
+// ProductURLKey identifies the product a url links to, ignoring the scheme, a trailing slash and the tracking
+// parameters (_pos, _sid..) stores add to links from their search results. Urls that aren't links (beatdisc's
+// "unavailable online") have no key.
+func ProductURLKey(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	query := u.Query()
+	for param := range query {
+		if strings.HasPrefix(param, "_") {
+			query.Del(param)
+		}
+	}
+	key := strings.ToLower(u.Host) + strings.TrimSuffix(u.Path, "/")
+	if len(query) > 0 {
+		key += "?" + query.Encode()
+	}
+	return key
+}
+
+// titleKey is the title with only its letters and digits, lower cased.
+func titleKey(title string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, title)
+}
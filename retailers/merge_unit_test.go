@@ -0,0 +1,35 @@
+//go:build unit_test
+// +build unit_test
+
+package retailers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge_Results(t *testing.T) {
+	t.Parallel()
+
+	main := ScrapeResult{PagesFetched: 2, SKUs: []SKU{
+		{Name: "Yummy!", Url: "https://store.test/products/yummy?_pos=1&_sid=abc", SearchTerm: "hard-ons"},
+		{Name: "Dickcheese", Url: "https://store.test/products/dickcheese", SearchTerm: "hard-ons"},
+		{Name: "Dickcheese", Url: "https://store.test/products/dickcheese-repress", SearchTerm: "hard-ons"},
+		{Name: "Dickcheese", Url: "https://store.test/products/dickcheese?_pos=2", SearchTerm: "hard-ons"},
+	}}
+	variant := ScrapeResult{PagesFetched: 1, SKUs: []SKU{
+		{Name: "Yummy", Url: "https://store.test/products/yummy-1", SearchTerm: "hardons"},
+		{Name: "Dickcheese LP", Url: "http://store.test/products/dickcheese/?_pos=3", SearchTerm: "hardons"},
+		{Name: "Love Is A Battlefield Of Wounded Hearts", Url: "https://store.test/products/love", SearchTerm: "hardons"},
+	}}
+	merged := MergeResults(main, variant)
+	assert.Equal(t, 3, merged.PagesFetched)
+	assert.Equal(t, []SKU{main.SKUs[0], main.SKUs[1], main.SKUs[2], variant.SKUs[2]}, merged.SKUs,
+		"Expected listings found by both searches (same title or same product) to be kept from the first, and "+
+			"products a search found under the same title to be kept")
+
+	unlinked := []SKU{{Name: "Yummy", Url: "unavailable online"}, {Name: "Dickcheese", Url: "unavailable online"}}
+	merged = MergeResults(ScrapeResult{SKUs: unlinked})
+	assert.Equal(t, unlinked, merged.SKUs, "Expected listings without links to be told apart by title")
+}
@@ -1,122 +1,124 @@
 package retailers
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"github.com/pkg/errors"
-	"io/ioutil"
-	"net/http"
+	"github.com/gavinturner/vinylretailers/util/log"
 	"net/url"
 	"strings"
 )
 
 const (
-	OFFW_URL_PREFIX = "https://offwhiterecords.com/"
-	OFFW_SEARCH_URL = "https://offwhiterecords.com/store/search_results.cfm?search_term=%s&search_sub=SEARCH"
+	OFFW_SCRAPER_KEY = "offwhiterecords"
+	OFFW_URL_PREFIX  = "https://offwhiterecords.com/store/"
+	OFFW_SEARCH_URL  = "https://offwhiterecords.com/store/search_results.cfm?search_term=%s&search_sub=SEARCH"
 )
 
-type OffWhiteRecords struct{}
+type OffWhiteRecords struct {
+	httpFetcher
+}
+
+func init() {
+	RegisterScraper(OFFW_SCRAPER_KEY, configuredScraper(func() VinylRetailer { return &OffWhiteRecords{} }))
+}
 
 func (a *OffWhiteRecords) GetArtistQueryURL(artist string) string {
 	query := fmt.Sprintf(OFFW_SEARCH_URL, url.QueryEscape(artist))
 	return query
 }
 
-func (a *OffWhiteRecords) ScrapeArtistReleases(artist string) ([]SKU, error) {
-
-	findings := []SKU{}
-	query := a.GetArtistQueryURL(artist)
-	client := &http.Client{}
-	resp, err := client.Get(query)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to retrieve search query %s", query)
-	}
+func (a *OffWhiteRecords) ScrapeArtistReleases(ctx context.Context, artist string) (result ScrapeResult, err error) {
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to extract body of search results %s", query)
-	}
-	toks := strings.Split(string(body), ">")
-
-	for idx, t := range toks {
-		if strings.Index(t, "<tr class=\"altrow\"") >= 0 {
-			sku := SKU{}
-			subUrl := strings.TrimPrefix(strings.TrimSpace(toks[idx+7]), "<a href=\"")
-			subUrl = PC_URL_PREFIX + strings.TrimSuffix(subUrl, "\"")
+	result.SKUs = []SKU{}
+	result.PagesFetched, err = a.getResultPages(ctx, a.GetArtistQueryURL(artist), func(query string, body string) (string, error) {
+		page, err := ParseHTML(OFFW_SCRAPER_KEY, query, body)
+		if err != nil {
+			return "", err
+		}
 
-			// now read THAT url...
-			query := fmt.Sprintf(subUrl)
-			resp, err := http.Get(query)
+		// results are a table of artist, title, format and label. the details are on the title's catalogue page
+		for _, row := range page.All("tbody tr") {
+			if !row.Has("td[id^=tit] a") {
+				continue
+			}
+			if !strings.EqualFold(row.OptionalText("td[id^=art]"), artist) {
+				continue
+			}
+			subUrl, err := row.URL("url", "td[id^=tit] a", "href")
 			if err != nil {
-				fmt.Printf("[FAILED: %s]..", err.Error())
-				return nil, errors.Wrapf(err, "failed to open sub url %s", subUrl)
+				return "", err
+			}
+			product, err := a.getPage(ctx, subUrl)
+			if IsPageNotFound(err) {
+				// a product that has gone since the search results were rendered shouldn't fail the scan
+				log.Warnf("Skipping off white product %s: %s", subUrl, err.Error())
+				result.ProductsSkipped++
+				continue
 			}
-			body, err := ioutil.ReadAll(resp.Body)
 			if err != nil {
-				fmt.Printf("[FAILED: %s]..", err.Error())
-				return nil, errors.Wrapf(err, "failed to open body of sub url %s", subUrl)
+				return "", err
 			}
-
-			subToks := strings.Split(string(body), ">")
-			for _, tok := range subToks {
-
-				// search for the json data
-				START_TOKEN := "new Shopify.OptionSelectors(\"product-select\", { product:"
-				END_TOKEN := ", onVariantSelected"
-
-				if strings.Index(tok, START_TOKEN) >= 0 {
-					jd := tok[strings.Index(tok, START_TOKEN)+len(START_TOKEN):]
-					jd = jd[0:strings.Index(jd, END_TOKEN)]
-					data := PoisonCityInfo{}
-					err := json.Unmarshal([]byte(jd), &data)
-					if err != nil {
-						fmt.Printf("[FAILED: %s]..", err.Error())
-						return nil, errors.Wrapf(err, "failed to parse json data")
-					}
-
-					sku.Name = data.Title
-					sku.Artist = artist
-					sku.Image = "https:" + data.FeaturedImage
-					sku.Price = fmt.Sprintf("$%.2f", float32(data.Price)/100.0)
-					if !data.Available {
-						sku.Price = SOLD_OUT
-					}
-					break
-				}
-
-				/*
-					if strings.Index(tok, "itemprop=\"image\"") > 0 {
-						sku.Image = "https:" + strings.TrimPrefix(strings.TrimSpace(tok), "<meta itemprop=\"image\" content=\"")
-						sku.Image = strings.TrimSuffix(sku.Image, "\" /")
-					}
-					if strings.Index(tok, "itemprop=\"name\"") >= 0 {
-						sku.Name = strings.TrimSpace(subToks[idx+1])
-						sku.Name = strings.TrimSuffix(sku.Name, "</h1")
-					}
-					if strings.Index(tok, "itemprop=\"price\"") >= 0 {
-						sku.Price = strings.TrimSpace(subToks[idx+1])
-						sku.Price = strings.TrimSuffix(sku.Price, "</span")
-					}
-					if strings.Index(tok, "\"Sold Out\"") >= 0 {
-						sku.Price = SOLD_OUT
-					}*/
+			sku, ok, err := a.parseProductPage(subUrl, product, artist)
+			if err != nil {
+				return "", err
 			}
-
-			if !strings.HasPrefix(strings.ToLower(sku.Name), strings.ToLower(artist)) {
+			if !ok {
 				continue
 			}
-			sku.Url = subUrl
+			result.SKUs = append(result.SKUs, sku)
+		}
+		// the search lists every result on a single page
+		return "", nil
+	})
+	if err != nil {
+		return ScrapeResult{}, err
+	}
+	return result, nil
+}
 
-			if strings.Index(strings.ToLower(sku.Name), strings.ToLower(artist+" ")) == 0 {
-				sku.Name = sku.Name[len(artist)+1:]
-			}
-			if (sku.Name[0] == '\'' && sku.Name[len(sku.Name)-1] == '\'') ||
-				(sku.Name[0] == '"' && sku.Name[len(sku.Name)-1] == '"') {
-				sku.Name = sku.Name[1 : len(sku.Name)-1]
-			}
-			// store new price
-			findings = append(findings, sku)
+// parseProductPage reads the product from its catalogue page, which lists the artist and title separately along with
+// the label's catalogue number and barcode. Returns false if the product is by another artist.
+func (a *OffWhiteRecords) parseProductPage(productUrl string, body string, artist string) (SKU, bool, error) {
+	page, err := ParseHTML(OFFW_SCRAPER_KEY, productUrl, body)
+	if err != nil {
+		return SKU{}, false, err
+	}
+	productArtist, err := page.Text("artist", ".item-details .artist")
+	if err != nil {
+		return SKU{}, false, err
+	}
+	if !strings.EqualFold(productArtist, artist) {
+		return SKU{}, false, nil
+	}
+	title, err := page.Text("title", ".item-details .title")
+	if err != nil {
+		return SKU{}, false, err
+	}
+	price, err := page.Text("price", ".item-details .buylink .price")
+	if err != nil {
+		return SKU{}, false, err
+	}
+	sku := SKU{
+		Name:   title,
+		Artist: artist,
+		Url:    productUrl,
+		Image:  page.OptionalURL(".item-details #prodimage", "src"),
+		Price:  ParsePrice(price),
+	}
+	if !page.Has(".item-details .buylink .in-stock") {
+		sku.Price.Availability = Availability_SoldOut
+	}
+	for _, row := range page.All(".item-details .labelcatbarcode tr") {
+		cells := row.All("td")
+		if len(cells) != 2 {
+			continue
+		}
+		switch strings.ToLower(cells[0].OptionalText("")) {
+		case "cat no:":
+			sku.CatalogueNo = NormaliseCatalogueNumber(cells[1].OptionalText(""))
+		case "barcode:":
+			sku.Barcode = NormaliseBarcode(cells[1].OptionalText(""))
 		}
 	}
-	return findings, nil
+	return sku, true, nil
 }
@@ -0,0 +1,156 @@
+package retailers
+
+import (
+	"fmt"
+	"gopkg.in/guregu/null.v3"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	DEFAULT_CURRENCY    = "AUD"
+	LOW_STOCK_THRESHOLD = 3 // listings with this many or fewer left are low stock
+)
+
+// Availability is whether (and how) a listing can currently be bought. Stored as text against each sku.
+type Availability string
+
+const (
+	Availability_InStock   Availability = "in_stock"
+	Availability_LowStock  Availability = "low_stock"
+	Availability_SoldOut   Availability = "sold_out"
+	Availability_PreOrder  Availability = "pre_order"
+	Availability_Backorder Availability = "backorder"
+	Availability_Delisted  Availability = "delisted" // no longer listed by the retailer (inferred by the scanner)
+)
+
+// Price is the price and availability of a listing. The amount is in minor units (cents) so that prices can be
+// compared and aggregated exactly, and is null where the retailer does not show a price (typically sold out).
+// Stock is the quantity left where the retailer exposes it.
+type Price struct {
+	Amount       null.Int     `db:"amount" json:"amount"`
+	Currency     string       `db:"currency" json:"currency"`
+	Availability Availability `db:"availability" json:"availability"`
+	Stock        null.Int     `db:"stock" json:"stock"`
+}
+
+var (
+	priceAmountRegex   = regexp.MustCompile(`\d[\d,]*(\.\d{1,2})?`)
+	priceCurrencyRegex = regexp.MustCompile(`\b(AUD|NZD|USD|GBP|EUR)\b`)
+	preOrderRegex      = regexp.MustCompile(`(?i)\bpre[- ]?orders?\b`)
+)
+
+// NewPrice creates an available price in the default currency from an amount in cents.
+func NewPrice(cents int64) Price {
+	return Price{
+		Amount:       null.IntFrom(cents),
+		Currency:     DEFAULT_CURRENCY,
+		Availability: Availability_InStock,
+	}
+}
+
+// SoldOutPrice is the price of a listing that can't be bought and shows no price.
+func SoldOutPrice() Price {
+	return Price{
+		Currency:     DEFAULT_CURRENCY,
+		Availability: Availability_SoldOut,
+	}
+}
+
+// DelistedPrice is the price of a listing the retailer has stopped listing.
+func DelistedPrice() Price {
+	return Price{
+		Currency:     DEFAULT_CURRENCY,
+		Availability: Availability_Delisted,
+	}
+}
+
+// IsPreOrder returns true if the text (a title, tag or price label) marks the listing as a pre-order.
+func IsPreOrder(s string) bool {
+	return preOrderRegex.MatchString(s)
+}
+
+// ParsePrice reads a price as scraped from a retailer page e.g. "$44.00", "From $30", "AUD 1,024.50",
+// "Pre-order $30" or "sold out". If no amount can be found the amount is left null.
+func ParsePrice(s string) Price {
+	s = strings.TrimSpace(html.UnescapeString(s))
+	lower := strings.ToLower(s)
+	p := Price{
+		Currency:     DEFAULT_CURRENCY,
+		Availability: Availability_InStock,
+	}
+	if strings.Index(lower, SOLD_OUT) >= 0 || strings.Index(lower, "sold-out") >= 0 {
+		p.Availability = Availability_SoldOut
+	} else if IsPreOrder(s) {
+		p.Availability = Availability_PreOrder
+	}
+	if currency := priceCurrencyRegex.FindString(strings.ToUpper(s)); currency != "" {
+		p.Currency = currency
+	}
+	amount := priceAmountRegex.FindString(s)
+	if amount == "" {
+		return p
+	}
+	amount = strings.Replace(amount, ",", "", -1)
+	value, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return p
+	}
+	p.Amount = null.IntFrom(int64(value*100 + 0.5))
+	return p
+}
+
+// WithStock sets the quantity left, marking an in stock listing as low stock when there are only a few left.
+func (p Price) WithStock(quantity int64) Price {
+	p.Stock = null.IntFrom(quantity)
+	if p.Availability == Availability_InStock && quantity > 0 && quantity <= LOW_STOCK_THRESHOLD {
+		p.Availability = Availability_LowStock
+	}
+	return p
+}
+
+// Equal returns true if both prices have the same amount, currency and availability. The stock quantity is
+// deliberately ignored so that each sale doesn't register as a new listing state.
+func (p Price) Equal(o Price) bool {
+	return p.Amount.Valid == o.Amount.Valid &&
+		(!p.Amount.Valid || p.Amount.Int64 == o.Amount.Int64) &&
+		p.Currency == o.Currency &&
+		p.Availability == o.Availability
+}
+
+// IsAvailable returns true if the listing can be bought now or ordered.
+func (p Price) IsAvailable() bool {
+	return p.Availability != Availability_SoldOut && p.Availability != Availability_Delisted
+}
+
+// String formats the price for display, e.g. "$44.00", "sold out", "no longer listed", "$44.00 (pre-order)" or "$44.00 (only 2 left)".
+func (p Price) String() string {
+	if p.Availability == Availability_SoldOut {
+		return SOLD_OUT
+	}
+	if p.Availability == Availability_Delisted {
+		return "no longer listed"
+	}
+	amount := "price unknown"
+	if p.Amount.Valid {
+		amount = fmt.Sprintf("$%d.%02d", p.Amount.Int64/100, p.Amount.Int64%100)
+		if p.Currency != DEFAULT_CURRENCY {
+			amount = p.Currency + " " + amount
+		}
+	}
+	switch p.Availability {
+	case Availability_LowStock:
+		if p.Stock.Valid {
+			amount += fmt.Sprintf(" (only %d left)", p.Stock.Int64)
+		} else {
+			amount += " (low stock)"
+		}
+	case Availability_PreOrder:
+		amount += " (pre-order)"
+	case Availability_Backorder:
+		amount += " (backorder)"
+	}
+	return amount
+}
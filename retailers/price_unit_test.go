@@ -0,0 +1,45 @@
+//go:build unit_test
+// +build unit_test
+
+package retailers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestPrice_Parse(t *testing.T) {
+	t.Parallel()
+
+	for raw, expected := range map[string]Price{
+		"$44.00":          {Amount: null.IntFrom(4400), Currency: "AUD", Availability: Availability_InStock},
+		" $ 10.00 ":       {Amount: null.IntFrom(1000), Currency: "AUD", Availability: Availability_InStock},
+		"&#36;40.00":      {Amount: null.IntFrom(4000), Currency: "AUD", Availability: Availability_InStock},
+		"From $30":        {Amount: null.IntFrom(3000), Currency: "AUD", Availability: Availability_InStock},
+		"USD 1,024.50":    {Amount: null.IntFrom(102450), Currency: "USD", Availability: Availability_InStock},
+		"$19.99":          {Amount: null.IntFrom(1999), Currency: "AUD", Availability: Availability_InStock},
+		"Sold Out":        {Currency: "AUD", Availability: Availability_SoldOut},
+		"$35.00 sold out": {Amount: null.IntFrom(3500), Currency: "AUD", Availability: Availability_SoldOut},
+		"Pre-Order $30":   {Amount: null.IntFrom(3000), Currency: "AUD", Availability: Availability_PreOrder},
+	} {
+		p := ParsePrice(raw)
+		assert.True(t, expected.Equal(p), "Expected '%s' to parse as %+v, got %+v", raw, expected, p)
+	}
+}
+
+func TestPrice_String(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "$44.00", NewPrice(4400).String())
+	assert.Equal(t, "$0.05", NewPrice(5).String())
+	assert.Equal(t, SOLD_OUT, SoldOutPrice().String())
+	assert.Equal(t, "USD $12.50", Price{Amount: null.IntFrom(1250), Currency: "USD", Availability: Availability_InStock}.String())
+	assert.Equal(t, "$30.00 (pre-order)", Price{Amount: null.IntFrom(3000), Currency: "AUD", Availability: Availability_PreOrder}.String())
+	assert.False(t, NewPrice(4400).Equal(NewPrice(4500)))
+	assert.False(t, NewPrice(4400).Equal(SoldOutPrice()))
+	assert.Equal(t, "no longer listed", DelistedPrice().String())
+	assert.False(t, DelistedPrice().IsAvailable())
+	assert.False(t, DelistedPrice().Equal(SoldOutPrice()))
+}
@@ -0,0 +1,78 @@
+package retailers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"sort"
+	"sync"
+)
+
+// ScraperFactory creates a scraper given the json config stored against the retailer in the database.
+// The config may be empty, in which case the scraper should use its built in defaults.
+type ScraperFactory func(config json.RawMessage) (VinylRetailer, error)
+
+var (
+	scraperRegistry      = map[string]ScraperFactory{}
+	scraperRegistryMutex = sync.RWMutex{}
+)
+
+// RegisterScraper makes a scraper available under a stable key. The key is what is stored in retailers.scraper_key,
+// so it must not change once a retailer row refers to it. Scrapers register themselves from init(), so registering
+// the same key twice is a programming error and panics.
+func RegisterScraper(key string, factory ScraperFactory) {
+	scraperRegistryMutex.Lock()
+	defer scraperRegistryMutex.Unlock()
+	if _, ok := scraperRegistry[key]; ok {
+		panic(fmt.Sprintf("scraper '%s' registered twice", key))
+	}
+	scraperRegistry[key] = factory
+}
+
+// NewVinylRetailer creates the scraper registered under the key, configured by config.
+func NewVinylRetailer(key string, config json.RawMessage) (VinylRetailer, error) {
+	scraperRegistryMutex.RLock()
+	factory, ok := scraperRegistry[key]
+	scraperRegistryMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("there is no scraper registered as '%s'", key)
+	}
+	scraper, err := factory(config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to configure scraper '%s'", key)
+	}
+	return scraper, nil
+}
+
+// IsRegisteredScraper returns true if a scraper has been registered under the key.
+func IsRegisteredScraper(key string) bool {
+	scraperRegistryMutex.RLock()
+	defer scraperRegistryMutex.RUnlock()
+	_, ok := scraperRegistry[key]
+	return ok
+}
+
+// RegisteredScrapers returns the keys of all registered scrapers in sorted order.
+func RegisteredScrapers() []string {
+	scraperRegistryMutex.RLock()
+	defer scraperRegistryMutex.RUnlock()
+	keys := []string{}
+	for key := range scraperRegistry {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// configuredScraper is the factory for scrapers configured by unmarshalling the retailer's json config straight
+// over the new scraper (e.g. {"fetch": {"requestIntervalMillis": 5000}}).
+func configuredScraper(create func() VinylRetailer) ScraperFactory {
+	return func(config json.RawMessage) (VinylRetailer, error) {
+		scraper := create()
+		err := configureScraper(scraper, config)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse scraper config")
+		}
+		return scraper, nil
+	}
+}
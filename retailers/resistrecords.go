@@ -1,10 +1,8 @@
 package retailers
 
 import (
+	"context"
 	"fmt"
-	"github.com/pkg/errors"
-	"io/ioutil"
-	"net/http"
 	"net/url"
 	"strings"
 )
@@ -12,81 +10,73 @@ import (
 // https://damagedmusic.com.au/?s=clowns+vinyl&post_type=product
 
 const (
-	RR_URL_PREFIX = "https://shop.resistrecords.com"
-	RR_SEARCH_URL = "https://shop.resistrecords.com/search?q=%s+vinyl"
+	RR_SCRAPER_KEY = "resistrecords"
+	RR_URL_PREFIX  = "https://shop.resistrecords.com"
+	RR_SEARCH_URL  = "https://shop.resistrecords.com/search?q=%s+vinyl"
 )
 
-type ResistRecords struct{}
+type ResistRecords struct {
+	httpFetcher
+}
+
+func init() {
+	RegisterScraper(RR_SCRAPER_KEY, configuredScraper(func() VinylRetailer { return &ResistRecords{} }))
+}
 
 func (a *ResistRecords) GetArtistQueryURL(artist string) string {
 	query := fmt.Sprintf(RR_SEARCH_URL, url.QueryEscape(artist))
 	return query
 }
 
-func (a *ResistRecords) ScrapeArtistReleases(artist string) (findings []SKU, err error) {
-
-	findings = []SKU{}
-	query := a.GetArtistQueryURL(artist)
-	resp, err := http.Get(query)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to retrieve search query %s", query)
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to extract body of search results %s", query)
-	}
-
-	toks := strings.Split(string(body), ">")
-	for idx, t := range toks {
-		if strings.Index(t, "class=\"title\"") >= 0 {
-			url := t[strings.Index(t, "href=\"")+6:]
-			url = url[0:strings.Index(url, "\"")]
-			description := toks[idx+1]
-			name := "Various"
-			title := ""
-			if strings.Index(description, "\"") > 0 {
-				name = description[0:strings.Index(description, "\"")]
-				title = description[len(name):]
-			} else {
-				title = description
-			}
-			title = title[0:strings.Index(title, "</a")]
+func (a *ResistRecords) ScrapeArtistReleases(ctx context.Context, artist string) (result ScrapeResult, err error) {
 
-			image := toks[idx-8]
-			image = image[strings.Index(image, "src=\"")+5:]
-			image = "https:" + image[0:strings.Index(image, "\"")]
-			image = strings.Replace(image, "_120x", "_240x", 1)
+	result.SKUs = []SKU{}
+	result.PagesFetched, err = a.getResultPages(ctx, a.GetArtistQueryURL(artist), func(query string, body string) (string, error) {
+		page, err := ParseHTML(RR_SCRAPER_KEY, query, body)
+		if err != nil {
+			return "", err
+		}
 
-			price := toks[idx+4]
-			if strings.Index(price, "sold-out") >= 0 {
-				price = SOLD_OUT
+		for _, item := range page.All("figure:has(a.title)") {
+			// products are titled <artist> "<title>" <format>, compilations have no artist
+			title, err := item.Text("title", "a.title")
+			if err != nil {
+				return "", err
 			}
-			if strings.Index(price, "sale") >= 0 {
-				price = toks[idx+7]
-				if strings.Index(price, "From") >= 0 {
-					price = toks[idx+10]
-				}
+			sku := SKU{Artist: "various", Name: title}
+			if idx := strings.Index(title, "\""); idx > 0 {
+				sku.Artist = strings.ToLower(strings.TrimSpace(title[0:idx]))
+				sku.Name = strings.TrimSpace(title[idx:])
 			}
-			if strings.Index(price, "from") >= 0 {
-				price = toks[idx+6]
+			if sku.Artist != strings.ToLower(artist) {
+				continue
 			}
-			if strings.Index(price, "</span") > 0 {
-				price = price[0:strings.Index(price, "</span")]
+			sku.Url, err = item.URL("url", "a.title", "href")
+			if err != nil {
+				return "", err
 			}
-			sku := SKU{
-				Url:    RR_URL_PREFIX + url,
-				Artist: strings.ToLower(strings.TrimSpace(name)),
-				Name:   strings.TrimSpace(title),
-				Price:  strings.TrimSpace(price),
-				Image:  image,
+			// the lazy loaded image has a templated src, the <noscript> fallback has a real (but small) one
+			sku.Image, err = item.URL("image", "noscript img", "src")
+			if err != nil {
+				return "", err
 			}
-
-			// artist name can contain the searched for artist if it's a split etc.
-			if sku.Artist != strings.ToLower(artist) {
-				continue
+			sku.Image = strings.Replace(sku.Image, "_120x", "_240x", 1)
+			if item.Has(".price .sold-out") {
+				sku.Price = SoldOutPrice()
+			} else {
+				// the price is the text of the .price element, after any "From", "On Sale" or original price labels
+				price, err := item.OwnText("price", ".price")
+				if err != nil {
+					return "", err
+				}
+				sku.Price = ParsePrice(price)
 			}
-			findings = append(findings, sku)
+			result.SKUs = append(result.SKUs, sku)
 		}
+		return page.OptionalURL(`.pagination a:contains("→")`, "href"), nil
+	})
+	if err != nil {
+		return ScrapeResult{}, err
 	}
-	return findings, nil
+	return result, nil
 }
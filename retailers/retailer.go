@@ -1,76 +1,93 @@
 package retailers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 )
 
-type RetailerID int
-
 const (
 	SOLD_OUT = "sold out"
 )
 
-const (
-	// enum corresponding to retailer ids in the database
-	Retailer_ArtistFirst         RetailerID = 1
-	Retailer_PoisonCity                     = 2
-	Retailer_ResistRecords                  = 3
-	Retailer_DamagedRecords                 = 4
-	Retailer_RepressedRecords               = 5
-	Retailer_Utopia                         = 6
-	Retailer_BeatdiscRecords                = 7
-	Retailer_MusicFarmers                   = 8
-	Retailer_DutchVinyl                     = 9
-	Retailer_ClarityRecords                 = 10
-	Retailer_OhJeanRecords                  = 11
-	Retailer_StrengeWorldRecords            = 12
-	Retailer_GrevilleRecords                = 13
-)
-
 type SKU struct {
-	Name        string `db:"name" json:"name"`
-	Artist      string `db:"artist" json:"artist"`
-	Url         string `db:"item_url" json:"itemUrl"`
-	Image       string `db:"image_url" json:"imageUrl"`
-	Price       string `db:"price" json:"price"`
-	Retailer    string `db:"retailer" json:"retailer"`
-	RetailerUrl string `db:"retailer_url" json:"retailerUrl"`
+	Name        string   `db:"name" json:"name"`
+	Artist      string   `db:"artist" json:"artist"`
+	Url         string   `db:"item_url" json:"itemUrl"`
+	Image       string   `db:"image_url" json:"imageUrl"`
+	Price       Price    `db:"price" json:"price"`
+	Retailer    string   `db:"retailer" json:"retailer"`
+	RetailerUrl string   `db:"retailer_url" json:"retailerUrl"`
+	SearchTerm  string   `db:"search_term" json:"searchTerm"` // the artist name (or variant) searched for to find it
+	Format      Format   `db:"format" json:"format"`
+	Edition     string   `db:"edition" json:"edition"`   // colour, limited edition, test pressing..
+	Variants    Variants `db:"variants" json:"variants"` // where the retailer sells several versions under the one listing
+	Barcode     string   `db:"barcode" json:"barcode"`   // EAN-13 (see NormaliseBarcode)
+	CatalogueNo string   `db:"catalogue_number" json:"catalogueNumber"`
 }
 
+// Codes returns the distinct barcodes and catalogue numbers of the listing and its variants.
+func (s SKU) Codes() (barcodes []string, catalogueNos []string) {
+	barcodes, catalogueNos = []string{}, []string{}
+	add := func(codes []string, code string) []string {
+		if code == "" {
+			return codes
+		}
+		for _, c := range codes {
+			if c == code {
+				return codes
+			}
+		}
+		return append(codes, code)
+	}
+	barcodes, catalogueNos = add(barcodes, s.Barcode), add(catalogueNos, s.CatalogueNo)
+	for _, v := range s.Variants {
+		barcodes, catalogueNos = add(barcodes, v.Barcode), add(catalogueNos, v.CatalogueNo)
+	}
+	return barcodes, catalogueNos
+}
+
+// ScrapeResult is what a scraper found for an artist, and how much of the retailer's site it read to find it.
+type ScrapeResult struct {
+	SKUs            []SKU
+	PagesFetched    int // pages of search results read
+	ProductsSkipped int // products in the search results that had gone (404) by the time they were read
+}
+
+// VinylRetailer is implemented by each scraper. ScrapeArtistReleases reads every page of the retailer's search
+// results for the artist, following the next page link or page parameter, up to the scraper's Fetch.MaxPages.
+// ctx is passed to every request made for the scrape (search pages, product pages, covers..), so the scrape stops
+// and fails once ctx is done.
 type VinylRetailer interface {
 	GetArtistQueryURL(artist string) string
-	ScrapeArtistReleases(artist string) ([]SKU, error)
+	ScrapeArtistReleases(ctx context.Context, artist string) (ScrapeResult, error)
 }
 
-func VinylRetailerFactory(retailerId RetailerID) (VinylRetailer, error) {
-	switch retailerId {
-	case Retailer_ArtistFirst:
-		return &ArtistFirst{}, nil
-	case Retailer_PoisonCity:
-		return &PoisonCity{}, nil
-	case Retailer_ResistRecords:
-		return &ResistRecords{}, nil
-	case Retailer_DamagedRecords:
-		return &DamagedRecords{}, nil
-	case Retailer_RepressedRecords:
-		return &RepressedRecords{}, nil
-	case Retailer_Utopia:
-		return &Utopia{}, nil
-	case Retailer_BeatdiscRecords:
-		return &BeatDiscRecords{}, nil
-	case Retailer_MusicFarmers:
-		return &MusicFarmers{}, nil
-	case Retailer_DutchVinyl:
-		return &DutchVinyl{}, nil
-	case Retailer_ClarityRecords:
-		return &ClarityRecords{}, nil
-	case Retailer_OhJeanRecords:
-		return &OhJeanRecords{}, nil
-	case Retailer_StrengeWorldRecords:
-		return &StrangeWorldRecords{}, nil
-	case Retailer_GrevilleRecords:
-		return &GrevilleRecords{}, nil
+// ScrapeArtist runs the scraper for the artist, turning a panic in the scraper (a bug, or markup it doesn't cope with)
+// into a ParseError rather than taking down the process. Each SKU found records the artist as its search term, and
+// its format and edition are read from its title where the scraper hasn't set them.
+func ScrapeArtist(ctx context.Context, scraper VinylRetailer, retailer string, artist string) (result ScrapeResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = ScrapeResult{}, &ParseError{Retailer: retailer, Field: "results", Reason: fmt.Sprintf("scraper panicked: %v", r)}
+		}
+	}()
+	result, err = scraper.ScrapeArtistReleases(ctx, artist)
+	for i := range result.SKUs {
+		sku := &result.SKUs[i]
+		sku.SearchTerm = artist
+		if sku.Format == Format_Unknown {
+			sku.Format = ParseFormat(sku.Name)
+		}
+		if sku.Edition == "" {
+			sku.Edition = ParseEdition(sku.Name)
+		}
 	}
+	return result, err
+}
 
-	return nil, fmt.Errorf("there is no retailer with id %v", retailerId)
+// IsDeadlineExceeded returns true if the scrape failed because it ran out of time (its context's deadline, or a
+// request timing out), rather than because of what the retailer returned. Worth trying again later.
+func IsDeadlineExceeded(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
 }
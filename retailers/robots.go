@@ -0,0 +1,173 @@
+package retailers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ROBOTS_TXT_TTL = 24 * time.Hour
+)
+
+// robotsRules are the rules from a host's robots.txt that apply to our user agent.
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+	fetchedAt  time.Time
+}
+
+type robotsRule struct {
+	allow   bool
+	length  int // length of the path pattern. the longest matching pattern wins
+	pattern *regexp.Regexp
+}
+
+var (
+	robotsCache      = map[string]*robotsRules{}
+	robotsCacheMutex = sync.Mutex{}
+)
+
+// getRobots returns the robots.txt rules for the url's host, fetching them at most once a day. A robots.txt
+// that can't be read allows everything.
+func (f *httpFetcher) getRobots(ctx context.Context, u *url.URL) *robotsRules {
+	key := u.Scheme + "://" + u.Host + " " + f.Fetch.UserAgent
+	robotsCacheMutex.Lock()
+	rules, ok := robotsCache[key]
+	robotsCacheMutex.Unlock()
+	if ok && time.Since(rules.fetchedAt) < ROBOTS_TXT_TTL {
+		return rules
+	}
+
+	robotsUrl := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	status, body, _, err := f.fetch(ctx, robotsUrl, 0)
+	if ctx.Err() != nil {
+		// didn't get an answer, so don't cache allowing everything for a day
+		return &robotsRules{}
+	}
+	if err != nil || status != http.StatusOK {
+		rules = &robotsRules{}
+	} else {
+		rules = parseRobots(body, f.Fetch.UserAgent)
+	}
+	rules.fetchedAt = time.Now()
+	robotsCacheMutex.Lock()
+	robotsCache[key] = rules
+	robotsCacheMutex.Unlock()
+	return rules
+}
+
+// parseRobots reads the rules in the groups for our user agent, or the * groups if none name us (RFC 9309).
+func parseRobots(body string, userAgent string) *robotsRules {
+	agent := strings.ToLower(userAgent)
+	if idx := strings.IndexAny(agent, "/ "); idx > 0 {
+		agent = agent[0:idx]
+	}
+
+	type group struct {
+		agents []string
+		rules  robotsRules
+	}
+	groups := []*group{}
+	var current *group
+	inAgents := false
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[0:idx]
+		}
+		idx := strings.Index(line, ":")
+		if idx < 0 {
+			continue
+		}
+		field := strings.ToLower(strings.TrimSpace(line[0:idx]))
+		value := strings.TrimSpace(line[idx+1:])
+		switch field {
+		case "user-agent":
+			if !inAgents {
+				current = &group{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			inAgents = true
+		case "allow", "disallow":
+			inAgents = false
+			if current == nil || value == "" {
+				continue
+			}
+			current.rules.rules = append(current.rules.rules, robotsRule{
+				allow:   field == "allow",
+				length:  len(value),
+				pattern: robotsPattern(value),
+			})
+		case "crawl-delay":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			if secs, err := strconv.ParseFloat(value, 64); err == nil {
+				current.rules.crawlDelay = time.Duration(secs * float64(time.Second))
+			}
+		}
+	}
+
+	// a group applies to every user agent it lists, so all of its agents are read before deciding which rules
+	// it adds to. a group that names us is ours even if it also lists *
+	named, star := &robotsRules{}, &robotsRules{}
+	for _, g := range groups {
+		var rules *robotsRules
+		for _, a := range g.agents {
+			if a == "*" {
+				if rules == nil {
+					rules = star
+				}
+			} else if strings.Contains(agent, a) {
+				rules = named
+			}
+		}
+		if rules == nil {
+			continue
+		}
+		rules.rules = append(rules.rules, g.rules.rules...)
+		if g.rules.crawlDelay > rules.crawlDelay {
+			rules.crawlDelay = g.rules.crawlDelay
+		}
+	}
+	if len(named.rules) > 0 || named.crawlDelay > 0 {
+		return named
+	}
+	return star
+}
+
+// robotsPattern converts a robots.txt path pattern, which may use * and a trailing $, to a regex.
+func robotsPattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	expr := "^" + strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1)
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// allowed applies the longest matching rule to the url. Allow wins a tie, and no matching rule allows.
+func (r *robotsRules) allowed(u *url.URL) bool {
+	path := u.RequestURI()
+	allow, longest := true, -1
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > longest || (rule.length == longest && rule.allow) {
+			allow, longest = rule.allow, rule.length
+		}
+	}
+	return allow
+}
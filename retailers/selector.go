@@ -0,0 +1,261 @@
+package retailers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/andybalholm/cascadia"
+	"github.com/pkg/errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	SELECTOR_SCRAPER_KEY = "selector"
+)
+
+// SelectorArtistMatch is the rule used to decide whether a result belongs to the searched for artist.
+type SelectorArtistMatch string
+
+const (
+	SelectorMatch_Exact    SelectorArtistMatch = "exact"    // artist is the searched for artist
+	SelectorMatch_Contains SelectorArtistMatch = "contains" // artist contains the searched for artist (splits etc)
+)
+
+// defaultSelectorTitleRegex splits "<artist> - <title>", the most common way record stores title products.
+var defaultSelectorTitleRegex = `^\s*(?P<artist>.+?)\s+[-–—]\s+(?P<title>.+?)\s*$`
+
+// SelectorField locates a value within a search result: the text of the first element matching the selector, or
+// one of its attributes. An empty selector is the result element itself (e.g. a data-name attribute on the card).
+type SelectorField struct {
+	Selector string `json:"selector"`
+	Attr     string `json:"attr"`    // read the attribute rather than the text
+	OwnText  bool   `json:"ownText"` // only the element's own text, not that of its children ("From" labels etc)
+}
+
+// SelectorScraper scrapes a store whose search results page lists a card per product, driven entirely by a
+// definition stored as the retailer's scraper config (scraper_key 'selector'). Adding a store like this, or fixing
+// one after it changes its markup, is then a data change rather than a release e.g.
+//
+//	{
+//	  "searchUrl": "https://www.strangeworldrecords.com.au/search?q=%s+vinyl",
+//	  "item": ".grid:has(.one-fifth):not(:has(.grid))",
+//	  "title": {"selector": ".one-fifth a", "attr": "title"},
+//	  "url": {"selector": ".one-fifth a", "attr": "href"},
+//	  "image": {"selector": ".one-fifth img", "attr": "src"},
+//	  "price": {"selector": "[itemprop=price]"},
+//	  "soldOut": ".badge--sold-out",
+//	  "fetch": {"robots": "obey"}
+//	}
+//
+// Definitions are checked when the scraper is created, and should be checked against recorded pages with the
+// fixture tests before being put on a retailer row.
+type SelectorScraper struct {
+	httpFetcher
+	SearchURL   string              `json:"searchUrl"`   // the artist is query escaped into the %s
+	Item        string              `json:"item"`        // selects each product in the results
+	Title       SelectorField       `json:"title"`       // split into artist and release by TitleRegex, unless Artist is set
+	Artist      *SelectorField      `json:"artist"`      // for stores that list the artist separately (e.g. as the vendor)
+	URL         SelectorField       `json:"url"`         // link to the product, resolved against the results page
+	Image       SelectorField       `json:"image"`       // optional
+	Price       SelectorField       `json:"price"`       // parsed with ParsePrice, so "sold out" and pre-order text is detected
+	SoldOut     string              `json:"soldOut"`     // optional. the product is sold out if the item or anything in it matches
+	TitleRegex  string              `json:"titleRegex"`  // named groups artist and title. without an artist group the artist is the one searched for
+	ArtistMatch SelectorArtistMatch `json:"artistMatch"` // defaults to exact
+	NextPage    string              `json:"nextPage"`    // optional link to the next page of results (up to fetch.maxPages are read)
+	Barcode     *SelectorField      `json:"barcode"`     // optional, for stores that show the barcode (or catalogue number) in their results
+	CatalogueNo *SelectorField      `json:"catalogueNumber"`
+
+	retailer   string // the store's host, to name it in parse errors
+	titleRegex *regexp.Regexp
+}
+
+func init() {
+	RegisterScraper(SELECTOR_SCRAPER_KEY, selectorScraper)
+}
+
+func selectorScraper(config json.RawMessage) (VinylRetailer, error) {
+	s := &SelectorScraper{
+		TitleRegex:  defaultSelectorTitleRegex,
+		ArtistMatch: SelectorMatch_Exact,
+	}
+	err := configureScraper(s, config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse selector scraper definition")
+	}
+	err = s.validate()
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid selector scraper definition")
+	}
+	return s, nil
+}
+
+// validate checks the definition is complete and that its selectors and regex compile, so that a bad definition
+// fails when the retailer is loaded rather than finding nothing on every scan.
+func (s *SelectorScraper) validate() error {
+	if strings.Count(s.SearchURL, "%s") != 1 {
+		return fmt.Errorf("searchUrl must contain a single %%s for the artist")
+	}
+	searchUrl, err := url.Parse(fmt.Sprintf(s.SearchURL, "artist"))
+	if err != nil || searchUrl.Host == "" {
+		return fmt.Errorf("invalid searchUrl '%s'", s.SearchURL)
+	}
+	s.retailer = searchUrl.Host
+	if s.Item == "" {
+		return fmt.Errorf("item is required")
+	}
+	if s.URL.Attr == "" {
+		return fmt.Errorf("url must name the attribute holding the link")
+	}
+	selectors := []struct{ name, selector string }{
+		{"item", s.Item}, {"title", s.Title.Selector}, {"url", s.URL.Selector}, {"image", s.Image.Selector},
+		{"price", s.Price.Selector}, {"soldOut", s.SoldOut}, {"nextPage", s.NextPage},
+	}
+	for _, optional := range []struct {
+		name  string
+		field *SelectorField
+	}{{"artist", s.Artist}, {"barcode", s.Barcode}, {"catalogueNumber", s.CatalogueNo}} {
+		if optional.field != nil {
+			selectors = append(selectors, struct{ name, selector string }{optional.name, optional.field.Selector})
+		}
+	}
+	for _, selector := range selectors {
+		if selector.selector == "" {
+			continue
+		}
+		if _, err := cascadia.ParseGroup(selector.selector); err != nil {
+			return errors.Wrapf(err, "invalid %s selector '%s'", selector.name, selector.selector)
+		}
+	}
+	if s.ArtistMatch != SelectorMatch_Exact && s.ArtistMatch != SelectorMatch_Contains {
+		return fmt.Errorf("unknown artistMatch '%s'", s.ArtistMatch)
+	}
+	s.titleRegex, err = regexp.Compile(s.TitleRegex)
+	if err != nil {
+		return errors.Wrapf(err, "invalid titleRegex")
+	}
+	if s.titleRegex.SubexpIndex("title") < 0 {
+		return fmt.Errorf("titleRegex must have a title group")
+	}
+	return nil
+}
+
+func (s *SelectorScraper) GetArtistQueryURL(artist string) string {
+	query := fmt.Sprintf(s.SearchURL, url.QueryEscape(artist))
+	return query
+}
+
+func (s *SelectorScraper) ScrapeArtistReleases(ctx context.Context, artist string) (result ScrapeResult, err error) {
+
+	result.SKUs = []SKU{}
+	result.PagesFetched, err = s.getResultPages(ctx, s.GetArtistQueryURL(artist), func(query string, body string) (string, error) {
+		page, err := ParseHTML(s.retailer, query, body)
+		if err != nil {
+			return "", err
+		}
+		for _, item := range page.All(s.Item) {
+			sku, ok, err := s.parseItem(item, artist)
+			if err != nil {
+				return "", err
+			}
+			if ok {
+				result.SKUs = append(result.SKUs, sku)
+			}
+		}
+		if s.NextPage == "" {
+			return "", nil
+		}
+		return page.OptionalURL(s.NextPage, "href"), nil
+	})
+	if err != nil {
+		return ScrapeResult{}, err
+	}
+	return result, nil
+}
+
+// parseItem reads a search result, returning false if it isn't a release by the artist.
+func (s *SelectorScraper) parseItem(item Node, artist string) (sku SKU, ok bool, err error) {
+	title, err := s.field(item, "title", s.Title)
+	if err != nil {
+		return sku, false, err
+	}
+	match := s.titleRegex.FindStringSubmatch(title)
+	if match == nil {
+		// not titled like a release (merch, gift vouchers, blog posts..)
+		return sku, false, nil
+	}
+	sku.Name = strings.TrimSpace(match[s.titleRegex.SubexpIndex("title")])
+	sku.Artist = artist
+	if s.Artist != nil {
+		sku.Artist, err = s.field(item, "artist", *s.Artist)
+		if err != nil {
+			return sku, false, err
+		}
+	} else if idx := s.titleRegex.SubexpIndex("artist"); idx >= 0 {
+		sku.Artist = match[idx]
+	}
+	sku.Artist = strings.ToLower(strings.TrimSpace(sku.Artist))
+	switch s.ArtistMatch {
+	case SelectorMatch_Contains:
+		ok = strings.Contains(sku.Artist, strings.ToLower(artist))
+	default:
+		ok = sku.Artist == strings.ToLower(artist)
+	}
+	if !ok {
+		return sku, false, nil
+	}
+
+	sku.Url, err = item.URL("url", s.URL.Selector, s.URL.Attr)
+	if err != nil {
+		return sku, false, err
+	}
+	if s.Image.Selector != "" || s.Image.Attr != "" {
+		attr := s.Image.Attr
+		if attr == "" {
+			attr = "src"
+		}
+		// a card without an image is still worth reporting
+		if image := item.OptionalAttr(s.Image.Selector, attr); image != "" {
+			sku.Image, err = item.URL("image", s.Image.Selector, attr)
+			if err != nil {
+				return sku, false, err
+			}
+		}
+	}
+	if s.Barcode != nil {
+		sku.Barcode = NormaliseBarcode(s.optionalField(item, *s.Barcode))
+	}
+	if s.CatalogueNo != nil {
+		sku.CatalogueNo = NormaliseCatalogueNumber(s.optionalField(item, *s.CatalogueNo))
+	}
+	if s.SoldOut != "" && (item.Matches(s.SoldOut) || item.Has(s.SoldOut)) {
+		sku.Price = SoldOutPrice()
+		return sku, true, nil
+	}
+	price, err := s.field(item, "price", s.Price)
+	if err != nil {
+		return sku, false, err
+	}
+	sku.Price = ParsePrice(price)
+	return sku, true, nil
+}
+
+func (s *SelectorScraper) field(item Node, name string, field SelectorField) (string, error) {
+	switch {
+	case field.Attr != "":
+		return item.Attr(name, field.Selector, field.Attr)
+	case field.OwnText:
+		return item.OwnText(name, field.Selector)
+	default:
+		return item.Text(name, field.Selector)
+	}
+}
+
+// optionalField reads a field that not every result has, returning "" where it's missing.
+func (s *SelectorScraper) optionalField(item Node, field SelectorField) string {
+	if field.Attr != "" {
+		return item.OptionalAttr(field.Selector, field.Attr)
+	}
+	return item.OptionalText(field.Selector)
+}
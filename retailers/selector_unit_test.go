@@ -0,0 +1,102 @@
+//go:build unit_test
+// +build unit_test
+
+package retailers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelector_Validate(t *testing.T) {
+	t.Parallel()
+
+	valid := `"searchUrl": "https://store.test/search?q=%s", "item": ".card", "title": {"selector": "h3"},
+		"url": {"selector": "a", "attr": "href"}, "price": {"selector": ".price"}`
+	_, err := NewVinylRetailer(SELECTOR_SCRAPER_KEY, json.RawMessage("{"+valid+"}"))
+	assert.Nil(t, err, "Expected a complete definition to be valid")
+
+	for name, definition := range map[string]string{
+		"no artist in url":  `{"searchUrl": "https://store.test/search", "item": ".card"}`,
+		"no item":           `{"searchUrl": "https://store.test/search?q=%s", "url": {"attr": "href"}}`,
+		"bad selector":      "{" + valid + `, "soldOut": ".badge["}`,
+		"bad regex":         "{" + valid + `, "titleRegex": "(?P<title>"}`,
+		"no title group":    "{" + valid + `, "titleRegex": "(?P<artist>.*)"}`,
+		"bad artist match":  "{" + valid + `, "artistMatch": "fuzzy"}`,
+		"url not attribute": `{"searchUrl": "https://store.test/search?q=%s", "item": ".card", "url": {"selector": "a"}}`,
+	} {
+		_, err := NewVinylRetailer(SELECTOR_SCRAPER_KEY, json.RawMessage(definition))
+		assert.NotNil(t, err, "Expected definition to be rejected: %s", name)
+	}
+}
+
+func TestSelector_Scrape(t *testing.T) {
+	t.Parallel()
+
+	pages := map[string]string{
+		"1": `<div class="card"><h3>Clowns - Bad Blood LP</h3><a href="/p/bad-blood">view</a><span class="price">$35.00</span></div>
+			<div class="card sold"><h3>Clowns - Lucid Again LP</h3><a href="/p/lucid-again">view</a><span class="price">$40.00</span></div>
+			<div class="card"><h3>Clowns Tote Bag</h3><a href="/p/tote">view</a><span class="price">$20.00</span></div>
+			<a class="next" href="/search?q=clowns&page=2">next</a>`,
+		"2": `<div class="card"><h3>Clowns / Pist Idiots - Split 7"</h3><a href="/p/split">view</a><span class="price">Pre-order $12.00</span></div>
+			<div class="card"><h3>Pist Idiots - Idiot Pop LP</h3><a href="/p/idiot-pop">view</a><span class="price">$30.00</span></div>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		number := r.URL.Query().Get("page")
+		if number == "" {
+			number = "1"
+		}
+		page, ok := pages[number]
+		if r.URL.Path != "/search" || !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("<html><body>" + page + "</body></html>"))
+	}))
+	defer server.Close()
+
+	definition := fmt.Sprintf(`{"searchUrl": "%s/search?q=%%s", "item": ".card", "title": {"selector": "h3"},
+		"url": {"selector": "a", "attr": "href"}, "price": {"selector": ".price"}, "soldOut": ".sold",
+		"artistMatch": "contains", "nextPage": "a.next", "fetch": {"robots": "ignore", "requestIntervalMillis": 0}}`, server.URL)
+	scraper, err := NewVinylRetailer(SELECTOR_SCRAPER_KEY, json.RawMessage(definition))
+	require.Nil(t, err)
+	result, err := scraper.ScrapeArtistReleases(context.Background(), "clowns")
+	require.Nil(t, err)
+	assert.Equal(t, 2, result.PagesFetched)
+	skus := result.SKUs
+	require.Equal(t, 3, len(skus), "Expected both pages to be read and non release/other artist items dropped")
+
+	assert.Equal(t, "Bad Blood LP", skus[0].Name)
+	assert.Equal(t, "clowns", skus[0].Artist)
+	assert.Equal(t, server.URL+"/p/bad-blood", skus[0].Url)
+	assert.Equal(t, NewPrice(3500), skus[0].Price)
+	assert.Equal(t, Availability_SoldOut, skus[1].Price.Availability)
+	assert.Equal(t, `Split 7"`, skus[2].Name)
+	assert.Equal(t, "clowns / pist idiots", skus[2].Artist)
+	assert.Equal(t, Availability_PreOrder, skus[2].Price.Availability)
+}
+
+func TestSelector_Definitions(t *testing.T) {
+	t.Parallel()
+
+	// the fixtures only replay the first page of results, so each definition must say how to find the next
+	files, err := filepath.Glob("definitions/*.json")
+	require.Nil(t, err)
+	require.NotEmpty(t, files)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		require.Nil(t, err)
+		scraper, err := NewVinylRetailer(SELECTOR_SCRAPER_KEY, json.RawMessage(data))
+		require.Nil(t, err, "Expected definition %s to be valid", file)
+		assert.NotEmpty(t, scraper.(*SelectorScraper).NextPage, "Expected definition %s to page through results", file)
+	}
+}
@@ -0,0 +1,540 @@
+package retailers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gavinturner/vinylretailers/util/log"
+	"github.com/pkg/errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ShopifyArtistMatch is the rule used to decide whether a shopify product belongs to the searched for artist.
+// Stores are inconsistent about where they put the artist: some use the product vendor, others prefix the title.
+type ShopifyArtistMatch int
+
+const (
+	ShopifyMatch_Vendor         ShopifyArtistMatch = iota // vendor is the artist
+	ShopifyMatch_VendorPrefix                             // vendor starts with the artist
+	ShopifyMatch_VendorContains                           // vendor contains the artist (splits etc)
+	ShopifyMatch_TitlePrefix                              // title starts with the artist: CLOWNS 'Bad Blood' LP
+	ShopifyMatch_TitleSplit                               // title is artist and release split by a dash: Clowns - Bad Blood
+)
+
+var shopifyArtistMatchNames = map[ShopifyArtistMatch]string{
+	ShopifyMatch_Vendor:         "vendor",
+	ShopifyMatch_VendorPrefix:   "vendorPrefix",
+	ShopifyMatch_VendorContains: "vendorContains",
+	ShopifyMatch_TitlePrefix:    "titlePrefix",
+	ShopifyMatch_TitleSplit:     "titleSplit",
+}
+
+// MarshalText allows the artist match rule to be written by name in retailer config.
+func (m ShopifyArtistMatch) MarshalText() ([]byte, error) {
+	name, ok := shopifyArtistMatchNames[m]
+	if !ok {
+		return nil, fmt.Errorf("unknown shopify artist match %v", int(m))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText reads the artist match rule by name from retailer config.
+func (m *ShopifyArtistMatch) UnmarshalText(text []byte) error {
+	for match, name := range shopifyArtistMatchNames {
+		if name == string(text) {
+			*m = match
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown shopify artist match '%s'", string(text))
+}
+
+// ShopifyJSONMarker locates a product json object embedded in a page. Each token is searched for in turn and the
+// product is taken to be the first balanced {...} object following the last token.
+type ShopifyJSONMarker []string
+
+// ShopifyStore configures the shopify scraping engine for a single storefront. Adding a new shopify record store
+// should only require a new entry in ShopifyStores, or a retailer row using the generic shopify scraper.
+type ShopifyStore struct {
+	httpFetcher
+	BaseURL     string             `json:"baseUrl"`     // https://poisoncityestore.com
+	SearchPath  string             `json:"searchPath"`  // /search?q=%s+vinyl (the artist is query escaped into the path)
+	PageParam   string             `json:"pageParam"`   // query parameter used to page through search results ("page" unless set)
+	ArtistMatch ShopifyArtistMatch `json:"artistMatch"` // how to decide the product belongs to the artist
+	VinylOnly   bool               `json:"vinylOnly"`   // drop products that don't look like vinyl (merch, cds, gift cards in the nav)
+	TitleTrim   []string           `json:"titleTrim"`   // retailer noise stripped from the end of product titles (" - Vinyl - New")
+
+	// SkuIsCatalogueNo is set for a label's own store, whose variant skus are its catalogue numbers. Other stores'
+	// skus are their own stock codes, which can look like catalogue numbers but would match unrelated releases.
+	SkuIsCatalogueNo bool `json:"skuIsCatalogueNumber"`
+
+	// SearchJSON is set when the search results page embeds the full product json for each result, which saves
+	// a request per product. Otherwise each product found is read from products/<handle>.js, falling back to
+	// ProductJSON markers on the product page when the .js endpoint is unavailable.
+	SearchJSON  ShopifyJSONMarker   `json:"searchJson"`
+	ProductJSON []ShopifyJSONMarker `json:"productJson"`
+}
+
+// ShopifyVariant is a single purchasable variant (colour, format etc) of a shopify product.
+type ShopifyVariant struct {
+	Id                  int64       `json:"id"`
+	Title               string      `json:"title"`
+	Option1             string      `json:"option1"`
+	Option2             interface{} `json:"option2"`
+	Option3             interface{} `json:"option3"`
+	Sku                 string      `json:"sku"`
+	Available           bool        `json:"available"`
+	Name                string      `json:"name"`
+	PublicTitle         interface{} `json:"public_title"`
+	Options             []string    `json:"options"`
+	Price               int         `json:"price"`
+	CompareAtPrice      interface{} `json:"compare_at_price"`
+	InventoryQuantity   int         `json:"inventory_quantity"`
+	InventoryManagement string      `json:"inventory_management"`
+	InventoryPolicy     string      `json:"inventory_policy"`
+	Barcode             string      `json:"barcode"`
+}
+
+// ShopifyProduct is the product json served by shopify from products/<handle>.js, and the same structure that
+// themes embed in product and search pages. Prices are in cents. Note that products/<handle>.json is not used as
+// it carries no availability.
+type ShopifyProduct struct {
+	Id            int64            `json:"id"`
+	Title         string           `json:"title"`
+	Handle        string           `json:"handle"`
+	Description   string           `json:"description"`
+	PublishedAt   time.Time        `json:"published_at"`
+	CreatedAt     time.Time        `json:"created_at"`
+	Vendor        string           `json:"vendor"`
+	Type          string           `json:"type"`
+	Tags          []string         `json:"tags"`
+	Price         int              `json:"price"`
+	PriceMin      int              `json:"price_min"`
+	PriceMax      int              `json:"price_max"`
+	Available     bool             `json:"available"`
+	Variants      []ShopifyVariant `json:"variants"`
+	Images        []string         `json:"images"`
+	FeaturedImage string           `json:"featured_image"`
+	Options       []string         `json:"options"`
+}
+
+var (
+	shopifyProductLinkRegex = regexp.MustCompile(`href="[^"]*/products/([^"/?#.]+)(?:[?#][^"]*)?"`)
+	shopifyVinylRegex       = regexp.MustCompile(`(?i)(^|[^a-z])(vinyl|\d?x?lps?)([^a-z]|$)|\b(7|10|12) ?("|”|inch)`)
+	shopifyTitleSplitRegex  = regexp.MustCompile(`\s*[-–—]\s+|\s+[-–—]\s*`)
+)
+
+func (s *ShopifyStore) GetArtistQueryURL(artist string) string {
+	query := s.BaseURL + fmt.Sprintf(s.SearchPath, url.QueryEscape(artist))
+	return query
+}
+
+func (s *ShopifyStore) GetArtistQueryForPageURL(artist string, page int) string {
+	query := s.GetArtistQueryURL(artist)
+	if s.PageParam == "" || page <= 1 {
+		return query
+	}
+	return fmt.Sprintf("%s&%s=%v", query, s.PageParam, page)
+}
+
+func (s *ShopifyStore) ScrapeArtistReleases(ctx context.Context, artist string) (result ScrapeResult, err error) {
+
+	result.SKUs = []SKU{}
+	products, pages, skipped, err := s.searchProducts(ctx, artist)
+	if err != nil {
+		return ScrapeResult{}, err
+	}
+	result.PagesFetched, result.ProductsSkipped = pages, skipped
+	for _, p := range products {
+		if s.VinylOnly && !isShopifyVinyl(p) {
+			continue
+		}
+		sku, ok := s.productToSKU(artist, p)
+		if !ok {
+			continue
+		}
+		result.SKUs = append(result.SKUs, sku)
+	}
+	return result, nil
+}
+
+// searchProducts runs the artist search (all pages if the store pages its results) and returns the product
+// json for every distinct product found. Stores ignore a page past the end of the results, or a page parameter they
+// don't support, and repeat products already seen, so paging stops at the first page with nothing new. A product
+// that has gone since the results were rendered is skipped (and counted), but any other failure to read one fails
+// the search, as its listings would otherwise look like they had gone.
+func (s *ShopifyStore) searchProducts(ctx context.Context, artist string) (products []ShopifyProduct, pages int, skipped int, err error) {
+	products = []ShopifyProduct{}
+	seen := map[string]struct{}{}
+	page := 1
+	pages, err = s.getResultPages(ctx, s.GetArtistQueryForPageURL(artist, page), func(query string, body string) (string, error) {
+		found := false
+		if len(s.SearchJSON) > 0 {
+			for _, jd := range extractEmbeddedJSON(body, s.SearchJSON) {
+				p := ShopifyProduct{}
+				if err := json.Unmarshal([]byte(jd), &p); err != nil {
+					return "", errors.Wrapf(err, "failed to parse product json in search results %s", query)
+				}
+				if _, ok := seen[p.Handle]; ok || p.Handle == "" {
+					continue
+				}
+				seen[p.Handle] = struct{}{}
+				products = append(products, p)
+				found = true
+			}
+		} else {
+			for _, handle := range extractProductHandles(body) {
+				if _, ok := seen[handle]; ok {
+					continue
+				}
+				seen[handle] = struct{}{}
+				p, err := s.getProduct(ctx, handle)
+				if IsPageNotFound(err) {
+					log.Warnf("Skipping shopify product '%s': %s", handle, err.Error())
+					skipped++
+					continue
+				}
+				if err != nil {
+					return "", err
+				}
+				products = append(products, *p)
+				found = true
+			}
+		}
+		if !found || s.PageParam == "" {
+			return "", nil
+		}
+		page++
+		return s.GetArtistQueryForPageURL(artist, page), nil
+	})
+	if err != nil {
+		return nil, pages, skipped, err
+	}
+	return products, pages, skipped, nil
+}
+
+// getProduct reads the product json for a handle, from the products/<handle>.js endpoint where the store
+// serves it and otherwise (if the store has a marker for it) from the json embedded in the product page.
+func (s *ShopifyStore) getProduct(ctx context.Context, handle string) (*ShopifyProduct, error) {
+	productUrl := s.productURL(handle)
+	p := ShopifyProduct{}
+	body, err := s.getPage(ctx, productUrl+".js")
+	if err == nil {
+		err = json.Unmarshal([]byte(body), &p)
+		if err == nil {
+			return &p, nil
+		}
+	} else if !IsPageNotFound(err) {
+		return nil, errors.Wrapf(err, "failed to retrieve product json for %s", productUrl)
+	}
+	if len(s.ProductJSON) == 0 {
+		return nil, errors.Wrapf(err, "failed to retrieve product json for %s", productUrl)
+	}
+
+	body, err = s.getPage(ctx, productUrl)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve product page %s", productUrl)
+	}
+	for _, marker := range s.ProductJSON {
+		for _, jd := range extractEmbeddedJSON(body, marker) {
+			err = json.Unmarshal([]byte(jd), &p)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse product json in %s", productUrl)
+			}
+			return &p, nil
+		}
+	}
+	return nil, fmt.Errorf("no product json found in %s", productUrl)
+}
+
+// trimFoldPrefix strips prefix from the start of s, ignoring case. Case mapping can change a character's byte
+// length, so the prefix is matched rune by rune in s rather than sliced off by its own length.
+func trimFoldPrefix(s string, prefix string) (string, bool) {
+	for i := range s {
+		if i > 0 && strings.EqualFold(s[:i], prefix) {
+			return s[i:], true
+		}
+	}
+	if strings.EqualFold(s, prefix) {
+		return "", true
+	}
+	return s, false
+}
+
+func (s *ShopifyStore) productURL(handle string) string {
+	return s.BaseURL + "/products/" + handle
+}
+
+// productToSKU applies the store's artist match to the product and converts it to a sku. Returns false if the
+// product is not by the artist.
+func (s *ShopifyStore) productToSKU(artist string, p ShopifyProduct) (SKU, bool) {
+	given := strings.TrimSpace(artist)
+	artist = strings.ToLower(given)
+	vendor := strings.ToLower(strings.TrimSpace(p.Vendor))
+	title := strings.TrimSpace(p.Title)
+	if title == "" && len(p.Variants) > 0 {
+		title = strings.TrimSpace(p.Variants[0].Name)
+	}
+
+	name := artist
+	switch s.ArtistMatch {
+	case ShopifyMatch_Vendor:
+		if vendor != artist {
+			return SKU{}, false
+		}
+	case ShopifyMatch_VendorPrefix:
+		if !strings.HasPrefix(vendor, artist) {
+			return SKU{}, false
+		}
+	case ShopifyMatch_VendorContains:
+		if strings.Index(vendor, artist) < 0 {
+			return SKU{}, false
+		}
+		name = vendor
+	case ShopifyMatch_TitlePrefix:
+		rest, ok := trimFoldPrefix(title, given)
+		if !ok {
+			return SKU{}, false
+		}
+		title = strings.TrimSpace(rest)
+	case ShopifyMatch_TitleSplit:
+		// either "artist - title" or "title – artist"
+		parts := shopifyTitleSplitRegex.Split(title, 2)
+		if len(parts) != 2 {
+			return SKU{}, false
+		}
+		if strings.ToLower(parts[0]) == artist {
+			title = parts[1]
+		} else if strings.ToLower(parts[1]) == artist {
+			title = parts[0]
+		} else {
+			return SKU{}, false
+		}
+	}
+
+	for _, suffix := range s.TitleTrim {
+		title = strings.TrimSuffix(title, suffix)
+	}
+	title = trimQuotes(strings.TrimSpace(title))
+
+	sku := SKU{
+		Url:     s.productURL(p.Handle),
+		Artist:  name,
+		Name:    title,
+		Price:   shopifyPrice(p),
+		Image:   shopifyImageURL(p.FeaturedImage),
+		Format:  shopifyFormat(p),
+		Edition: ParseEdition(p.Title),
+	}
+	sku.Variants = shopifyVariants(p, sku.Format, s.SkuIsCatalogueNo)
+	// a product sold several ways has the codes of each variant. the product's are those of its first variant
+	if len(p.Variants) > 0 {
+		sku.Barcode = NormaliseBarcode(p.Variants[0].Barcode)
+		if s.SkuIsCatalogueNo {
+			sku.CatalogueNo = NormaliseCatalogueNumber(p.Variants[0].Sku)
+		}
+	}
+	return sku, true
+}
+
+// shopifyFormat reads the format from the product title, type or tags, or failing that from the variants if
+// they're all the same format.
+func shopifyFormat(p ShopifyProduct) Format {
+	for _, text := range []string{p.Title, p.Type, strings.Join(p.Tags, " ")} {
+		if format := ParseFormat(text); format != Format_Unknown {
+			return format
+		}
+	}
+	format := Format_Unknown
+	for i, v := range p.Variants {
+		variant := ParseFormat(v.Title)
+		if i > 0 && variant != format {
+			return Format_Unknown
+		}
+		format = variant
+	}
+	return format
+}
+
+// shopifyVariants returns the product's variants with their own price and availability, or nil if the product is
+// only sold one way (shopify gives it a single "Default Title" variant). Variant skus are only read as catalogue
+// numbers if the store is the label's.
+func shopifyVariants(p ShopifyProduct, format Format, skuIsCatalogueNo bool) Variants {
+	if len(p.Variants) == 0 || (len(p.Variants) == 1 && p.Variants[0].Title == "Default Title") {
+		return nil
+	}
+	variants := Variants{}
+	for _, v := range p.Variants {
+		variant := Variant{
+			Title:   v.Title,
+			Format:  ParseFormat(v.Title),
+			Edition: ParseEdition(v.Title),
+			Price:   shopifyVariantPrice(p, v),
+			Barcode: NormaliseBarcode(v.Barcode),
+		}
+		if skuIsCatalogueNo {
+			variant.CatalogueNo = NormaliseCatalogueNumber(v.Sku)
+		}
+		if variant.Format == Format_Unknown {
+			variant.Format = format
+		}
+		if variant.Edition == "" && isEdition(v.Title) {
+			// variants are often just the colour e.g. "Red"
+			variant.Edition = v.Title
+		}
+		variants = append(variants, variant)
+	}
+	return variants
+}
+
+// shopifyVariantPrice is the price and availability of a single variant, read the same way as shopifyPrice.
+func shopifyVariantPrice(p ShopifyProduct, v ShopifyVariant) Price {
+	price := NewPrice(int64(v.Price))
+	switch {
+	case !v.Available:
+		price.Availability = Availability_SoldOut
+	case IsPreOrder(p.Title) || IsPreOrder(strings.Join(p.Tags, " ")) || IsPreOrder(v.Title):
+		price.Availability = Availability_PreOrder
+	case v.InventoryManagement != "shopify":
+	case v.InventoryQuantity <= 0 && v.InventoryPolicy == "continue":
+		price.Availability = Availability_Backorder
+	case v.InventoryQuantity > 0:
+		price = price.WithStock(int64(v.InventoryQuantity))
+	}
+	return price
+}
+
+// shopifyPrice derives availability from the product. Pre-orders are flagged by tag or title, available variants
+// that are out of stock but still sellable (inventory policy "continue") are on backorder, and stock levels are
+// only trusted where shopify manages the inventory of every available variant.
+func shopifyPrice(p ShopifyProduct) Price {
+	price := NewPrice(int64(p.Price))
+	if !p.Available {
+		price.Availability = Availability_SoldOut
+		return price
+	}
+	if IsPreOrder(p.Title) || IsPreOrder(strings.Join(p.Tags, " ")) {
+		price.Availability = Availability_PreOrder
+		return price
+	}
+	stock, tracked, backorder := int64(0), len(p.Variants) > 0, false
+	for _, v := range p.Variants {
+		if !v.Available {
+			continue
+		}
+		if v.InventoryManagement != "shopify" {
+			tracked = false
+			continue
+		}
+		if v.InventoryQuantity <= 0 && v.InventoryPolicy == "continue" {
+			backorder = true
+			continue
+		}
+		stock += int64(v.InventoryQuantity)
+	}
+	if backorder && tracked && stock == 0 {
+		price.Availability = Availability_Backorder
+	} else if tracked && stock > 0 {
+		price = price.WithStock(stock)
+	}
+	return price
+}
+
+func isShopifyVinyl(p ShopifyProduct) bool {
+	if shopifyVinylRegex.MatchString(p.Type) || shopifyVinylRegex.MatchString(p.Title) {
+		return true
+	}
+	for _, tag := range p.Tags {
+		if shopifyVinylRegex.MatchString(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func shopifyImageURL(image string) string {
+	if strings.HasPrefix(image, "//") {
+		return "https:" + image
+	}
+	return image
+}
+
+// extractProductHandles returns the distinct product handles linked to from a page, in page order.
+func extractProductHandles(body string) []string {
+	handles := []string{}
+	seen := map[string]struct{}{}
+	for _, match := range shopifyProductLinkRegex.FindAllStringSubmatch(body, -1) {
+		if _, ok := seen[match[1]]; ok {
+			continue
+		}
+		seen[match[1]] = struct{}{}
+		handles = append(handles, match[1])
+	}
+	return handles
+}
+
+// extractEmbeddedJSON returns every json object in the body located by the marker.
+func extractEmbeddedJSON(body string, marker ShopifyJSONMarker) []string {
+	objects := []string{}
+	for {
+		start := 0
+		for _, tok := range marker {
+			idx := strings.Index(body[start:], tok)
+			if idx < 0 {
+				return objects
+			}
+			start += idx + len(tok)
+		}
+		body = body[start:]
+		open := strings.Index(body, "{")
+		if open < 0 {
+			return objects
+		}
+		end := matchingBrace(body[open:])
+		if end < 0 {
+			return objects
+		}
+		objects = append(objects, body[open:open+end+1])
+		body = body[open+end+1:]
+	}
+}
+
+// matchingBrace returns the index of the brace closing the object opened at the start of s, or -1 if the
+// object is not closed. Braces within json strings are ignored.
+func matchingBrace(s string) int {
+	depth := 0
+	inString := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func trimQuotes(s string) string {
+	if len(s) >= 2 && ((s[0] == '\'' && s[len(s)-1] == '\'') || (s[0] == '"' && s[len(s)-1] == '"')) {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,101 @@
+package retailers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+)
+
+const (
+	SHOPIFY_SCRAPER_KEY = "shopify"
+	SHOPIFY_PAGE_PARAM  = "page" // shopify's search paging, unless a store's search app does otherwise
+)
+
+// ShopifyStores configures the shopify scraping engine for each of the shopify record stores we scan, keyed
+// by scraper key. Config stored against the retailer in the database is applied over these defaults.
+var ShopifyStores = map[string]ShopifyStore{
+
+	// https://poisoncityestore.com/search?q=clowns+vinyl. the label's own store, skus are its catalogue numbers (PCR102LP)
+	"poisoncity": {
+		BaseURL:          "https://poisoncityestore.com",
+		SearchPath:       "/search?q=%s+vinyl",
+		ArtistMatch:      ShopifyMatch_TitlePrefix,
+		SkuIsCatalogueNo: true,
+		ProductJSON: []ShopifyJSONMarker{
+			{"new Shopify.OptionSelectors(\"product-select\", { product:"},
+		},
+	},
+
+	// in stock 12" vinyl only. products are embedded in the search results as collection.push({...});
+	"utopia": {
+		BaseURL:     "https://utopia.com.au",
+		SearchPath:  "/search?q=%s+vinyl&_=pf&pf_st_currently_in_stock=true&pf_pt_product_type=New+Vinyl+-+12+Inch",
+		ArtistMatch: ShopifyMatch_VendorContains,
+		TitleTrim:   []string{" - Vinyl - New"},
+		SearchJSON:  ShopifyJSONMarker{"collection.push("},
+	},
+
+	// https://musicfarmers.com/search?q=clowns
+	"musicfarmers": {
+		BaseURL:     "https://musicfarmers.com",
+		SearchPath:  "/search?q=%s",
+		ArtistMatch: ShopifyMatch_Vendor,
+		VinylOnly:   true,
+		ProductJSON: []ShopifyJSONMarker{
+			{"Shopify.OptionSelectors('productSelect'", "product:"},
+		},
+	},
+
+	// titles are "Bossanova – Pixies"
+	"dutchvinyl": {
+		BaseURL:     "https://www.dutchvinyl.com.au",
+		SearchPath:  "/a/search?type=product&q=%s+vinyl",
+		ArtistMatch: ShopifyMatch_TitleSplit,
+		ProductJSON: []ShopifyJSONMarker{
+			{"\"product\": "},
+		},
+	},
+
+	// https://www.ohjeanrecords.com/apps/omega-search/?type=product&options[prefix]=last&q=clowns
+	"ohjeanrecords": {
+		BaseURL:     "https://www.ohjeanrecords.com",
+		SearchPath:  "/apps/omega-search/?type=product&options%%5Bprefix%%5D=last&q=%s",
+		ArtistMatch: ShopifyMatch_VendorPrefix,
+		VinylOnly:   true,
+	},
+
+	// https://grevillerecords.com.au/search?options%5Bprefix%5D=last&page=1&q=pixies+vinyl
+	"grevillerecords": {
+		BaseURL:     "https://grevillerecords.com.au",
+		SearchPath:  "/search?options[prefix]=last&q=%s+vinyl",
+		ArtistMatch: ShopifyMatch_TitleSplit,
+		ProductJSON: []ShopifyJSONMarker{
+			{"\"ProductJson-product-template\""},
+		},
+	},
+}
+
+func init() {
+	for key, store := range ShopifyStores {
+		RegisterScraper(key, shopifyScraper(store))
+	}
+	// any other shopify store, configured entirely from the retailer row
+	RegisterScraper(SHOPIFY_SCRAPER_KEY, shopifyScraper(ShopifyStore{}))
+}
+
+func shopifyScraper(defaults ShopifyStore) ScraperFactory {
+	return func(config json.RawMessage) (VinylRetailer, error) {
+		store := defaults
+		if store.PageParam == "" {
+			store.PageParam = SHOPIFY_PAGE_PARAM
+		}
+		err := configureScraper(&store, config)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse shopify store config")
+		}
+		if store.BaseURL == "" || store.SearchPath == "" {
+			return nil, fmt.Errorf("shopify store config requires a baseUrl and searchPath")
+		}
+		return &store, nil
+	}
+}
@@ -0,0 +1,192 @@
+//go:build unit_test
+// +build unit_test
+
+package retailers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShopify_ProductPageJSON(t *testing.T) {
+	t.Parallel()
+
+	body, err := ioutil.ReadFile("./testdata/poisoncity/poisoncityestore.com_products_clowns_bad_blood_lp.html")
+	require.Nil(t, err, "Failed to read recorded product page")
+
+	store := ShopifyStores["poisoncity"]
+	objects := extractEmbeddedJSON(string(body), store.ProductJSON[0])
+	require.Equal(t, 1, len(objects), "Expected a single product json object in the product page")
+
+	p := ShopifyProduct{}
+	err = json.Unmarshal([]byte(objects[0]), &p)
+	require.Nil(t, err, "Failed to parse embedded product json")
+
+	sku, ok := store.productToSKU("clowns", p)
+	assert.True(t, ok, "Expected product to match artist on title prefix")
+	assert.Equal(t, "'Bad Blood' LP", sku.Name)
+	assert.Equal(t, "https://poisoncityestore.com/products/clowns-bad-blood-lp", sku.Url)
+	assert.Equal(t, "PCR102LP", sku.CatalogueNo, "Expected the label's sku to be its catalogue number")
+
+	other := store
+	other.SkuIsCatalogueNo = false
+	sku, _ = other.productToSKU("clowns", p)
+	assert.Equal(t, "", sku.CatalogueNo, "Expected another store's sku not to be taken as a catalogue number")
+	assert.Equal(t, "0680596876212", sku.Barcode)
+
+	_, ok = store.productToSKU("pixies", p)
+	assert.False(t, ok, "Expected product not to match a different artist")
+}
+
+func TestShopify_SearchPageJSON(t *testing.T) {
+	t.Parallel()
+
+	body, err := ioutil.ReadFile("./testdata/utopia/utopia.com.au_search_q_nofx_vinyl_pf_pf_st_currently_in_stock_true_pf_pt_product_type_new__b86bf2863.html")
+	require.Nil(t, err, "Failed to read recorded search page")
+
+	store := ShopifyStores["utopia"]
+	objects := extractEmbeddedJSON(string(body), store.SearchJSON)
+	require.True(t, len(objects) > 0, "Expected product json objects in the search results")
+
+	for _, jd := range objects {
+		p := ShopifyProduct{}
+		err = json.Unmarshal([]byte(jd), &p)
+		require.Nil(t, err, "Failed to parse embedded product json")
+		sku, ok := store.productToSKU("nofx", p)
+		if !ok {
+			continue
+		}
+		assert.NotContains(t, sku.Name, "Vinyl - New", "Expected retailer noise to be trimmed from title")
+		assert.Equal(t, "nofx", sku.Artist)
+	}
+}
+
+func TestShopify_ProductHandles(t *testing.T) {
+	t.Parallel()
+
+	handles := extractProductHandles(`
+		<a href="/collections/all/products/bad-blood">Bad Blood</a>
+		<a href="/products/bad-blood">Bad Blood</a>
+		<a href="https://store.com/products/bad-blood.oembed">embed</a>
+		<img src="//cdn.shopify.com/s/files/1/products/PCR102.jpg"/>
+		<a href="/products/stunt-clown">Stunt Clown</a>
+		<a href="/products/golden-days?_pos=2&_sid=ed705a933&_ss=r">Golden Days</a>
+	`)
+	assert.Equal(t, []string{"bad-blood", "stunt-clown", "golden-days"}, handles)
+}
+
+func TestShopify_OmegaSearchHandles(t *testing.T) {
+	t.Parallel()
+
+	body, err := ioutil.ReadFile("./testdata/ohjeanrecords/www.ohjeanrecords.com_apps_omega_search_type_product_options_prefix_last_q_clowns.html")
+	require.Nil(t, err, "Failed to read recorded search page")
+
+	store := ShopifyStores["ohjeanrecords"]
+	assert.Equal(t, "https://www.ohjeanrecords.com/apps/omega-search/?type=product&options%5Bprefix%5D=last&q=clowns", store.GetArtistQueryURL("clowns"))
+	handles := extractProductHandles(string(body))
+	assert.Contains(t, handles, "clowns-bad-blood-coloured-vinyl")
+	assert.Contains(t, handles, "clowns-im-not-right-coloured-vinyl")
+}
+
+func TestShopify_TitleSplit(t *testing.T) {
+	t.Parallel()
+
+	store := ShopifyStore{BaseURL: "https://store.com", ArtistMatch: ShopifyMatch_TitleSplit}
+	for title, expected := range map[string]string{
+		"Pixies - Doolittle":         "Doolittle",
+		"Bossanova – Pixies":         "Bossanova",
+		"PIXIES- Surfer Rosa":        "Surfer Rosa",
+		"Pixies – 'Trompe Le Monde'": "Trompe Le Monde",
+	} {
+		sku, ok := store.productToSKU("pixies", ShopifyProduct{Title: title, Available: true, Price: 4400})
+		assert.True(t, ok, "Expected '%s' to match artist", title)
+		assert.Equal(t, expected, sku.Name)
+		assert.Equal(t, "$44.00", sku.Price.String())
+	}
+	_, ok := store.productToSKU("pixies", ShopifyProduct{Title: "Frank Black - Honeycomb"})
+	assert.False(t, ok, "Expected a different artist not to match")
+}
+
+func TestShopify_TitlePrefixCaseMapping(t *testing.T) {
+	t.Parallel()
+
+	store := ShopifyStore{BaseURL: "https://store.com", ArtistMatch: ShopifyMatch_TitlePrefix}
+	for artist, title := range map[string]string{
+		"Größe":   "GRÖẞE 'Bad Blood' LP",
+		"İbrahim": "İbrahim 'Bad Blood' LP",
+		"clowns":  "CLOWNS 'Bad Blood' LP",
+	} {
+		sku, ok := store.productToSKU(artist, ShopifyProduct{Title: title})
+		assert.True(t, ok, "Expected '%s' to match artist %s", title, artist)
+		assert.Equal(t, "'Bad Blood' LP", sku.Name)
+	}
+	_, ok := store.productToSKU("Größer", ShopifyProduct{Title: "GRÖẞE"})
+	assert.False(t, ok, "Expected a title shorter than the artist not to match")
+}
+
+func TestShopify_VinylFilter(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, isShopifyVinyl(ShopifyProduct{Type: "LP"}))
+	assert.True(t, isShopifyVinyl(ShopifyProduct{Type: "New Vinyl - 12 Inch"}))
+	assert.True(t, isShopifyVinyl(ShopifyProduct{Tags: []string{"Format_LP"}}))
+	assert.True(t, isShopifyVinyl(ShopifyProduct{Title: "Everything Sucks 2xLP"}))
+	assert.False(t, isShopifyVinyl(ShopifyProduct{Type: "Gift Card", Title: "Help"}))
+	assert.False(t, isShopifyVinyl(ShopifyProduct{Type: "CD"}))
+}
+
+func TestShopify_RegisteredStoreConfig(t *testing.T) {
+	t.Parallel()
+
+	scraper, err := NewVinylRetailer("poisoncity", json.RawMessage(`{"searchPath": "/search?q=%s", "artistMatch": "vendor"}`))
+	require.Nil(t, err, "Unexpected error configuring a registered shopify store")
+	store := scraper.(*ShopifyStore)
+	assert.Equal(t, "https://poisoncityestore.com/search?q=clowns", store.GetArtistQueryURL("clowns"))
+	assert.Equal(t, ShopifyMatch_Vendor, store.ArtistMatch)
+	assert.Equal(t, ShopifyMatch_TitlePrefix, ShopifyStores["poisoncity"].ArtistMatch, "Expected defaults to be left untouched")
+
+	_, err = NewVinylRetailer(SHOPIFY_SCRAPER_KEY, json.RawMessage(`{}`))
+	assert.NotNil(t, err, "Expected error configuring a generic shopify store with no base url")
+
+	_, err = NewVinylRetailer("poisoncity", json.RawMessage(`{"artistMatch": "nonsense"}`))
+	assert.NotNil(t, err, "Expected error configuring an unknown artist match")
+
+	_, err = NewVinylRetailer("poisoncity", json.RawMessage(`{"fetch": {"robots": "sometimes"}}`))
+	assert.NotNil(t, err, "Expected error configuring an unknown robots policy")
+	assert.Equal(t, RobotsPolicy_Ignore, ConfiguredRobotsPolicy(json.RawMessage(`{"fetch": {"robots": "ignore"}}`)))
+	assert.Equal(t, RobotsPolicy(""), ConfiguredRobotsPolicy(json.RawMessage(`{"searchPath": "/search?q=%s"}`)))
+
+	_, err = NewVinylRetailer("nonsense", nil)
+	assert.NotNil(t, err, "Expected error creating an unregistered scraper")
+}
+
+func TestShopify_Availability(t *testing.T) {
+	t.Parallel()
+
+	tracked := func(quantity int, policy string) ShopifyVariant {
+		return ShopifyVariant{Available: true, InventoryManagement: "shopify", InventoryQuantity: quantity, InventoryPolicy: policy}
+	}
+	assert.Equal(t, Availability_SoldOut, shopifyPrice(ShopifyProduct{Price: 4400}).Availability)
+	assert.Equal(t, Availability_InStock, shopifyPrice(ShopifyProduct{Price: 4400, Available: true}).Availability)
+	assert.Equal(t, Availability_PreOrder, shopifyPrice(ShopifyProduct{Price: 4400, Available: true, Title: "Doolittle (Pre-Order)"}).Availability)
+	assert.Equal(t, Availability_PreOrder, shopifyPrice(ShopifyProduct{Price: 4400, Available: true, Tags: []string{"LP", "preorder"}}).Availability)
+
+	p := shopifyPrice(ShopifyProduct{Price: 4400, Available: true, Variants: []ShopifyVariant{tracked(2, "deny")}})
+	assert.Equal(t, Availability_LowStock, p.Availability)
+	assert.Equal(t, int64(2), p.Stock.Int64)
+	assert.Equal(t, "$44.00 (only 2 left)", p.String())
+
+	p = shopifyPrice(ShopifyProduct{Price: 4400, Available: true, Variants: []ShopifyVariant{tracked(2, "deny"), tracked(20, "deny")}})
+	assert.Equal(t, Availability_InStock, p.Availability)
+	assert.Equal(t, int64(22), p.Stock.Int64)
+
+	p = shopifyPrice(ShopifyProduct{Price: 4400, Available: true, Variants: []ShopifyVariant{tracked(0, "continue")}})
+	assert.Equal(t, Availability_Backorder, p.Availability)
+
+	p = shopifyPrice(ShopifyProduct{Price: 4400, Available: true, Variants: []ShopifyVariant{tracked(1, "deny"), {Available: true}}})
+	assert.Equal(t, Availability_InStock, p.Availability, "Expected stock to be ignored when not every variant is tracked")
+}
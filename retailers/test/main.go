@@ -2,6 +2,9 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"github.com/gavinturner/vinylretailers/retailers"
 	_ "github.com/lib/pq"
@@ -9,24 +12,46 @@ import (
 	"strings"
 )
 
+// Runs a scraper against the live retailer site. The recorded fixture tests in retailers/ are the regression
+// tests, this is for trying out a scraper by hand e.g.
+//
+//	go run ./retailers/test -scraper poisoncity -artist clowns
 func main() {
-	fmt.Printf("Scrape test\n")
-	scraper := retailers.ArtistFirst{}
+	scraperKey := flag.String("scraper", retailers.AF_SCRAPER_KEY, "key of the registered scraper to run")
+	config := flag.String("config", "", "json scraper config, as stored against the retailer (or @file to read it from a file)")
+	artist := flag.String("artist", "nofx", "artist to search for")
+	artistsFile := flag.String("artists", "", "file of artists to search for, one per line (overrides -artist)")
+	flag.Parse()
 
-	// multi artist test
-	readFile, err := os.Open("./retailers/test/artists.txt")
+	fmt.Printf("Scrape test: %s\n", *scraperKey)
+	if strings.HasPrefix(*config, "@") {
+		// e.g. -scraper selector -config @retailers/definitions/strangeworldrecords.json
+		data, err := os.ReadFile(strings.TrimPrefix(*config, "@"))
+		if err != nil {
+			panic(err)
+		}
+		*config = string(data)
+	}
+	scraper, err := retailers.NewVinylRetailer(*scraperKey, json.RawMessage(*config))
 	if err != nil {
 		panic(err)
 	}
-	artists := []string{}
-	fileScanner := bufio.NewScanner(readFile)
-	fileScanner.Split(bufio.ScanLines)
-	for fileScanner.Scan() {
-		artists = append(artists, strings.TrimSpace(strings.ToLower(fileScanner.Text())))
-	}
-	readFile.Close()
 
-	artists = []string{"nofx"}
+	artists := []string{*artist}
+	if *artistsFile != "" {
+		// multi artist test
+		readFile, err := os.Open(*artistsFile)
+		if err != nil {
+			panic(err)
+		}
+		artists = []string{}
+		fileScanner := bufio.NewScanner(readFile)
+		fileScanner.Split(bufio.ScanLines)
+		for fileScanner.Scan() {
+			artists = append(artists, strings.TrimSpace(strings.ToLower(fileScanner.Text())))
+		}
+		readFile.Close()
+	}
 
 	skus := []retailers.SKU{}
 	for _, artist := range artists {
@@ -34,30 +59,16 @@ func main() {
 			continue
 		}
 		fmt.Printf("ARTIST: %s", artist)
-		s, err := scraper.ScrapeArtistReleases(artist)
+		result, err := scraper.ScrapeArtistReleases(context.Background(), artist)
 		if err != nil {
 			fmt.Printf("ERROR: %s", err.Error())
 			os.Exit(1)
 		}
-		skus = append(skus, s...)
-		fmt.Printf(" (%v)\n", len(s))
+		skus = append(skus, result.SKUs...)
+		fmt.Printf(" (%v from %v pages)\n", len(result.SKUs), result.PagesFetched)
 	}
 	for _, s := range skus {
 		fmt.Printf("SKU> %s, %s, (%s)\n", s.Artist, s.Name, s.Price)
+		fmt.Printf("IMAGE: %s\n\n", s.Image)
 	}
-
-	// single artist test
-	/*
-		sskus := []retailers.SKU{}
-		artist := "clowns"
-		fmt.Printf("\nARTIST: %s", artist)
-		s, _ := scraper.ScrapeArtistReleases(artist)
-		sskus = append(sskus, s...)
-		fmt.Printf(" (%v)\n", len(s))
-
-		for _, s := range sskus {
-			fmt.Printf("SKU> [%s], [%s] (%s)\n", s.Artist, s.Name, s.Price)
-			fmt.Printf("IMAGE: %s\n\n", s.Image)
-		}
-	*/
 }
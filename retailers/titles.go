@@ -0,0 +1,104 @@
+package retailers
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+const (
+	TITLE_MATCH_AUTO   = 0.9 // titles at least this similar are the same release
+	TITLE_MATCH_REVIEW = 0.7 // titles at least this similar might be, and are queued for someone to check
+)
+
+var (
+	titleBracketRegex   = regexp.MustCompile(`[(\[{]([^)\]}]*)[)\]}]`)
+	titleSizeRegex      = regexp.MustCompile(`\b(7|10|12)\s*(?:"|”|''|inch\b|in\b)`)
+	titleSeparatorRegex = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+	titleQuoteReplacer  = strings.NewReplacer("'", "", "’", "", "‘", "")
+
+	// words for the format. a title ending in any of these has them dropped
+	titleFormatWords = map[string]struct{}{
+		"lp": {}, "2lp": {}, "3lp": {}, "2xlp": {}, "dlp": {}, "7in": {}, "10in": {}, "12in": {}, "vinyl": {},
+	}
+	// words for the state of the listing. like edition words, a bracketed part of a title containing any of these
+	// (or a format word) is dropped. they're only noise in brackets, as titles can end in them ("Something New")
+	titleListingWords = map[string]struct{}{
+		"new": {}, "sealed": {}, "import": {},
+	}
+)
+
+// NormaliseTitle reduces a release title as listed by a retailer to a key that is the same however the retailer
+// dresses it up, e.g. "Everything Sucks", "'Everything Sucks' LP" and "Everything Sucks (Vinyl - New)" are all
+// "everything sucks". Quotes and punctuation are dropped, and format and edition noise is stripped from brackets
+// and the end of the title. Bracketed parts that aren't noise ("(Live)") are kept.
+func NormaliseTitle(title string) string {
+	title = strings.ToLower(html.UnescapeString(title))
+	title = titleSizeRegex.ReplaceAllString(title, " ${1}in ")
+	title = titleBracketRegex.ReplaceAllStringFunc(title, func(bracketed string) string {
+		if IsPreOrder(bracketed) {
+			return " "
+		}
+		for _, word := range titleWords(bracketed) {
+			_, format := titleFormatWords[word]
+			_, edition := editionWords[word]
+			_, listing := titleListingWords[word]
+			if format || edition || listing {
+				return " "
+			}
+		}
+		return " " + bracketed + " "
+	})
+	words := titleWords(title)
+	for len(words) > 1 {
+		if _, ok := titleFormatWords[words[len(words)-1]]; !ok {
+			break
+		}
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, " ")
+}
+
+func titleWords(title string) []string {
+	title = titleQuoteReplacer.Replace(title)
+	return strings.Fields(titleSeparatorRegex.ReplaceAllString(title, " "))
+}
+
+// TitleSimilarity scores how alike two normalised titles are, from 0 (nothing in common) to 1 (the same). It is
+// the dice coefficient of the titles' letter pairs, so it tolerates typos, spelling variations and words run
+// together, but not a different volume or part number.
+func TitleSimilarity(a string, b string) float64 {
+	if a == b {
+		return 1
+	}
+	pairsA, pairsB := titlePairs(a), titlePairs(b)
+	if len(pairsA) == 0 || len(pairsB) == 0 {
+		return 0
+	}
+	counts := map[string]int{}
+	for _, pair := range pairsA {
+		counts[pair]++
+	}
+	shared := 0
+	for _, pair := range pairsB {
+		if counts[pair] > 0 {
+			counts[pair]--
+			shared++
+		}
+	}
+	return float64(2*shared) / float64(len(pairsA)+len(pairsB))
+}
+
+// titlePairs returns the pairs of adjacent characters in the title, padded with a space at each end so that the
+// first and last letters (and single character words, like volume numbers) count as much as the rest.
+func titlePairs(title string) []string {
+	pairs := []string{}
+	if title == "" {
+		return pairs
+	}
+	runes := []rune(" " + title + " ")
+	for i := 0; i+1 < len(runes); i++ {
+		pairs = append(pairs, string(runes[i:i+2]))
+	}
+	return pairs
+}
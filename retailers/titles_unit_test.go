@@ -0,0 +1,40 @@
+//go:build unit_test
+// +build unit_test
+
+package retailers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTitles_Normalise(t *testing.T) {
+	t.Parallel()
+
+	for title, expected := range map[string]string{
+		"Everything Sucks":                           "everything sucks",
+		"'Everything Sucks' LP":                      "everything sucks",
+		"Everything Sucks (Vinyl - New)":             "everything sucks",
+		"EVERYTHING SUCKS [Limited Pink Vinyl] 12\"": "everything sucks",
+		"Everything Sucks - 2xLP (Pre-Order)":        "everything sucks",
+		"Don’t Want To Go Home 7&#34;":               "dont want to go home",
+		"Live At The Tote (Live)":                    "live at the tote live",
+		"Something New":                              "something new",
+		"LP":                                         "lp",
+	} {
+		assert.Equal(t, expected, NormaliseTitle(title), "Normalising '%s'", title)
+	}
+}
+
+func TestTitles_Similarity(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 1.0, TitleSimilarity("everything sucks", "everything sucks"))
+	assert.True(t, TitleSimilarity("everything sucks", "everythng sucks") >= TITLE_MATCH_AUTO, "Expected a typo to match")
+	review := TitleSimilarity("bad blood", "bad blood live")
+	assert.True(t, review >= TITLE_MATCH_REVIEW && review < TITLE_MATCH_AUTO, "Expected a live album to need review (%v)", review)
+	assert.True(t, TitleSimilarity("volume 1", "volume 2") < TITLE_MATCH_AUTO, "Expected numbered volumes to need review")
+	assert.True(t, TitleSimilarity("bad blood", "lucid again") < TITLE_MATCH_REVIEW)
+	assert.Equal(t, 0.0, TitleSimilarity("", "lucid again"))
+}
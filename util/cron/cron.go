@@ -0,0 +1,217 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression: minute, hour, day of month, month and day of week, e.g. "*/30 * * * *" or
+// "0 9-17 * * mon-fri". Fields can be *, a value, a range (a-b), a step (*/n or a-b/n) or a list of these. Months
+// and days of the week can be given by name, and Sunday is 0 or 7. The @hourly, @daily, @weekly and @monthly
+// shorthands are understood too.
+type Schedule struct {
+	minute     []bool
+	hour       []bool
+	dayOfMonth []bool
+	month      []bool
+	dayOfWeek  []bool
+	// as in cron, a day matches if either of day of month or day of week does, when both are restricted
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+// field is the range of values a field of the expression can take, and the names it accepts for them
+type field struct {
+	name  string
+	min   int
+	max   int
+	names []string // names of the values from min
+}
+
+var (
+	minuteField     = field{name: "minute", min: 0, max: 59}
+	hourField       = field{name: "hour", min: 0, max: 23}
+	dayOfMonthField = field{name: "day of month", min: 1, max: 31}
+	monthField      = field{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	dayOfWeekField  = field{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+
+	shorthands = map[string]string{
+		"@hourly":  "0 * * * *",
+		"@daily":   "0 0 * * *",
+		"@weekly":  "0 0 * * 0",
+		"@monthly": "0 0 1 * *",
+	}
+)
+
+// Parse parses a five field cron expression.
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(strings.ToLower(expr))
+	if full, ok := shorthands[expr]; ok {
+		expr = full
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression '%s' should have 5 fields, not %v", expr, len(fields))
+	}
+	s := &Schedule{
+		anyDayOfMonth: fields[2] == "*",
+		anyDayOfWeek:  fields[4] == "*",
+	}
+	var err error
+	for _, f := range []struct {
+		values *[]bool
+		field  field
+		expr   string
+	}{
+		{&s.minute, minuteField, fields[0]},
+		{&s.hour, hourField, fields[1]},
+		{&s.dayOfMonth, dayOfMonthField, fields[2]},
+		{&s.month, monthField, fields[3]},
+		{&s.dayOfWeek, dayOfWeekField, fields[4]},
+	} {
+		*f.values, err = f.field.parse(f.expr)
+		if err != nil {
+			return nil, fmt.Errorf("cron expression '%s' has an invalid %s: %s", expr, f.field.name, err.Error())
+		}
+	}
+	// sunday is both 0 and 7
+	s.dayOfWeek[0] = s.dayOfWeek[0] || s.dayOfWeek[7]
+	return s, nil
+}
+
+// parse returns which of the field's values the expression matches, indexed by value
+func (f field) parse(expr string) ([]bool, error) {
+	values := make([]bool, f.max+1)
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rangeExpr = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("bad step in '%s'", part)
+			}
+		}
+		from, to := f.min, f.max
+		if rangeExpr != "*" {
+			bounds := strings.SplitN(rangeExpr, "-", 2)
+			var err error
+			from, err = f.value(bounds[0])
+			if err != nil {
+				return nil, err
+			}
+			to = from
+			if len(bounds) == 2 {
+				to, err = f.value(bounds[1])
+				if err != nil {
+					return nil, err
+				}
+			} else if step > 1 {
+				// a/n runs from a to the end of the range
+				to = f.max
+			}
+			if to < from {
+				return nil, fmt.Errorf("backwards range '%s'", rangeExpr)
+			}
+		}
+		for v := from; v <= to; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+func (f field) value(expr string) (int, error) {
+	for i, name := range f.names {
+		if expr == name {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(expr)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("'%s' is not between %v and %v", expr, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next returns the first time after the given one that the schedule fires, in the given time's location, or the
+// zero time if it never does (e.g. "0 0 31 2 *").
+func (s *Schedule) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := time.Date(after.Year(), after.Month(), after.Day(), after.Hour(), after.Minute(), 0, 0, loc).Add(time.Minute)
+	limit := t.Year() + 5
+	for t.Year() <= limit {
+		if !s.month[t.Month()] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.hour[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if !s.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom, dow := s.dayOfMonth[t.Day()], s.dayOfWeek[t.Weekday()]
+	if s.anyDayOfMonth || s.anyDayOfWeek {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// QuietHours is a time of day range (in the location of the times it's given) that a schedule shouldn't fire in. It
+// wraps past midnight if it ends before it starts, e.g. 22:00 to 07:00.
+type QuietHours struct {
+	start int // minutes into the day
+	end   int
+}
+
+// ParseQuietHours parses a range of HH:MM times of day (seconds are ignored). It starts at start and ends before end.
+func ParseQuietHours(start string, end string) (QuietHours, error) {
+	q := QuietHours{}
+	var err error
+	q.start, err = minuteOfDay(start)
+	if err != nil {
+		return q, err
+	}
+	q.end, err = minuteOfDay(end)
+	return q, err
+}
+
+func minuteOfDay(clock string) (int, error) {
+	parts := strings.Split(strings.TrimSpace(clock), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("time of day '%s' should be HH:MM", clock)
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, fmt.Errorf("time of day '%s' has a bad hour", clock)
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("time of day '%s' has a bad minute", clock)
+	}
+	return hour*60 + minute, nil
+}
+
+// Contains returns true if the time is within the quiet hours.
+func (q QuietHours) Contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if q.start <= q.end {
+		return m >= q.start && m < q.end
+	}
+	return m >= q.start || m < q.end
+}
@@ -0,0 +1,82 @@
+//go:build unit_test
+// +build unit_test
+
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCron_Next(t *testing.T) {
+	t.Parallel()
+
+	// a wednesday
+	from := time.Date(2022, 8, 31, 10, 17, 30, 0, time.UTC)
+	for expr, expected := range map[string]time.Time{
+		"*/30 * * * *":       time.Date(2022, 8, 31, 10, 30, 0, 0, time.UTC),
+		"17 * * * *":         time.Date(2022, 8, 31, 11, 17, 0, 0, time.UTC),
+		"@hourly":            time.Date(2022, 8, 31, 11, 0, 0, 0, time.UTC),
+		"@daily":             time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC),
+		"0 9-17/4 * * *":     time.Date(2022, 8, 31, 13, 0, 0, 0, time.UTC),
+		"0 6 * * sat,sun":    time.Date(2022, 9, 3, 6, 0, 0, 0, time.UTC),
+		"0 6 * * 7":          time.Date(2022, 9, 4, 6, 0, 0, 0, time.UTC),
+		"0 0 1 jan *":        time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":         time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		"0 0 15 * mon":       time.Date(2022, 9, 5, 0, 0, 0, 0, time.UTC), // either day of month or day of week
+		"0 0 31 2 *":         {},
+		"5,10 10 31 aug wed": time.Date(2023, 8, 2, 10, 5, 0, 0, time.UTC), // the 31st, or a wednesday, in august
+	} {
+		schedule, err := Parse(expr)
+		require.NoError(t, err, "Parsing '%s'", expr)
+		assert.Equal(t, expected, schedule.Next(from), "Next run of '%s'", expr)
+	}
+}
+
+func TestCron_Next_Location(t *testing.T) {
+	t.Parallel()
+
+	melbourne, err := time.LoadLocation("Australia/Melbourne")
+	require.NoError(t, err)
+	schedule, err := Parse("0 9 * * *")
+	require.NoError(t, err)
+	next := schedule.Next(time.Date(2022, 8, 31, 0, 0, 0, 0, time.UTC).In(melbourne))
+	assert.Equal(t, time.Date(2022, 8, 31, 23, 0, 0, 0, time.UTC), next.UTC())
+}
+
+func TestCron_ParseErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "*/0 * * * *", "5-1 * * * *", "* * * * funday"} {
+		_, err := Parse(expr)
+		assert.Error(t, err, "Parsing '%s'", expr)
+	}
+}
+
+func TestCron_QuietHours(t *testing.T) {
+	t.Parallel()
+
+	overnight, err := ParseQuietHours("22:00", "07:00")
+	require.NoError(t, err)
+	lunch, err := ParseQuietHours("12:00:00", "13:30:00")
+	require.NoError(t, err)
+	for clock, expected := range map[string][2]bool{
+		"21:59": {false, false},
+		"22:00": {true, false},
+		"03:00": {true, false},
+		"07:00": {false, false},
+		"12:00": {false, true},
+		"13:29": {false, true},
+		"13:30": {false, false},
+	} {
+		at, _ := time.Parse("15:04", clock)
+		assert.Equal(t, expected[0], overnight.Contains(at), "Overnight quiet hours at %s", clock)
+		assert.Equal(t, expected[1], lunch.Contains(at), "Lunch quiet hours at %s", clock)
+	}
+
+	_, err = ParseQuietHours("25:00", "07:00")
+	assert.Error(t, err)
+}
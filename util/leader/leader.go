@@ -0,0 +1,193 @@
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/gavinturner/vinylretailers/util/log"
+	"github.com/gavinturner/vinylretailers/util/postgres"
+	"github.com/pkg/errors"
+	"hash/fnv"
+	"os"
+	"time"
+)
+
+const (
+	DEFAULT_HEARTBEAT_SECS = 10
+	DEFAULT_LEASE_SECS     = 30
+)
+
+// Options control how quickly leadership changes hands. Zero values are the defaults.
+type Options struct {
+	Heartbeat time.Duration // how often the leader checks it still holds the lock, and standbys try to take it
+	Lease     time.Duration // how long the leader's session can go without a heartbeat before postgres ends it
+}
+
+// WithDefaults returns the options with any that aren't set defaulted.
+func (o Options) WithDefaults() Options {
+	if o.Heartbeat <= 0 {
+		o.Heartbeat = time.Duration(DEFAULT_HEARTBEAT_SECS) * time.Second
+	}
+	if o.Lease <= o.Heartbeat {
+		o.Lease = time.Duration(DEFAULT_LEASE_SECS) * time.Second
+		if o.Lease <= o.Heartbeat {
+			o.Lease = 3 * o.Heartbeat
+		}
+	}
+	return o
+}
+
+// Elector elects one of the processes campaigning under the same name as leader, by which of them holds a postgres
+// session level advisory lock. The leader holds the lock on a connection of its own, which is named for it (so it
+// shows in pg_stat_activity), and heartbeats on it. If the leader exits or its connection drops postgres releases the
+// lock straight away, and if its host dies the session is ended once it has gone a lease without a heartbeat (on
+// postgres 14 and later, otherwise it's down to tcp keepalives). A standby takes over within a heartbeat after that.
+type Elector struct {
+	db       *postgres.DB
+	name     string
+	key      int64
+	identity string
+	options  Options
+}
+
+// Status is who leads an election.
+type Status struct {
+	Identity    string    `db:"identity"`
+	Client      string    `db:"client"`
+	ConnectedAt time.Time `db:"connected_at"` // when the leader's connection was opened
+}
+
+// NewElector campaigns for the named leadership as this host and process.
+func NewElector(db *postgres.DB, name string, options Options) *Elector {
+	hostname, _ := os.Hostname()
+	return &Elector{
+		db:       db,
+		name:     name,
+		key:      lockKey(name),
+		identity: fmt.Sprintf("%s %s-%v", name, hostname, os.Getpid()),
+		options:  options.WithDefaults(),
+	}
+}
+
+// lockKey is the advisory lock for the name. it fits in 32 bits, so it's the objid of the lock in pg_locks
+func lockKey(name string) int64 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	return int64(h.Sum32())
+}
+
+// Run campaigns for leadership until the context is cancelled, calling lead whenever this process becomes leader.
+// lead's context is cancelled when leadership is lost, and it must return promptly when it is, as another process
+// may take over.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	leader := ""
+	for ctx.Err() == nil {
+		conn, err := e.acquire(ctx)
+		if conn != nil {
+			log.Infof("%s is now leader", e.identity)
+			e.lead(ctx, conn, lead)
+			log.Infof("%s is no longer leader", e.identity)
+			leader = ""
+			continue
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error(err, "Failed to campaign for %s leader", e.name)
+			}
+		} else if status, err := Leader(e.db, e.name); err == nil && status != nil && status.Identity != leader {
+			leader = status.Identity
+			log.Infof("%s standing by: %s is leader (connected from %s since %v)", e.identity, status.Identity, status.Client, status.ConnectedAt)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(e.options.Heartbeat):
+		}
+	}
+}
+
+// acquire tries to take the lock on a new connection, returning the connection if it did and nil if another process
+// holds it.
+func (e *Elector) acquire(ctx context.Context) (*sql.Conn, error) {
+	conn, err := e.db.Conn(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get connection")
+	}
+	acquired := false
+	err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, e.key).Scan(&acquired)
+	if err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrapf(err, "failed to try lock %v", e.key)
+	} else if !acquired {
+		_ = conn.Close()
+		return nil, nil
+	}
+	_, err = conn.ExecContext(ctx, `SELECT set_config('application_name', $1, false)`, e.identity)
+	if err != nil {
+		e.release(conn)
+		return nil, errors.Wrapf(err, "failed to name leader connection")
+	}
+	_, err = conn.ExecContext(ctx, fmt.Sprintf(`SET idle_session_timeout = %v`, e.options.Lease.Milliseconds()))
+	if err != nil {
+		log.Warnf("Failed to set a lease on %s leadership, so a dead leader's host may hold it until its connection times out: %s", e.name, err.Error())
+	}
+	return conn, nil
+}
+
+// lead runs lead until leadership is lost, heartbeating on the connection holding the lock, then releases it.
+func (e *Elector) lead(ctx context.Context, conn *sql.Conn, lead func(ctx context.Context)) {
+	leadCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lead(leadCtx)
+	}()
+	ticker := time.NewTicker(e.options.Heartbeat)
+	for leading := true; leading; {
+		select {
+		case <-leadCtx.Done():
+			leading = false
+		case <-done:
+			leading = false
+		case <-ticker.C:
+			// a session postgres has ended (or that has dropped) no longer holds the lock
+			heartbeatCtx, cancelHeartbeat := context.WithTimeout(ctx, e.options.Heartbeat)
+			_, err := conn.ExecContext(heartbeatCtx, `SELECT 1`)
+			cancelHeartbeat()
+			if err != nil && ctx.Err() == nil {
+				log.Error(err, "%s lost its connection, so is stepping down", e.identity)
+				leading = false
+			}
+		}
+	}
+	ticker.Stop()
+	cancel()
+	<-done
+	e.release(conn)
+}
+
+// release unlocks the lock, and resets the connection's name and lease before it goes back in the pool
+func (e *Elector) release(conn *sql.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.options.Heartbeat)
+	defer cancel()
+	_, _ = conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, e.key)
+	_, _ = conn.ExecContext(ctx, `RESET ALL`)
+	_ = conn.Close()
+}
+
+// Leader returns who leads the named election, or nil if no one does.
+func Leader(db *postgres.DB, name string) (*Status, error) {
+	statuses := []Status{}
+	err := db.Select(&statuses, db.Rebind(`
+		SELECT a.application_name AS identity, COALESCE(HOST(a.client_addr), 'local') AS client, a.backend_start AS connected_at
+		FROM pg_locks l
+		JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.locktype = 'advisory' AND l.granted AND l.classid = 0 AND l.objid::TEXT::BIGINT = ? AND l.objsubid = 1
+	`), lockKey(name))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find %s leader", name)
+	}
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+	return &statuses[0], nil
+}
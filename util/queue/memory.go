@@ -0,0 +1,233 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemoryQueue is an in-process Queue, for unit tests and local runs of the scanner (which then runs the scheduler
+// too, as nothing else can queue to it). Its entries are lost when the process exits. Consumers blocked in Dequeue
+// are woken through a channel that is closed (and replaced) whenever an entry is queued.
+type MemoryQueue struct {
+	mutex    sync.Mutex
+	options  Options
+	priority []*memoryEntry
+	normal   []*memoryEntry
+	inflight map[string]*memoryEntry
+	dead     []*memoryEntry
+	queued   chan struct{}
+	nextID   int64
+	closed   bool
+}
+
+// memoryEntry is an entry and its deliveries so far
+type memoryEntry struct {
+	id       string
+	data     []byte
+	priority bool
+	attempts int
+	deadline time.Time // when an inflight entry is redelivered if it hasn't been acked
+}
+
+func NewMemoryQueue(options Options) *MemoryQueue {
+	return &MemoryQueue{
+		options:  options.WithDefaults(),
+		inflight: map[string]*memoryEntry{},
+		queued:   make(chan struct{}),
+	}
+}
+
+func (q *MemoryQueue) Enqueue(payload any) error {
+	return q.enqueue(payload, false)
+}
+
+func (q *MemoryQueue) EnqueuePriority(payload any) error {
+	return q.enqueue(payload, true)
+}
+
+func (q *MemoryQueue) enqueue(payload any, priority bool) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal payload")
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.closed {
+		return errors.New("queue is closed")
+	}
+	q.nextID++
+	q.push(&memoryEntry{id: strconv.FormatInt(q.nextID, 10), data: data, priority: priority})
+	return nil
+}
+
+func (q *MemoryQueue) Dequeue(ctx context.Context, payload any, blocking bool) (*Delivery, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		q.mutex.Lock()
+		if q.closed {
+			q.mutex.Unlock()
+			return nil, errors.New("queue is closed")
+		}
+		expired := q.requeueExpired()
+		var delivery *Delivery
+		entry := q.pop()
+		if entry != nil {
+			entry.attempts++
+			entry.deadline = time.Now().Add(q.options.VisibilityTimeout)
+			q.inflight[entry.id] = entry
+			delivery = &Delivery{ID: entry.id, Attempt: entry.attempts, Handle: entry}
+		}
+		queued := q.queued
+		wait := q.untilNextDeadline()
+		q.mutex.Unlock()
+		for _, entry := range expired {
+			q.options.DeadLetteredOnExpiry(entry.data)
+		}
+
+		if delivery != nil {
+			if err := json.Unmarshal(entry.data, payload); err != nil {
+				q.mutex.Lock()
+				delete(q.inflight, entry.id)
+				q.dead = append(q.dead, entry)
+				q.mutex.Unlock()
+				return nil, errors.Wrapf(err, "failed to unmarshal payload")
+			}
+			return delivery, nil
+		}
+		if !blocking {
+			return nil, nil
+		}
+		// wait for an entry to be queued, or for an inflight one to expire
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+		case <-queued:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+func (q *MemoryQueue) Ack(delivery *Delivery) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if !q.holds(delivery) {
+		return ErrDeliveryExpired
+	}
+	delete(q.inflight, delivery.ID)
+	return nil
+}
+
+func (q *MemoryQueue) Nack(delivery *Delivery, delay time.Duration) (deadLettered bool, err error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if !q.holds(delivery) {
+		return false, ErrDeliveryExpired
+	}
+	entry := q.inflight[delivery.ID]
+	if entry.attempts >= q.options.MaxAttempts {
+		delete(q.inflight, delivery.ID)
+		q.dead = append(q.dead, entry)
+		return true, nil
+	}
+	if delay > 0 {
+		// it stays inflight until the delay has passed, when it is requeued as if it had expired
+		entry.deadline = time.Now().Add(delay)
+		return false, nil
+	}
+	delete(q.inflight, delivery.ID)
+	q.push(entry)
+	return false, nil
+}
+
+func (q *MemoryQueue) QueueLength() (int64, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return int64(len(q.priority) + len(q.normal)), nil
+}
+
+func (q *MemoryQueue) DeadLetterLength() (int64, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return int64(len(q.dead)), nil
+}
+
+// Close wakes any blocked consumers, which then fail, as does anything else done with the queue.
+func (q *MemoryQueue) Close() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.queued)
+	}
+	return nil
+}
+
+// holds is whether the delivery is still inflight, i.e. it hasn't expired and been redelivered (or dead lettered)
+func (q *MemoryQueue) holds(delivery *Delivery) bool {
+	entry, ok := q.inflight[delivery.ID]
+	return ok && entry.attempts == delivery.Attempt
+}
+
+// push adds an entry to the back of its lane, waking blocked consumers. The mutex must be held.
+func (q *MemoryQueue) push(entry *memoryEntry) {
+	if entry.priority {
+		q.priority = append(q.priority, entry)
+	} else {
+		q.normal = append(q.normal, entry)
+	}
+	if !q.closed {
+		close(q.queued)
+		q.queued = make(chan struct{})
+	}
+}
+
+// pop takes the entry at the front of the priority lane, or failing that the queue. The mutex must be held.
+func (q *MemoryQueue) pop() *memoryEntry {
+	var entry *memoryEntry
+	if len(q.priority) > 0 {
+		entry, q.priority = q.priority[0], q.priority[1:]
+	} else if len(q.normal) > 0 {
+		entry, q.normal = q.normal[0], q.normal[1:]
+	}
+	return entry
+}
+
+// requeueExpired requeues entries that weren't acked within the visibility timeout, dead lettering (and returning)
+// those that have been delivered the maximum number of times. The mutex must be held.
+func (q *MemoryQueue) requeueExpired() []*memoryEntry {
+	now := time.Now()
+	dead := []*memoryEntry{}
+	for id, entry := range q.inflight {
+		if now.Before(entry.deadline) {
+			continue
+		}
+		delete(q.inflight, id)
+		if entry.attempts >= q.options.MaxAttempts {
+			q.dead = append(q.dead, entry)
+			dead = append(dead, entry)
+		} else {
+			q.push(entry)
+		}
+	}
+	return dead
+}
+
+// untilNextDeadline is how long until the next inflight entry expires, or the visibility timeout if none are
+// inflight. The mutex must be held.
+func (q *MemoryQueue) untilNextDeadline() time.Duration {
+	wait := q.options.VisibilityTimeout
+	now := time.Now()
+	for _, entry := range q.inflight {
+		if until := entry.deadline.Sub(now); until < wait {
+			wait = until
+		}
+	}
+	return wait
+}
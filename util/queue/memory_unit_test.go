@@ -0,0 +1,163 @@
+//go:build unit_test
+// +build unit_test
+
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPayload struct {
+	N int
+}
+
+func TestMemoryQueue_PriorityThenFIFO(t *testing.T) {
+	t.Parallel()
+
+	q := NewMemoryQueue(Options{})
+	require.NoError(t, q.Enqueue(testPayload{N: 1}))
+	require.NoError(t, q.Enqueue(testPayload{N: 2}))
+	require.NoError(t, q.EnqueuePriority(testPayload{N: 3}))
+	length, _ := q.QueueLength()
+	assert.Equal(t, int64(3), length)
+
+	for _, expected := range []int{3, 1, 2} {
+		payload := testPayload{}
+		delivery, err := q.Dequeue(context.Background(), &payload, false)
+		require.NoError(t, err)
+		require.NotNil(t, delivery)
+		assert.Equal(t, expected, payload.N)
+		assert.Equal(t, 1, delivery.Attempt)
+		assert.NoError(t, q.Ack(delivery))
+	}
+	delivery, err := q.Dequeue(context.Background(), &testPayload{}, false)
+	assert.NoError(t, err)
+	assert.Nil(t, delivery)
+}
+
+func TestMemoryQueue_NackRetriesThenDeadLetters(t *testing.T) {
+	t.Parallel()
+
+	q := NewMemoryQueue(Options{MaxAttempts: 2})
+	require.NoError(t, q.Enqueue(testPayload{N: 1}))
+	require.NoError(t, q.Enqueue(testPayload{N: 2}))
+
+	// a nacked entry goes to the back of the queue
+	payload := testPayload{}
+	delivery, err := q.Dequeue(context.Background(), &payload, false)
+	require.NoError(t, err)
+	deadLettered, err := q.Nack(delivery, 0)
+	require.NoError(t, err)
+	assert.False(t, deadLettered)
+	delivery, _ = q.Dequeue(context.Background(), &payload, false)
+	assert.Equal(t, 2, payload.N)
+	require.NoError(t, q.Ack(delivery))
+
+	delivery, _ = q.Dequeue(context.Background(), &payload, false)
+	assert.Equal(t, 1, payload.N)
+	assert.Equal(t, 2, delivery.Attempt)
+	deadLettered, err = q.Nack(delivery, 0)
+	require.NoError(t, err)
+	assert.True(t, deadLettered)
+	length, _ := q.QueueLength()
+	assert.Equal(t, int64(0), length)
+	dead, _ := q.DeadLetterLength()
+	assert.Equal(t, int64(1), dead)
+}
+
+func TestMemoryQueue_RedeliversAfterVisibilityTimeout(t *testing.T) {
+	t.Parallel()
+
+	q := NewMemoryQueue(Options{VisibilityTimeout: 20 * time.Millisecond})
+	require.NoError(t, q.Enqueue(testPayload{N: 1}))
+	first, err := q.Dequeue(context.Background(), &testPayload{}, false)
+	require.NoError(t, err)
+
+	// a blocking dequeue waits for the first delivery to expire
+	second, err := q.Dequeue(context.Background(), &testPayload{}, true)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+	assert.Equal(t, 2, second.Attempt)
+	assert.Equal(t, ErrDeliveryExpired, q.Ack(first))
+	assert.NoError(t, q.Ack(second))
+}
+
+func TestMemoryQueue_BlockingDequeue(t *testing.T) {
+	t.Parallel()
+
+	q := NewMemoryQueue(Options{})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = q.Enqueue(testPayload{N: 1})
+	}()
+	payload := testPayload{}
+	delivery, err := q.Dequeue(context.Background(), &payload, true)
+	require.NoError(t, err)
+	require.NotNil(t, delivery)
+	assert.Equal(t, 1, payload.N)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	delivery, err = q.Dequeue(ctx, &payload, true)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Nil(t, delivery)
+}
+
+func TestMemoryQueue_NackWithDelay(t *testing.T) {
+	t.Parallel()
+
+	q := NewMemoryQueue(Options{})
+	require.NoError(t, q.Enqueue(testPayload{N: 1}))
+	delivery, err := q.Dequeue(context.Background(), &testPayload{}, false)
+	require.NoError(t, err)
+	deadLettered, err := q.Nack(delivery, 20*time.Millisecond)
+	require.NoError(t, err)
+	assert.False(t, deadLettered)
+
+	// it isn't redelivered until the delay has passed
+	delivery, err = q.Dequeue(context.Background(), &testPayload{}, false)
+	require.NoError(t, err)
+	assert.Nil(t, delivery)
+	started := time.Now()
+	delivery, err = q.Dequeue(context.Background(), &testPayload{}, true)
+	require.NoError(t, err)
+	require.NotNil(t, delivery)
+	assert.Equal(t, 2, delivery.Attempt)
+	assert.GreaterOrEqual(t, time.Since(started), 10*time.Millisecond)
+}
+
+func TestMemoryQueue_ExpiredDeadLetter(t *testing.T) {
+	t.Parallel()
+
+	expired := make(chan testPayload, 2)
+	q := NewMemoryQueue(Options{VisibilityTimeout: 20 * time.Millisecond, MaxAttempts: 1, ExpiredDeadLetter: func(data json.RawMessage) {
+		payload := testPayload{}
+		assert.NoError(t, json.Unmarshal(data, &payload))
+		expired <- payload
+	}})
+	require.NoError(t, q.Enqueue(testPayload{N: 1}))
+	delivery, err := q.Dequeue(context.Background(), &testPayload{}, false)
+	require.NoError(t, err)
+	require.NotNil(t, delivery)
+
+	// the next dequeue once the delivery has expired dead letters the entry, rather than redelivering it
+	time.Sleep(30 * time.Millisecond)
+	delivery, err = q.Dequeue(context.Background(), &testPayload{}, false)
+	require.NoError(t, err)
+	assert.Nil(t, delivery)
+	dead, _ := q.DeadLetterLength()
+	assert.Equal(t, int64(1), dead)
+	require.Equal(t, 1, len(expired))
+	assert.Equal(t, 1, (<-expired).N)
+
+	// it's only passed to the hook once
+	_, err = q.Dequeue(context.Background(), &testPayload{}, false)
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(expired))
+}
@@ -0,0 +1,183 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/gavinturner/vinylretailers/util/postgres"
+	"github.com/pkg/errors"
+	"strconv"
+	"time"
+)
+
+const (
+	POSTGRES_POLL_MILLIS = 1000 // how often a blocking dequeue looks for an entry
+)
+
+// PostgresQueue is a Queue kept in the queue_entries table, for deployments without redis. Consumers take entries
+// with SELECT ... FOR UPDATE SKIP LOCKED, so each is delivered to one of them without them waiting on each other. A
+// delivered entry stays in the table, hidden until its visibility timeout passes, and is deleted when it is acked.
+// Postgres can't block a consumer until an entry is queued, so a blocking dequeue polls.
+type PostgresQueue struct {
+	db      *postgres.DB
+	name    string
+	options Options
+}
+
+// postgresEntry is a row of queue_entries, as dequeued
+type postgresEntry struct {
+	ID       int64  `db:"id"`
+	Payload  []byte `db:"payload"`
+	Attempts int    `db:"attempts"`
+}
+
+// NewPostgresQueue returns the named queue in the db's queue_entries table. The queue closes the db when it is closed.
+func NewPostgresQueue(db *postgres.DB, queueName string, options Options) *PostgresQueue {
+	return &PostgresQueue{
+		db:      db,
+		name:    queueName,
+		options: options.WithDefaults(),
+	}
+}
+
+func (q *PostgresQueue) Enqueue(payload any) error {
+	return q.enqueue(payload, false)
+}
+
+func (q *PostgresQueue) EnqueuePriority(payload any) error {
+	return q.enqueue(payload, true)
+}
+
+func (q *PostgresQueue) enqueue(payload any, priority bool) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal payload")
+	}
+	_, err = q.db.Exec(q.db.Rebind(`
+		INSERT INTO queue_entries (queue, priority, payload) VALUES (?, ?, ?)
+	`), q.name, priority, string(data))
+	if err != nil {
+		return errors.Wrapf(err, "failed to add entry to queue %v", q.name)
+	}
+	return nil
+}
+
+func (q *PostgresQueue) Dequeue(ctx context.Context, payload any, blocking bool) (*Delivery, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		delivery, err := q.dequeue(payload)
+		if err != nil || delivery != nil || !blocking {
+			return delivery, err
+		}
+		timer := time.NewTimer(time.Duration(POSTGRES_POLL_MILLIS) * time.Millisecond)
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+func (q *PostgresQueue) dequeue(payload any) (*Delivery, error) {
+	// entries that weren't acked within the visibility timeout and have been delivered the maximum number of times
+	// are dead lettered, rather than redelivered. the update locks them, so each is only dead lettered by one consumer
+	expired := [][]byte{}
+	err := q.db.Select(&expired, q.db.Rebind(`
+		UPDATE queue_entries SET dead_at = NOW()
+		WHERE queue = ? AND dead_at IS NULL AND attempts >= ? AND visible_at <= NOW()
+		RETURNING payload
+	`), q.name, q.options.MaxAttempts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to dead letter expired entries of queue %v", q.name)
+	}
+	for _, data := range expired {
+		q.options.DeadLetteredOnExpiry(data)
+	}
+	entry := postgresEntry{}
+	err = q.db.Get(&entry, q.db.Rebind(`
+		UPDATE queue_entries SET attempts = attempts + 1, visible_at = NOW() + ?::float8 * INTERVAL '1 millisecond'
+		WHERE id = (
+			SELECT id FROM queue_entries
+			WHERE queue = ? AND dead_at IS NULL AND visible_at <= NOW()
+			ORDER BY priority DESC, queued_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, payload, attempts
+	`), q.options.VisibilityTimeout.Milliseconds(), q.name)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to dequeue from queue %v", q.name)
+	}
+	delivery := &Delivery{ID: strconv.FormatInt(entry.ID, 10), Attempt: entry.Attempts, Handle: entry.ID}
+	err = json.Unmarshal(entry.Payload, payload)
+	if err != nil {
+		// it will never unmarshal, so don't leave it to be redelivered
+		_, _ = q.db.Exec(q.db.Rebind(`UPDATE queue_entries SET dead_at = NOW() WHERE id = ?`), entry.ID)
+		return nil, errors.Wrapf(err, "failed to unmarshal payload")
+	}
+	return delivery, nil
+}
+
+// Ack deletes the entry, as long as it hasn't been redelivered (or dead lettered) since.
+func (q *PostgresQueue) Ack(delivery *Delivery) error {
+	result, err := q.db.Exec(q.db.Rebind(`
+		DELETE FROM queue_entries WHERE id = ? AND attempts = ? AND dead_at IS NULL
+	`), delivery.Handle, delivery.Attempt)
+	if err != nil {
+		return errors.Wrapf(err, "failed to ack queue entry %s", delivery.ID)
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return ErrDeliveryExpired
+	}
+	return nil
+}
+
+func (q *PostgresQueue) Nack(delivery *Delivery, delay time.Duration) (deadLettered bool, err error) {
+	err = q.db.Get(&deadLettered, q.db.Rebind(`
+		UPDATE queue_entries SET queued_at = NOW(), visible_at = NOW() + ?::float8 * INTERVAL '1 millisecond',
+			dead_at = CASE WHEN attempts >= ? THEN NOW() END
+		WHERE id = ? AND attempts = ? AND dead_at IS NULL
+		RETURNING dead_at IS NOT NULL
+	`), delay.Milliseconds(), q.options.MaxAttempts, delivery.Handle, delivery.Attempt)
+	if err == sql.ErrNoRows {
+		return false, ErrDeliveryExpired
+	} else if err != nil {
+		return false, errors.Wrapf(err, "failed to requeue queue entry %s", delivery.ID)
+	}
+	return deadLettered, nil
+}
+
+// QueueLength is the entries waiting to be delivered, including those whose visibility timeout has passed.
+func (q *PostgresQueue) QueueLength() (int64, error) {
+	len := int64(0)
+	err := q.db.Get(&len, q.db.Rebind(`
+		SELECT COUNT(*) FROM queue_entries WHERE queue = ? AND dead_at IS NULL AND visible_at <= NOW()
+	`), q.name)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to get length of queue %v", q.name)
+	}
+	return len, nil
+}
+
+func (q *PostgresQueue) DeadLetterLength() (int64, error) {
+	len := int64(0)
+	err := q.db.Get(&len, q.db.Rebind(`
+		SELECT COUNT(*) FROM queue_entries WHERE queue = ? AND dead_at IS NOT NULL
+	`), q.name)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to get dead letter length of queue %v", q.name)
+	}
+	return len, nil
+}
+
+func (q *PostgresQueue) Close() error {
+	err := q.db.Close()
+	if err != nil {
+		return errors.Wrapf(err, "failed to close db connection for queue %v", q.name)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"time"
+)
+
+const (
+	DEFAULT_VISIBILITY_TIMEOUT_SECS = 600
+	DEFAULT_MAX_ATTEMPTS            = 3
+)
+
+var ErrDeliveryExpired = errors.New("delivery was not acked within the visibility timeout, and has been requeued")
+
+// Queue is an at-least-once FIFO queue of json payloads, with a priority lane whose entries are dequeued ahead of any
+// in the queue. A dequeued entry must be acked once it has been processed. One that isn't acked within the visibility
+// timeout (the consumer crashed or hung) is redelivered, as is one that is nacked, until it has been delivered
+// MaxAttempts times, when it is dead lettered instead.
+type Queue interface {
+	// Enqueue adds an entry to the back of the queue.
+	Enqueue(payload any) error
+	// EnqueuePriority adds an entry to the back of the priority lane, so that it is dequeued before anything in the
+	// queue e.g. for an on demand scan that shouldn't wait behind a routine batch.
+	EnqueuePriority(payload any) error
+	// Dequeue takes the entry at the front of the priority lane, or failing that the queue, returning nil if there
+	// isn't one (and it isn't blocking). A blocking dequeue waits for an entry to be queued, until the context is
+	// cancelled.
+	Dequeue(ctx context.Context, payload any, blocking bool) (*Delivery, error)
+	// Ack removes a processed entry from the queue. If it wasn't acked within the visibility timeout it may already
+	// have been redelivered, and ErrDeliveryExpired is returned.
+	Ack(delivery *Delivery) error
+	// Nack returns an entry that couldn't be processed to the back of its lane to be retried once the delay has
+	// passed, or dead letters it if it has been delivered the maximum number of times, returning true if it was dead
+	// lettered.
+	Nack(delivery *Delivery, delay time.Duration) (deadLettered bool, err error)
+	// QueueLength is the number of entries waiting to be dequeued, in the queue and its priority lane.
+	QueueLength() (int64, error)
+	// DeadLetterLength is the number of entries that were given up on.
+	DeadLetterLength() (int64, error)
+	Close() error
+}
+
+// Options control redelivery. Zero values are the defaults.
+type Options struct {
+	VisibilityTimeout time.Duration // how long a consumer has to ack an entry before it is redelivered
+	MaxAttempts       int           // deliveries of an entry before it is dead lettered
+
+	// ExpiredDeadLetter is called (if set) with the payload of each entry a consumer's Dequeue dead letters because
+	// its last delivery wasn't acked or nacked within the visibility timeout, so the entry can be settled as if it had
+	// been given up on. Nack tells its caller when it dead letters an entry, so those aren't passed to it.
+	ExpiredDeadLetter func(payload json.RawMessage)
+}
+
+// WithDefaults returns the options with any that aren't set defaulted.
+func (o Options) WithDefaults() Options {
+	if o.VisibilityTimeout <= 0 {
+		o.VisibilityTimeout = time.Duration(DEFAULT_VISIBILITY_TIMEOUT_SECS) * time.Second
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = DEFAULT_MAX_ATTEMPTS
+	}
+	return o
+}
+
+// DeadLetteredOnExpiry passes the payload of an entry dead lettered on expiry to the ExpiredDeadLetter hook, if set.
+func (o Options) DeadLetteredOnExpiry(payload []byte) {
+	if o.ExpiredDeadLetter != nil {
+		o.ExpiredDeadLetter(json.RawMessage(payload))
+	}
+}
+
+// Delivery is an entry taken from a queue, which must be acked once processed (or nacked to retry it).
+type Delivery struct {
+	ID      string
+	Attempt int         // 1 the first time the entry is delivered
+	Handle  interface{} // the queue's own reference to the entry
+}
+
+// ConsumerStats are what a queue that tracks its consumers knows about each of them.
+type ConsumerStats struct {
+	Consumer string
+	Pending  int64         // entries delivered to the consumer that it hasn't acked
+	Idle     time.Duration // how long the oldest of those has been waiting to be acked
+}
+
+// ConsumerReporter is a queue that can report on its consumers.
+type ConsumerReporter interface {
+	ConsumerStats() ([]ConsumerStats, error)
+}
@@ -7,8 +7,11 @@ import (
 )
 
 type QueuePayload struct {
+	ID        string // identifies the entry while it is being delivered
 	JSON      []byte
 	CreatedAt time.Time
+	Priority  bool // queued in the priority lane
+	Attempts  int  // deliveries before it was last requeued, where the queue doesn't count them itself
 }
 
 func (p *QueuePayload) Marshal() (string, error) {
@@ -1,31 +1,105 @@
 package redis
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/gavinturner/vinylretailers/util/queue"
 	"github.com/go-redis/redis"
 	"github.com/pkg/errors"
+	"math/rand"
+	"strconv"
 	"time"
 )
 
 const (
-	BLOCKING_DELAY_MSECS = 500
-	MASTER_QUEUE_KEY     = "vinylretailers::queue"
+	BLOCKING_TIMEOUT_SECS = 1 // longest a blocking dequeue waits before checking for cancellation, expiries and priority entries
+	MASTER_QUEUE_KEY      = "vinylretailers::queue"
 )
 
+var (
+	// moves the entry at the front of the priority lane (or failing that, the queue) onto the processing list,
+	// setting when it must be acked by and counting the delivery. entries are counted by id (or by their data, if
+	// they were queued before entries had ids)
+	dequeueScript = redis.NewScript(`
+		for _, lane in ipairs({KEYS[1], KEYS[2]}) do
+			local data = redis.call('RPOPLPUSH', lane, KEYS[3])
+			if data then
+				redis.call('ZADD', KEYS[4], ARGV[1], data)
+				local attempts = redis.call('HINCRBY', KEYS[5], cjson.decode(data).ID or data, 1)
+				return {data, attempts}
+			end
+		end
+		return false
+	`)
+	// sets when an entry a blocking dequeue has moved onto the processing list must be acked by, and counts the
+	// delivery
+	deliverScript = redis.NewScript(`
+		redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+		return redis.call('HINCRBY', KEYS[2], cjson.decode(ARGV[1]).ID or ARGV[1], 1)
+	`)
+	// gives entries on the processing list that have no ack deadline (their consumer went away between moving them
+	// there and setting one) a deadline, so that they expire and are requeued like any other delivery
+	adoptScript = redis.NewScript(`
+		for _, data in ipairs(redis.call('LRANGE', KEYS[1], 0, -1)) do
+			if not redis.call('ZSCORE', KEYS[2], data) then
+				redis.call('ZADD', KEYS[2], ARGV[1], data)
+			end
+		end
+		return 1
+	`)
+	// removes a processed entry. returns 0 if it had already expired
+	ackScript = redis.NewScript(`
+		if redis.call('ZREM', KEYS[2], ARGV[1]) == 0 then
+			return 0
+		end
+		redis.call('LREM', KEYS[1], 1, ARGV[1])
+		redis.call('HDEL', KEYS[3], cjson.decode(ARGV[1]).ID or ARGV[1])
+		return 1
+	`)
+	// puts an unprocessed entry back on the back of its lane, or on the dead letter list once it has been delivered
+	// the maximum number of times. returns 0 if requeued, 1 if dead lettered and -1 if it had already been acked or
+	// requeued
+	requeueScript = redis.NewScript(`
+		if redis.call('ZREM', KEYS[2], ARGV[1]) == 0 then
+			return -1
+		end
+		redis.call('LREM', KEYS[1], 1, ARGV[1])
+		local entry = cjson.decode(ARGV[1])
+		local id = entry.ID or ARGV[1]
+		local attempts = tonumber(redis.call('HGET', KEYS[6], id) or '0')
+		if attempts >= tonumber(ARGV[2]) then
+			redis.call('HDEL', KEYS[6], id)
+			redis.call('LPUSH', KEYS[5], ARGV[1])
+			return 1
+		end
+		if entry.Priority then
+			redis.call('LPUSH', KEYS[3], ARGV[1])
+		else
+			redis.call('LPUSH', KEYS[4], ARGV[1])
+		end
+		return 0
+	`)
+)
+
+// RedisQueue is a queue.Queue of redis lists. A dequeued entry is moved to a processing list rather than removed, and
+// is only removed once it is acked. Entries that have been delivered the maximum number of times are moved to a dead
+// letter list.
 type RedisQueue struct {
-	client *redis.Client
-	name   string
+	client  *redis.Client
+	name    string
+	options queue.Options
 }
 
-func ConnectToQueue(redisServer string, redisPassword string, queueName string, create bool) (*RedisQueue, error) {
+func ConnectToQueue(redisServer string, redisPassword string, queueName string, create bool, options queue.Options) (*RedisQueue, error) {
 	r := &RedisQueue{
 		client: redis.NewClient(&redis.Options{
 			Addr:     redisServer,
 			Password: redisPassword,
 			DB:       0, // default
 		}),
-		name: queueName,
+		name:    queueName,
+		options: options.WithDefaults(),
 	}
 	exists, err := r.PingRedis()
 	if err != nil {
@@ -52,49 +126,167 @@ func ConnectToQueue(redisServer string, redisPassword string, queueName string,
 }
 
 func (r *RedisQueue) Enqueue(payload any) error {
+	return r.enqueue(payload, false)
+}
+
+func (r *RedisQueue) EnqueuePriority(payload any) error {
+	return r.enqueue(payload, true)
+}
+
+func (r *RedisQueue) enqueue(payload any, priority bool) error {
 	bytes, err := json.Marshal(payload)
 	if err != nil {
 		return errors.Wrapf(err, "failed to marshal payload")
 	}
 	p := &QueuePayload{
+		ID:        strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.FormatInt(rand.Int63(), 36),
 		CreatedAt: time.Now(),
 		JSON:      bytes,
+		Priority:  priority,
 	}
 	data, err := p.Marshal()
 	if err != nil {
 		return errors.Wrapf(err, "failed to marshal queue entry")
 	}
-	lpush := r.client.LPush(r.queueName(), data)
-	return lpush.Err()
+	lane := r.queueName()
+	if priority {
+		lane = r.priorityName()
+	}
+	err = r.client.LPush(lane, data).Err()
+	if err != nil {
+		return errors.Wrapf(err, "failed to push queue entry")
+	}
+	return nil
 }
 
-func (r *RedisQueue) Dequeue(payload any, blocking bool) (bool, error) {
-	var data string
-	var err error
+// Dequeue takes the next entry, moving it onto the processing list until it is acked. A blocking dequeue waits on
+// the queue with BRPOPLPUSH, which moves an entry as soon as one is queued. The wait is cut short every
+// BLOCKING_TIMEOUT_SECS to check for cancellation, expired deliveries and priority entries (which are on a list of
+// their own).
+func (r *RedisQueue) Dequeue(ctx context.Context, payload any, blocking bool) (*queue.Delivery, error) {
+	var result interface{}
 	for {
-		data, err = r.client.LPop(r.queueName()).Result()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		err := r.requeueExpired()
+		if err != nil {
+			return nil, err
+		}
+		result, err = dequeueScript.Run(r.client, r.deliveryKeys(), r.ackDeadline()).Result()
+		if err == nil {
+			break
+		} else if err != redis.Nil {
+			return nil, errors.Wrapf(err, "failed to pop queue entry")
+		}
+		if !blocking {
+			return nil, nil
+		}
+		data, err := r.client.BRPopLPush(r.queueName(), r.processingName(), time.Duration(BLOCKING_TIMEOUT_SECS)*time.Second).Result()
+		if err == redis.Nil {
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed to wait on queue %v", r.name)
+		}
+		attempts, err := deliverScript.Run(r.client, []string{r.inflightName(), r.attemptsName()}, data, r.ackDeadline()).Result()
 		if err != nil {
-			if err.Error() == "redis: nil" {
-				if !blocking {
-					return false, nil
-				}
-				time.Sleep(time.Duration(BLOCKING_DELAY_MSECS) * time.Millisecond)
-				continue
-			}
-			return false, errors.Wrapf(err, "failed to pop queue entry")
+			// it's on the processing list, so it will be requeued once it expires
+			return nil, errors.Wrapf(err, "failed to record delivery of queue entry")
 		}
+		result = []interface{}{data, attempts}
 		break
 	}
+	reply, ok := result.([]interface{})
+	if !ok || len(reply) != 2 {
+		return nil, fmt.Errorf("unexpected reply %v dequeuing from queue %v", result, r.name)
+	}
+	data, _ := reply[0].(string)
+	attempt, _ := reply[1].(int64)
 	entry := QueuePayload{}
-	err = entry.Unmarshal(data)
+	err := entry.Unmarshal(data)
 	if err != nil {
-		return false, errors.Wrapf(err, "failed to unmarshal queue entry")
+		return nil, errors.Wrapf(err, "failed to unmarshal queue entry")
 	}
+	delivery := &queue.Delivery{ID: entry.ID, Attempt: int(attempt), Handle: data}
 	err = json.Unmarshal(entry.JSON, payload)
 	if err != nil {
-		return false, errors.Wrapf(err, "failed to unmarshal payload")
+		// it will never unmarshal, so don't leave it to be redelivered
+		_, _ = r.deadLetter(delivery)
+		return nil, errors.Wrapf(err, "failed to unmarshal payload")
+	}
+	return delivery, nil
+}
+
+func (r *RedisQueue) Ack(delivery *queue.Delivery) error {
+	acked, err := ackScript.Run(r.client, []string{r.processingName(), r.inflightName(), r.attemptsName()}, delivery.Handle).Int64()
+	if err != nil {
+		return errors.Wrapf(err, "failed to ack queue entry %s", delivery.ID)
+	}
+	if acked == 0 {
+		return queue.ErrDeliveryExpired
+	}
+	return nil
+}
+
+func (r *RedisQueue) Nack(delivery *queue.Delivery, delay time.Duration) (deadLettered bool, err error) {
+	if delay > 0 && delivery.Attempt < r.options.MaxAttempts {
+		// it stays on the processing list until the delay has passed, when it is requeued as if it had expired
+		deadline := time.Now().Add(delay).UnixNano() / int64(time.Millisecond)
+		delayed, err := r.client.ZAddXXCh(r.inflightName(), redis.Z{Score: float64(deadline), Member: delivery.Handle}).Result()
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to delay queue entry %s", delivery.ID)
+		}
+		if delayed == 0 {
+			return false, queue.ErrDeliveryExpired
+		}
+		return false, nil
+	}
+	requeued, err := requeueScript.Run(r.client, r.requeueKeys(), delivery.Handle, r.options.MaxAttempts).Int64()
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to requeue queue entry %s", delivery.ID)
+	}
+	if requeued < 0 {
+		return false, queue.ErrDeliveryExpired
+	}
+	return requeued == 1, nil
+}
+
+func (r *RedisQueue) deadLetter(delivery *queue.Delivery) (bool, error) {
+	requeued, err := requeueScript.Run(r.client, r.requeueKeys(), delivery.Handle, 0).Int64()
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to dead letter queue entry %s", delivery.ID)
 	}
-	return true, nil
+	return requeued == 1, nil
+}
+
+// ackDeadline is when an entry delivered now must be acked by (unix millis).
+func (r *RedisQueue) ackDeadline() int64 {
+	return time.Now().Add(r.options.VisibilityTimeout).UnixNano() / int64(time.Millisecond)
+}
+
+// requeueExpired requeues (or dead letters) entries that weren't acked within the visibility timeout. Only the
+// consumer whose script dead letters an entry passes it to the ExpiredDeadLetter hook.
+func (r *RedisQueue) requeueExpired() error {
+	err := adoptScript.Run(r.client, []string{r.processingName(), r.inflightName()}, r.ackDeadline()).Err()
+	if err != nil {
+		return errors.Wrapf(err, "failed to check deliveries of queue %v", r.name)
+	}
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	expired, err := r.client.ZRangeByScore(r.inflightName(), redis.ZRangeBy{Min: "-inf", Max: strconv.FormatInt(now, 10)}).Result()
+	if err != nil {
+		return errors.Wrapf(err, "failed to get expired entries of queue %v", r.name)
+	}
+	for _, data := range expired {
+		requeued, err := requeueScript.Run(r.client, r.requeueKeys(), data, r.options.MaxAttempts).Int64()
+		if err != nil {
+			return errors.Wrapf(err, "failed to requeue expired entry of queue %v", r.name)
+		}
+		entry := QueuePayload{}
+		if requeued == 1 && entry.Unmarshal(data) == nil {
+			r.options.DeadLetteredOnExpiry(entry.JSON)
+		}
+	}
+	return nil
 }
 
 func (r *RedisQueue) Close() error {
@@ -106,15 +298,27 @@ func (r *RedisQueue) Close() error {
 }
 
 func (r *RedisQueue) QueueLength() (int64, error) {
-	len, err := r.client.LLen(r.queueName()).Result()
+	total := int64(0)
+	for _, lane := range []string{r.priorityName(), r.queueName()} {
+		len, err := r.client.LLen(lane).Result()
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to get length of queue %v", r.name)
+		}
+		total += len
+	}
+	return total, nil
+}
+
+func (r *RedisQueue) DeadLetterLength() (int64, error) {
+	len, err := r.client.LLen(r.deadLetterName()).Result()
 	if err != nil {
-		return 0, errors.Wrapf(err, "failed to get length of queue %v", r.name)
+		return 0, errors.Wrapf(err, "failed to get length of dead letter queue %v", r.name)
 	}
 	return len, nil
 }
 
 func (r *RedisQueue) DestroyAndCleanup() error {
-	err := r.client.Del(r.queueName()).Err()
+	err := r.client.Del(r.queueName(), r.priorityName(), r.processingName(), r.inflightName(), r.attemptsName(), r.deadLetterName()).Err()
 	if err != nil {
 		return errors.Wrapf(err, "failed to cleanup existing items from queue %v", r.name)
 	}
@@ -143,3 +347,35 @@ func (r *RedisQueue) destroy() error {
 func (r *RedisQueue) queueName() string {
 	return MASTER_QUEUE_KEY + "::" + r.name
 }
+
+// priorityName is the lane of entries dequeued ahead of the queue
+func (r *RedisQueue) priorityName() string {
+	return r.queueName() + "::priority"
+}
+
+// processingName is the list of entries that have been delivered but not acked
+func (r *RedisQueue) processingName() string {
+	return r.queueName() + "::processing"
+}
+
+// inflightName is the sorted set of delivered entries, scored by when they must be acked by (unix millis)
+func (r *RedisQueue) inflightName() string {
+	return r.queueName() + "::inflight"
+}
+
+// attemptsName is the hash of entry ids to the number of times they have been delivered
+func (r *RedisQueue) attemptsName() string {
+	return r.queueName() + "::attempts"
+}
+
+func (r *RedisQueue) deadLetterName() string {
+	return r.queueName() + "::dead"
+}
+
+func (r *RedisQueue) deliveryKeys() []string {
+	return []string{r.priorityName(), r.queueName(), r.processingName(), r.inflightName(), r.attemptsName()}
+}
+
+func (r *RedisQueue) requeueKeys() []string {
+	return []string{r.processingName(), r.inflightName(), r.priorityName(), r.queueName(), r.deadLetterName(), r.attemptsName()}
+}
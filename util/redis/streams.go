@@ -0,0 +1,411 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gavinturner/vinylretailers/util/queue"
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	STREAM_GROUP      = "consumers"
+	STREAM_DATA_FIELD = "entry"
+	MAX_CLAIMS        = 100 // stuck entries looked at on each dequeue
+)
+
+// moves delayed entries that are due onto the back of their streams
+var promoteScript = redis.NewScript(`
+	local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+	for _, data in ipairs(due) do
+		redis.call('ZREM', KEYS[1], data)
+		if cjson.decode(data).Priority then
+			redis.call('XADD', KEYS[2], '*', ARGV[2], data)
+		else
+			redis.call('XADD', KEYS[3], '*', ARGV[2], data)
+		end
+	end
+	return #due
+`)
+
+// StreamQueue is a queue.Queue of redis streams read by a consumer group, so the group's pending entries list shows
+// which consumer holds which entry, and for how long. A consumer that dies with entries pending has them claimed by
+// the next consumer to dequeue once their visibility timeout has passed. Acked entries are deleted from the stream,
+// so its length is the entries waiting plus those pending. Nacked entries are re-added to the back of their stream,
+// carrying the deliveries so far, or held in a sorted set until they are due if they are to be retried later.
+type StreamQueue struct {
+	client   *redis.Client
+	name     string
+	consumer string
+	options  queue.Options
+}
+
+// streamHandle is the stream and id of a delivered entry
+type streamHandle struct {
+	stream string
+	id     string
+	entry  QueuePayload
+}
+
+// ConnectToStreamQueue connects to the named stream queue (creating its streams and consumer group if need be) as a
+// consumer named for the host and process.
+func ConnectToStreamQueue(redisServer string, redisPassword string, queueName string, options queue.Options) (*StreamQueue, error) {
+	hostname, _ := os.Hostname()
+	r := &StreamQueue{
+		client: redis.NewClient(&redis.Options{
+			Addr:     redisServer,
+			Password: redisPassword,
+			DB:       0, // default
+		}),
+		name:     queueName,
+		consumer: fmt.Sprintf("%s-%v", hostname, os.Getpid()),
+		options:  options.WithDefaults(),
+	}
+	if err := r.client.Ping().Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to contact redis server at %v", redisServer)
+	}
+	for _, stream := range r.lanes() {
+		err := r.client.XGroupCreateMkStream(stream, STREAM_GROUP, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return nil, errors.Wrapf(err, "failed to create consumer group for '%s' on %v", stream, redisServer)
+		}
+	}
+	return r, nil
+}
+
+func (r *StreamQueue) Enqueue(payload any) error {
+	return r.enqueue(payload, false)
+}
+
+func (r *StreamQueue) EnqueuePriority(payload any) error {
+	return r.enqueue(payload, true)
+}
+
+func (r *StreamQueue) enqueue(payload any, priority bool) error {
+	bytes, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal payload")
+	}
+	p := &QueuePayload{CreatedAt: time.Now(), JSON: bytes, Priority: priority}
+	data, err := p.Marshal()
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal queue entry")
+	}
+	err = r.client.XAdd(&redis.XAddArgs{Stream: r.laneName(priority), Values: map[string]interface{}{STREAM_DATA_FIELD: data}}).Err()
+	if err != nil {
+		return errors.Wrapf(err, "failed to add queue entry")
+	}
+	return nil
+}
+
+func (r *StreamQueue) Dequeue(ctx context.Context, payload any, blocking bool) (*queue.Delivery, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		err := r.promoteDelayed()
+		if err != nil {
+			return nil, err
+		}
+		delivery, err := r.claimStuck()
+		if err != nil || delivery != nil {
+			return r.unmarshal(delivery, payload, err)
+		}
+		// the priority lane is checked without blocking before each (blocking) read of the queue, so a priority
+		// entry waits at most BLOCKING_TIMEOUT_SECS behind a blocked consumer
+		delivery, err = r.read(r.laneName(true), -1)
+		if err != nil || delivery != nil {
+			return r.unmarshal(delivery, payload, err)
+		}
+		block := time.Duration(-1)
+		if blocking {
+			block = time.Duration(BLOCKING_TIMEOUT_SECS) * time.Second
+		}
+		delivery, err = r.read(r.laneName(false), block)
+		if err != nil || delivery != nil {
+			return r.unmarshal(delivery, payload, err)
+		}
+		if !blocking {
+			return nil, nil
+		}
+	}
+}
+
+// read reads the next new entry from the stream for this consumer, waiting up to block for one (if block isn't -1)
+func (r *StreamQueue) read(stream string, block time.Duration) (*queue.Delivery, error) {
+	streams, err := r.client.XReadGroup(&redis.XReadGroupArgs{
+		Group:    STREAM_GROUP,
+		Consumer: r.consumer,
+		Streams:  []string{stream, ">"},
+		Count:    1,
+		Block:    block,
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to read from stream '%s'", stream)
+	}
+	for _, s := range streams {
+		for _, message := range s.Messages {
+			return r.delivery(stream, message, 1)
+		}
+	}
+	return nil, nil
+}
+
+// claimStuck claims an entry that has been pending longer than the visibility timeout (its consumer died, or is hung)
+// to redeliver it, dead lettering any that have been delivered the maximum number of times along the way.
+func (r *StreamQueue) claimStuck() (*queue.Delivery, error) {
+	for _, stream := range r.lanes() {
+		pending, err := r.client.XPendingExt(&redis.XPendingExtArgs{
+			Stream: stream, Group: STREAM_GROUP, Start: "-", End: "+", Count: MAX_CLAIMS,
+		}).Result()
+		if err != nil && err != redis.Nil {
+			return nil, errors.Wrapf(err, "failed to get pending entries of stream '%s'", stream)
+		}
+		for _, p := range pending {
+			if p.Idle < r.options.VisibilityTimeout {
+				continue
+			}
+			// only one consumer manages to claim it, as claiming resets its idle time
+			claimed, err := r.client.XClaim(&redis.XClaimArgs{
+				Stream: stream, Group: STREAM_GROUP, Consumer: r.consumer, MinIdle: r.options.VisibilityTimeout, Messages: []string{p.Id},
+			}).Result()
+			if err != nil && err != redis.Nil {
+				return nil, errors.Wrapf(err, "failed to claim entry %s of stream '%s'", p.Id, stream)
+			}
+			for _, message := range claimed {
+				delivery, err := r.delivery(stream, message, int(p.RetryCount)+1)
+				if err != nil {
+					return nil, err
+				}
+				if delivery.Attempt <= r.options.MaxAttempts {
+					return delivery, nil
+				}
+				err = r.deadLetter(delivery)
+				if err != nil {
+					return nil, err
+				}
+				r.options.DeadLetteredOnExpiry(delivery.Handle.(streamHandle).entry.JSON)
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (r *StreamQueue) delivery(stream string, message redis.XMessage, deliveries int) (*queue.Delivery, error) {
+	data, _ := message.Values[STREAM_DATA_FIELD].(string)
+	entry := QueuePayload{}
+	err := entry.Unmarshal(data)
+	if err != nil {
+		// it will never unmarshal, so don't leave it to be redelivered
+		_ = r.remove(stream, message.ID)
+		return nil, errors.Wrapf(err, "failed to unmarshal queue entry")
+	}
+	return &queue.Delivery{
+		ID:      message.ID,
+		Attempt: entry.Attempts + deliveries,
+		Handle:  streamHandle{stream: stream, id: message.ID, entry: entry},
+	}, nil
+}
+
+func (r *StreamQueue) unmarshal(delivery *queue.Delivery, payload any, err error) (*queue.Delivery, error) {
+	if err != nil || delivery == nil {
+		return delivery, err
+	}
+	err = json.Unmarshal(delivery.Handle.(streamHandle).entry.JSON, payload)
+	if err != nil {
+		_ = r.deadLetter(delivery)
+		return nil, errors.Wrapf(err, "failed to unmarshal payload")
+	}
+	return delivery, nil
+}
+
+func (r *StreamQueue) Ack(delivery *queue.Delivery) error {
+	handle := delivery.Handle.(streamHandle)
+	pipe := r.client.TxPipeline()
+	acked := pipe.XAck(handle.stream, STREAM_GROUP, handle.id)
+	pipe.XDel(handle.stream, handle.id)
+	_, err := pipe.Exec()
+	if err != nil {
+		return errors.Wrapf(err, "failed to ack queue entry %s", delivery.ID)
+	}
+	if acked.Val() == 0 {
+		return queue.ErrDeliveryExpired
+	}
+	return nil
+}
+
+func (r *StreamQueue) Nack(delivery *queue.Delivery, delay time.Duration) (deadLettered bool, err error) {
+	if delivery.Attempt >= r.options.MaxAttempts {
+		return true, r.deadLetter(delivery)
+	}
+	handle := delivery.Handle.(streamHandle)
+	entry := handle.entry
+	entry.Attempts = delivery.Attempt
+	data, err := entry.Marshal()
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to marshal queue entry")
+	}
+	if delay > 0 {
+		return false, r.delay(delivery, data, time.Now().Add(delay))
+	}
+	return false, r.move(delivery, r.laneName(entry.Priority), data)
+}
+
+// delay acks an entry and holds it in the delayed set until it is due, in one transaction
+func (r *StreamQueue) delay(delivery *queue.Delivery, data string, due time.Time) error {
+	handle := delivery.Handle.(streamHandle)
+	pipe := r.client.TxPipeline()
+	pipe.ZAdd(r.delayedName(), redis.Z{Score: float64(due.UnixNano() / int64(time.Millisecond)), Member: data})
+	pipe.XAck(handle.stream, STREAM_GROUP, handle.id)
+	pipe.XDel(handle.stream, handle.id)
+	_, err := pipe.Exec()
+	if err != nil {
+		return errors.Wrapf(err, "failed to delay queue entry %s", delivery.ID)
+	}
+	return nil
+}
+
+// promoteDelayed moves delayed entries that are due onto the back of their streams to be redelivered
+func (r *StreamQueue) promoteDelayed() error {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	err := promoteScript.Run(r.client, []string{r.delayedName(), r.laneName(true), r.laneName(false)}, now, STREAM_DATA_FIELD).Err()
+	if err != nil {
+		return errors.Wrapf(err, "failed to requeue delayed entries of queue %v", r.name)
+	}
+	return nil
+}
+
+func (r *StreamQueue) deadLetter(delivery *queue.Delivery) error {
+	entry := delivery.Handle.(streamHandle).entry
+	data, err := entry.Marshal()
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal queue entry")
+	}
+	return r.move(delivery, r.deadLetterName(), data)
+}
+
+// move acks an entry and adds it to the back of another (or the same) stream in one transaction
+func (r *StreamQueue) move(delivery *queue.Delivery, stream string, data string) error {
+	handle := delivery.Handle.(streamHandle)
+	pipe := r.client.TxPipeline()
+	pipe.XAdd(&redis.XAddArgs{Stream: stream, Values: map[string]interface{}{STREAM_DATA_FIELD: data}})
+	pipe.XAck(handle.stream, STREAM_GROUP, handle.id)
+	pipe.XDel(handle.stream, handle.id)
+	_, err := pipe.Exec()
+	if err != nil {
+		return errors.Wrapf(err, "failed to move queue entry %s to '%s'", delivery.ID, stream)
+	}
+	return nil
+}
+
+func (r *StreamQueue) remove(stream string, id string) error {
+	pipe := r.client.TxPipeline()
+	pipe.XAck(stream, STREAM_GROUP, id)
+	pipe.XDel(stream, id)
+	_, err := pipe.Exec()
+	return err
+}
+
+// QueueLength is the entries that haven't been delivered yet. Delivered entries stay in the stream until acked, so
+// these are the stream's entries less those pending.
+func (r *StreamQueue) QueueLength() (int64, error) {
+	total := int64(0)
+	for _, stream := range r.lanes() {
+		len, err := r.client.XLen(stream).Result()
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to get length of stream '%s'", stream)
+		}
+		pending, err := r.client.XPending(stream, STREAM_GROUP).Result()
+		if err != nil && err != redis.Nil {
+			return 0, errors.Wrapf(err, "failed to get pending entries of stream '%s'", stream)
+		}
+		if pending != nil {
+			len -= pending.Count
+		}
+		total += len
+	}
+	return total, nil
+}
+
+func (r *StreamQueue) DeadLetterLength() (int64, error) {
+	len, err := r.client.XLen(r.deadLetterName()).Result()
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to get length of dead letter stream %v", r.name)
+	}
+	return len, nil
+}
+
+// ConsumerStats reports the entries each consumer holds, and how long it has held the oldest of them. A consumer
+// with a long idle time has probably died, and its entries will be claimed once their visibility timeout passes.
+func (r *StreamQueue) ConsumerStats() ([]queue.ConsumerStats, error) {
+	stats := []queue.ConsumerStats{}
+	index := map[string]int{}
+	for _, stream := range r.lanes() {
+		pending, err := r.client.XPendingExt(&redis.XPendingExtArgs{
+			Stream: stream, Group: STREAM_GROUP, Start: "-", End: "+", Count: MAX_CLAIMS,
+		}).Result()
+		if err != nil && err != redis.Nil {
+			return nil, errors.Wrapf(err, "failed to get pending entries of stream '%s'", stream)
+		}
+		for _, p := range pending {
+			i, ok := index[p.Consumer]
+			if !ok {
+				i = len(stats)
+				index[p.Consumer] = i
+				stats = append(stats, queue.ConsumerStats{Consumer: p.Consumer})
+			}
+			stats[i].Pending++
+			if p.Idle > stats[i].Idle {
+				stats[i].Idle = p.Idle
+			}
+		}
+	}
+	return stats, nil
+}
+
+func (r *StreamQueue) Close() error {
+	err := r.client.Close()
+	if err != nil {
+		return errors.Wrapf(err, "failed to close redis client for queue %s", r.name)
+	}
+	return nil
+}
+
+func (r *StreamQueue) DestroyAndCleanup() error {
+	err := r.client.Del(append(r.lanes(), r.deadLetterName(), r.delayedName())...).Err()
+	if err != nil {
+		return errors.Wrapf(err, "failed to cleanup streams of queue %v", r.name)
+	}
+	return nil
+}
+
+func (r *StreamQueue) streamName() string {
+	return MASTER_QUEUE_KEY + "::" + r.name + "::stream"
+}
+
+func (r *StreamQueue) laneName(priority bool) string {
+	if priority {
+		return r.streamName() + "::priority"
+	}
+	return r.streamName()
+}
+
+// lanes are the streams entries are dequeued from, in the order they are checked
+func (r *StreamQueue) lanes() []string {
+	return []string{r.laneName(true), r.laneName(false)}
+}
+
+// delayedName is the sorted set of nacked entries waiting to be retried, scored by when they are due (unix millis)
+func (r *StreamQueue) delayedName() string {
+	return r.streamName() + "::delayed"
+}
+
+func (r *StreamQueue) deadLetterName() string {
+	return r.streamName() + "::dead"
+}